@@ -0,0 +1,203 @@
+// anomalies.go
+// sudden spikes or drops in a month's findings per LoB/Team and per scanner
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// How a month is compared with the months before it - mad for the median
+// absolute deviation (modified z-score) or zscore for the mean and standard
+// deviation
+var anomalyMethods = []string{"mad", "zscore"}
+
+var anomalyMethod = "mad"
+
+// Score from which a month's count is flagged, either way
+var anomalyThreshold = 3.5
+
+// Months before the month checked that it's compared with
+var anomalyMonths = 6
+
+// Smallest change in findings flagged, so small counts going from 1 to 4
+// aren't
+var anomalyMinChange = 10
+
+// A month's count well away from the months before it
+type anomaly struct {
+	kind    string  // LoB/Team or Scanner
+	name    string  // LoB/Team or scanner name
+	count   float64 // findings in the month, projected to the full month if it's under way
+	typical float64 // median or mean of the months before
+	score   float64 // how far off it is, +/-Inf when the months before never varied
+}
+
+func (a anomaly) change() string {
+	if a.count > a.typical {
+		return "Spike"
+	}
+
+	return "Drop"
+}
+
+// Look for anomalies in the first of ms, newest first, against the rest
+func findAnomalies(ms []*tfMonth) []anomaly {
+	if len(ms) < 4 {
+		return nil
+	}
+	// Scale a month under way up to the whole month
+	scale := 1.0
+	if m := ms[0]; m.mpartial && m.tStamp.Day() > 0 {
+		scale = float64(lastDate(int(m.tStamp.Month()), m.tStamp.Year())) / float64(m.tStamp.Day())
+	}
+
+	var found []anomaly
+	for _, series := range []struct {
+		kind   string
+		counts func(m *tfMonth) map[string]int
+	}{
+		{"LoB/Team", func(m *tfMonth) map[string]int {
+			lobs := make(map[string]int)
+			for lob, v := range m.vulnByLob {
+				lobs[lob] = v.crit + v.high + v.med + v.low
+			}
+			return lobs
+		}},
+		{"Scanner", func(m *tfMonth) map[string]int { return m.toolUsage }},
+	} {
+		byMonth := make([]map[string]int, len(ms))
+		names := make(map[string]bool)
+		for i, m := range ms {
+			byMonth[i] = series.counts(m)
+			for n, _ := range byMonth[i] {
+				names[n] = true
+			}
+		}
+		for name, _ := range names {
+			var hist []float64
+			for _, counts := range byMonth[1:] {
+				hist = append(hist, float64(counts[name]))
+			}
+			a := anomaly{kind: series.kind, name: name, count: float64(byMonth[0][name]) * scale}
+			a.typical, a.score = anomalyScore(a.count, hist)
+			if math.Abs(a.count-a.typical) >= float64(anomalyMinChange) && math.Abs(a.score) >= anomalyThreshold {
+				found = append(found, a)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if math.Abs(found[i].score) != math.Abs(found[j].score) {
+			return math.Abs(found[i].score) > math.Abs(found[j].score)
+		}
+		if found[i].kind != found[j].kind {
+			return found[i].kind < found[j].kind
+		}
+		return found[i].name < found[j].name
+	})
+
+	return found
+}
+
+// The typical value of hist and how far v is from it by anomalyMethod
+func anomalyScore(v float64, hist []float64) (float64, float64) {
+	var mid, spread float64
+	if anomalyMethod == "zscore" {
+		for _, h := range hist {
+			mid += h
+		}
+		mid /= float64(len(hist))
+		for _, h := range hist {
+			spread += (h - mid) * (h - mid)
+		}
+		spread = math.Sqrt(spread / float64(len(hist)-1))
+	} else {
+		mid = median(hist)
+		var devs []float64
+		for _, h := range hist {
+			devs = append(devs, math.Abs(h-mid))
+		}
+		// Scaled so it's comparable with a standard deviation
+		spread = median(devs) / 0.6745
+	}
+
+	switch {
+	case v == mid:
+		return mid, 0
+	case spread == 0:
+		return mid, math.Inf(int(math.Copysign(1, v-mid)))
+	}
+
+	return mid, (v - mid) / spread
+}
+
+func median(vs []float64) float64 {
+	s := append([]float64(nil), vs...)
+	sort.Float64s(s)
+	n := len(s)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return s[n/2]
+	}
+
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// Look for anomalies in the month ending with end
+func fetchAnomalies(end time.Time) ([]anomaly, error) {
+	ms, err := fetchHistory(end, anomalyMonths+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return findAnomalies(ms), nil
+}
+
+///////////////////////////////////////
+// Setters                           //
+///////////////////////////////////////
+
+func setAnomalyMethod(v string) error {
+	if !oneOf(v, anomalyMethods) {
+		return fmt.Errorf("must be mad or zscore not %v", v)
+	}
+	anomalyMethod = v
+
+	return nil
+}
+
+func setAnomalyThreshold(v string) error {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return fmt.Errorf("must be a number more than 0 not %v", v)
+	}
+	anomalyThreshold = f
+
+	return nil
+}
+
+func setAnomalyMonths(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 3 {
+		return fmt.Errorf("must be 3 or more months not %v", v)
+	}
+	anomalyMonths = n
+
+	return nil
+}
+
+func setAnomalyMinChange(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a whole number, 0 or more, not %v", v)
+	}
+	anomalyMinChange = n
+
+	return nil
+}
@@ -0,0 +1,90 @@
+// anomalies_test.go
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAnomalyScore(t *testing.T) {
+	defer func(m string) { anomalyMethod = m }(anomalyMethod)
+	tests := []struct {
+		method string
+		v      float64
+		hist   []float64
+		mid    float64
+		score  float64
+	}{
+		// Median 14, the median deviation of 2 is scaled by 0.6745
+		{"mad", 30, []float64{18, 10, 14, 16, 12}, 14, 16 * 0.6745 / 2},
+		{"mad", 10, []float64{18, 10, 14, 16, 12}, 14, -4 * 0.6745 / 2},
+		{"mad", 14, []float64{18, 10, 14, 16, 12}, 14, 0},
+		// Even counts take the middle two, median 4 and deviations 3, 1, 1, 3
+		{"mad", 10, []float64{1, 3, 5, 7}, 4, 6 * 0.6745 / 2},
+		// An outlier in the history barely moves the median
+		{"mad", 30, []float64{10, 10, 11, 12, 200}, 11, 19 * 0.6745 / 1},
+		{"mad", 12, []float64{10, 10, 10}, 10, math.Inf(1)},
+		{"mad", 8, []float64{10, 10, 10}, 10, math.Inf(-1)},
+		{"mad", 10, []float64{10, 10, 10}, 10, 0},
+		// Mean 14 and sample standard deviation sqrt(40/4)
+		{"zscore", 20, []float64{10, 12, 14, 16, 18}, 14, 6 / math.Sqrt(10)},
+		{"zscore", 4, []float64{10, 12, 14, 16, 18}, 14, -10 / math.Sqrt(10)},
+		{"zscore", 30, []float64{10, 10, 11, 12, 200}, 48.6, (30 - 48.6) / math.Sqrt((2*38.6*38.6+37.6*37.6+36.6*36.6+151.4*151.4)/4)},
+		{"zscore", 12, []float64{10, 10, 10}, 10, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		anomalyMethod = tt.method
+		mid, score := anomalyScore(tt.v, tt.hist)
+		if !near(mid, tt.mid) || !(score == tt.score || near(score, tt.score)) {
+			t.Errorf("%v %v against %v: got %v, %v want %v, %v", tt.method, tt.v, tt.hist, mid, score, tt.mid, tt.score)
+		}
+	}
+}
+
+func TestFindAnomalies(t *testing.T) {
+	defer func(m string, th float64, c int) {
+		anomalyMethod, anomalyThreshold, anomalyMinChange = m, th, c
+	}(anomalyMethod, anomalyThreshold, anomalyMinChange)
+	anomalyMethod, anomalyThreshold, anomalyMinChange = "mad", 3.5, 10
+
+	end := mustDay(t, "2026-09-30")
+	tests := []struct {
+		name string
+		end  string // the newest month's day, the month is partial unless it's the last
+		zap  []int  // ZAP findings, newest first
+		pay  []int  // Pay's critical findings, newest first
+		want string
+	}{
+		{"too few months", "", []int{100, 20, 20}, nil, ""},
+		{"steady", "", []int{21, 20, 18, 22, 19, 20, 21}, []int{5, 6, 5, 4, 5, 6, 5}, ""},
+		{"spike", "", []int{100, 20, 18, 22, 19, 20, 21}, nil, "Scanner ZAP Spike"},
+		{"drop", "", []int{0, 20, 18, 22, 19, 20, 21}, nil, "Scanner ZAP Drop"},
+		{"too small a change", "", []int{9, 1, 1, 1, 1, 1, 1}, nil, ""},
+		// 10 findings by the 10th is 30 for September
+		{"projected", "2026-09-10", []int{10, 30, 28, 32, 29, 30, 31}, nil, ""},
+		{"projected spike", "2026-09-10", []int{20, 30, 28, 32, 29, 30, 31}, nil, "Scanner ZAP Spike"},
+		// Biggest scores first
+		{"both", "", []int{50, 20, 18, 22, 19, 20, 21}, []int{200, 6, 5, 4, 5, 6, 5}, "LoB/Team Pay Spike|Scanner ZAP Spike"},
+	}
+	for _, tt := range tests {
+		var ms []*tfMonth
+		for i, v := range tt.zap {
+			m := &tfMonth{tStamp: end.AddDate(0, -i, 0), toolUsage: map[string]int{"ZAP": v}}
+			if i == 0 && tt.end != "" {
+				m.tStamp, m.mpartial = mustDay(t, tt.end), true
+			}
+			if tt.pay != nil {
+				m.vulnByLob = map[string]VulnCount{"Pay": {crit: tt.pay[i]}}
+			}
+			ms = append(ms, m)
+		}
+		var got []string
+		for _, a := range findAnomalies(ms) {
+			got = append(got, a.kind+" "+a.name+" "+a.change())
+		}
+		if g := strings.Join(got, "|"); g != tt.want {
+			t.Errorf("%v: got %q want %q", tt.name, g, tt.want)
+		}
+	}
+}
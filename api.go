@@ -0,0 +1,567 @@
+// api.go
+// versioned JSON API served by serve mode for other tools to consume the metrics
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Size of a page of apps when per_page isn't sent and the most that can be asked for
+const defaultPerPage = 100
+const maxPerPage = 1000
+
+func (s *server) api(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/", s.locked(s.apiRoute))
+}
+
+// Send /api/v1/{kind} and /api/v1/{kind}/{name} to their handlers
+func (s *server) apiRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		apiError(w, http.StatusMethodNotAllowed, "Only GET is supported")
+		return
+	}
+	kind, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+
+	list := map[string]http.HandlerFunc{
+		"months":   s.apiMonths,
+		"quarters": s.apiQuarters,
+		"years":    s.apiYears,
+		"teams":    s.apiTeams,
+	}
+	one := map[string]func(http.ResponseWriter, *http.Request, string){
+		"months":   s.apiMonth,
+		"quarters": s.apiQuarter,
+		"years":    s.apiYear,
+		"teams":    s.apiTeam,
+	}
+	if _, ok := list[kind]; !ok {
+		apiError(w, http.StatusNotFound, "Unknown API path "+r.URL.Path)
+		return
+	}
+	if name == "" {
+		list[kind](w, r)
+		return
+	}
+	one[kind](w, r, name)
+}
+
+///////////////////////////////////////
+// JSON views of the metrics structs //
+///////////////////////////////////////
+
+type apiVulnCount struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+}
+
+type apiApp struct {
+	Name     string        `json:"name"`
+	Count    int           `json:"count,omitempty"`
+	Score    *int          `json:"score,omitempty"`
+	Vulns    *apiVulnCount `json:"vulns,omitempty"`
+	Findings int           `json:"findings,omitempty"`
+}
+
+// A page of a list of apps
+type apiAppPage struct {
+	Total    int      `json:"total"`
+	Page     int      `json:"page"`
+	PerPage  int      `json:"per_page"`
+	NextPage int      `json:"next_page,omitempty"`
+	Items    []apiApp `json:"items"`
+}
+
+type apiCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type apiMonth struct {
+	Month        string                  `json:"month"`
+	Quarter      string                  `json:"quarter"`
+	Partial      bool                    `json:"partial"`
+	TotVulns     int                     `json:"total_vulns"`
+	TotAssess    int                     `json:"total_assessed"`
+	VulnByLob    map[string]apiVulnCount `json:"vulns_by_team"`
+	AssessByLob  map[string]int          `json:"assessed_by_team"`
+	CritAppCount int                     `json:"apps_with_critical"`
+	PercntCrit   float64                 `json:"percent_critical"`
+	HighAppCount int                     `json:"apps_with_high"`
+	PercntHigh   float64                 `json:"percent_high"`
+	CritApps     apiAppPage              `json:"critical_apps"`
+	HighApps     apiAppPage              `json:"high_apps"`
+	BestApps     []apiApp                `json:"best_apps"`
+	WorstApps    []apiApp                `json:"worst_apps"`
+	ToolUsage    []apiCount              `json:"tool_usage"`
+	TopCWE       []apiCount              `json:"top_cwe"`
+	CWEBySev     map[string][]apiCount   `json:"top_cwe_by_severity"`
+	WeightedCWE  []apiCount              `json:"weighted_cwe"`
+	Scans        *apiScans               `json:"scans,omitempty"`
+}
+
+type apiQuarter struct {
+	Label        string                `json:"label"`
+	Months       []string              `json:"months"`
+	TotVulns     int                   `json:"total_vulns"`
+	CritAppCount int                   `json:"apps_with_critical"`
+	PercntCrit   float64               `json:"percent_critical"`
+	HighAppCount int                   `json:"apps_with_high"`
+	PercntHigh   float64               `json:"percent_high"`
+	CritApps     apiAppPage            `json:"critical_apps"`
+	HighApps     apiAppPage            `json:"high_apps"`
+	BestApps     []apiApp              `json:"best_apps"`
+	WorstApps    []apiApp              `json:"worst_apps"`
+	ToolUsage    []apiCount            `json:"tool_usage"`
+	TopCWE       []apiCount            `json:"top_cwe"`
+	CWEBySev     map[string][]apiCount `json:"top_cwe_by_severity"`
+	WeightedCWE  []apiCount            `json:"weighted_cwe"`
+	Scans        *apiScans             `json:"scans,omitempty"`
+}
+
+type apiYear struct {
+	Year         int                   `json:"year"`
+	YearEnds     string                `json:"year_ends"`
+	Quarters     []string              `json:"quarters"`
+	TotVulns     int                   `json:"total_vulns"`
+	CritAppCount int                   `json:"apps_with_critical"`
+	PercntCrit   float64               `json:"percent_critical"`
+	HighAppCount int                   `json:"apps_with_high"`
+	PercntHigh   float64               `json:"percent_high"`
+	CritApps     apiAppPage            `json:"critical_apps"`
+	HighApps     apiAppPage            `json:"high_apps"`
+	BestApps     []apiApp              `json:"best_apps"`
+	WorstApps    []apiApp              `json:"worst_apps"`
+	ToolUsage    []apiCount            `json:"tool_usage"`
+	TopCWE       []apiCount            `json:"top_cwe"`
+	CWEBySev     map[string][]apiCount `json:"top_cwe_by_severity"`
+	WeightedCWE  []apiCount            `json:"weighted_cwe"`
+	Scans        *apiScans             `json:"scans,omitempty"`
+}
+
+type apiTeam struct {
+	Name        string       `json:"name"`
+	Month       string       `json:"month"`
+	AppCount    int          `json:"app_count"`
+	CritFinding int          `json:"critical_findings"`
+	Assessed    int          `json:"assessed"`
+	Vulns       apiVulnCount `json:"vulns"`
+	Apps        apiAppPage   `json:"apps"`
+}
+
+func apiVulns(v VulnCount) apiVulnCount {
+	return apiVulnCount{v.crit, v.high, v.med, v.low}
+}
+
+func apiCounts(m map[string]int, max int) []apiCount {
+	c := []apiCount{}
+	for _, r := range countRows(m, max) {
+		c = append(c, apiCount{r.Name, r.Count})
+	}
+
+	return c
+}
+
+// Scans and uploads, only there with scan history
+type apiScans struct {
+	Total  int            `json:"total"`
+	Clean  int            `json:"clean"`
+	ByTool map[string]int `json:"by_scanner"`
+	ByTeam map[string]int `json:"by_team"`
+}
+
+func apiScanCounts(tot int, clean int, byTool map[string]int, byLob map[string]int) *apiScans {
+	if byTool == nil {
+		return nil
+	}
+
+	return &apiScans{Total: tot, Clean: clean, ByTool: byTool, ByTeam: byLob}
+}
+
+// Top 10 CWEs keyed by severity name
+func apiCWESev(bySev map[int]map[string]int) map[string][]apiCount {
+	c := make(map[string][]apiCount)
+	for sev, m := range bySev {
+		if len(m) > 0 {
+			c[strings.ToLower(sevNames[sev])] = apiCounts(m, 10)
+		}
+	}
+
+	return c
+}
+
+func apiScores(scores map[string]int, cnts map[string]VulnCount, ascending bool) []apiApp {
+	a := []apiApp{}
+	for _, r := range scoreRows(scores, cnts, ascending) {
+		score := r.Score
+		app := apiApp{Name: r.Name, Score: &score}
+		if cnts != nil {
+			v := apiVulns(cnts[r.Name])
+			app.Vulns = &v
+		}
+		a = append(a, app)
+	}
+
+	return a
+}
+
+// Apps and their counts, most first, cut down to the page asked for
+func apiPage(r *http.Request, m map[string]int) (apiAppPage, error) {
+	var apps []apiApp
+	for _, c := range countRows(m, 0) {
+		apps = append(apps, apiApp{Name: c.Name, Count: c.Count})
+	}
+
+	return pageApps(r, apps)
+}
+
+func pageApps(r *http.Request, apps []apiApp) (apiAppPage, error) {
+	p := apiAppPage{Total: len(apps), Page: 1, PerPage: defaultPerPage}
+	if v := r.FormValue("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("page must be a number from 1 up not %v", v)
+		}
+		p.Page = n
+	}
+	if v := r.FormValue("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPerPage {
+			return p, fmt.Errorf("per_page must be a number from 1 to %v not %v", maxPerPage, v)
+		}
+		p.PerPage = n
+	}
+
+	start := (p.Page - 1) * p.PerPage
+	end := start + p.PerPage
+	if start > len(apps) {
+		start = len(apps)
+	}
+	if end < len(apps) {
+		p.NextPage = p.Page + 1
+	} else {
+		end = len(apps)
+	}
+	p.Items = append([]apiApp{}, apps[start:end]...)
+
+	return p, nil
+}
+
+///////////////////////////////////////
+// Handlers                          //
+///////////////////////////////////////
+
+func (s *server) apiMonths(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	months := []string{}
+	for _, m := range snap.months {
+		months = append(months, m.tStamp.Format(monthKey))
+	}
+	apiWrite(w, r, months)
+}
+
+func (s *server) apiMonth(w http.ResponseWriter, r *http.Request, want string) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	for _, m := range snap.months {
+		if m.tStamp.Format(monthKey) != want {
+			continue
+		}
+		d := apiMonth{
+			Month:        want,
+			Quarter:      m.quarter,
+			Partial:      m.mpartial,
+			TotVulns:     m.totVulns,
+			TotAssess:    m.totAssess,
+			VulnByLob:    make(map[string]apiVulnCount),
+			AssessByLob:  m.assessByLob,
+			CritAppCount: len(m.critApps),
+			PercntCrit:   m.percntCrit,
+			HighAppCount: len(m.highApps),
+			PercntHigh:   m.percntHigh,
+			BestApps:     apiScores(m.bestApps, m.bAppsCnt, true),
+			WorstApps:    apiScores(m.worstApps, m.wAppsCnt, false),
+			ToolUsage:    apiCounts(m.toolUsage, 0),
+			TopCWE:       apiCounts(m.topCWE, 10),
+			CWEBySev:     apiCWESev(m.cweBySev),
+			WeightedCWE:  apiCounts(m.cweScore, 10),
+			Scans:        apiScanCounts(m.totScans, m.cleanScans, m.scansByTool, m.scansByLob),
+		}
+		for k, v := range m.vulnByLob {
+			d.VulnByLob[k] = apiVulns(v)
+		}
+		var err error
+		if d.CritApps, err = apiPage(r, m.critApps); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if d.HighApps, err = apiPage(r, m.highApps); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		apiWrite(w, r, d)
+		return
+	}
+	apiError(w, http.StatusNotFound, "No metrics for month "+want+", see /api/v1/months")
+}
+
+// The quarters in a snapshot, newest first, the first up to the latest month
+func (snap *snapshot) quarters() [4]*tfQuarter {
+	var y tfYear
+	rollYear(&y, snap.apps, snap.months)
+
+	return y.quarters
+}
+
+func (s *server) apiQuarters(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	labels := []string{}
+	for _, q := range snap.quarters() {
+		labels = append(labels, q.qLabel)
+	}
+	apiWrite(w, r, labels)
+}
+
+func (s *server) apiQuarter(w http.ResponseWriter, r *http.Request, want string) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	for _, q := range snap.quarters() {
+		if q.qLabel != want {
+			continue
+		}
+		d := apiQuarter{
+			Label:        q.qLabel,
+			TotVulns:     q.totVulns,
+			CritAppCount: len(q.critApps),
+			PercntCrit:   q.percntCrit,
+			HighAppCount: len(q.highApps),
+			PercntHigh:   q.percntHigh,
+			BestApps:     apiScores(q.bestApps, nil, true),
+			WorstApps:    apiScores(q.worstApps, nil, false),
+			ToolUsage:    apiCounts(q.toolUsage, 0),
+			TopCWE:       apiCounts(q.topCWE, 10),
+			CWEBySev:     apiCWESev(q.cweBySev),
+			WeightedCWE:  apiCounts(q.cweScore, 10),
+			Scans:        apiScanCounts(q.totScans, q.cleanScans, q.scansByTool, q.scansByLob),
+		}
+		for _, m := range q.months {
+			// Leaving out months of the quarter still to come
+			if !m.tStamp.After(snap.months[0].tStamp) {
+				d.Months = append(d.Months, m.tStamp.Format(monthKey))
+			}
+		}
+		var err error
+		if d.CritApps, err = apiPage(r, q.critApps); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if d.HighApps, err = apiPage(r, q.highApps); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		apiWrite(w, r, d)
+		return
+	}
+	apiError(w, http.StatusNotFound, "No metrics for quarter "+want+", see /api/v1/quarters")
+}
+
+// The fiscal years the snapshot has every month of, newest first, with the
+// index of each year's last month in snap.months
+func (snap *snapshot) years() ([]int, map[int]int) {
+	years := []int{}
+	last := make(map[int]int)
+	for i, m := range snap.months {
+		if m.mpartial || int(m.tStamp.Month()) != quarterEnd[4] || i+12 > len(snap.months) {
+			continue
+		}
+		fy := fiscalYear(m.tStamp.Month(), m.tStamp.Year())
+		years = append(years, fy)
+		last[fy] = i
+	}
+
+	return years, last
+}
+
+func (s *server) apiYears(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	years, _ := snap.years()
+	apiWrite(w, r, years)
+}
+
+func (s *server) apiYear(w http.ResponseWriter, r *http.Request, year string) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	_, last := snap.years()
+	fy, err := strconv.Atoi(year)
+	i, ok := last[fy]
+	if err != nil || !ok {
+		apiError(w, http.StatusNotFound, "No metrics for fiscal year "+year+", see /api/v1/years")
+		return
+	}
+	var y tfYear
+	rollYear(&y, snap.apps, snap.months[i:])
+
+	d := apiYear{
+		Year:         fy,
+		YearEnds:     y.yearEnds,
+		Quarters:     y.qLabels[:],
+		TotVulns:     y.totVulns,
+		CritAppCount: len(y.critApps),
+		PercntCrit:   y.percntCrit,
+		HighAppCount: len(y.highApps),
+		PercntHigh:   y.percntHigh,
+		BestApps:     apiScores(y.bestApps, nil, true),
+		WorstApps:    apiScores(y.worstApps, nil, false),
+		ToolUsage:    apiCounts(y.toolUsage, 0),
+		TopCWE:       apiCounts(y.topCWE, 10),
+		CWEBySev:     apiCWESev(y.cweBySev),
+		WeightedCWE:  apiCounts(y.cweScore, 10),
+		Scans:        apiScanCounts(y.totScans, y.cleanScans, y.scansByTool, y.scansByLob),
+	}
+	if d.CritApps, err = apiPage(r, y.critApps); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if d.HighApps, err = apiPage(r, y.highApps); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	apiWrite(w, r, d)
+}
+
+func (s *server) apiTeams(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	teams := []apiCount{}
+	for _, t := range sortedKeys(snap.teamCounts) {
+		teams = append(teams, apiCount{t, snap.teamCounts[t]})
+	}
+	apiWrite(w, r, teams)
+}
+
+// A team's numbers for the latest month or the month sent with ?month=yyyy-mm
+func (s *server) apiTeam(w http.ResponseWriter, r *http.Request, name string) {
+	snap, ok := s.apiSnapshot(w)
+	if !ok {
+		return
+	}
+	team := name
+	if _, ok := snap.teamCounts[team]; !ok {
+		apiError(w, http.StatusNotFound, "No LoB/Team named "+team+", see /api/v1/teams")
+		return
+	}
+	m := snap.months[0]
+	if want := r.FormValue("month"); want != "" {
+		m = nil
+		for _, sm := range snap.months {
+			if sm.tStamp.Format(monthKey) == want {
+				m = sm
+			}
+		}
+		if m == nil {
+			apiError(w, http.StatusNotFound, "No metrics for month "+want+", see /api/v1/months")
+			return
+		}
+	}
+
+	srch := teamSearch(m.search, team)
+	scores := appScores(srch)
+	cnts := appVulnCounts(srch, appFindings(srch))
+	found := appFindings(srch)
+	var rows []dashRow
+	for _, a := range snap.teamApps[team] {
+		rows = append(rows, dashRow{Name: a, Score: scores[a]})
+	}
+	sortRows(rows)
+	var apps []apiApp
+	for _, row := range rows {
+		score := row.Score
+		v := apiVulns(cnts[row.Name])
+		apps = append(apps, apiApp{Name: row.Name, Score: &score, Vulns: &v, Findings: found[row.Name]})
+	}
+
+	d := apiTeam{
+		Name:        team,
+		Month:       m.tStamp.Format(monthKey),
+		AppCount:    snap.teamCounts[team],
+		CritFinding: snap.critsByLob[team],
+		Assessed:    m.assessByLob[team],
+		Vulns:       apiVulns(m.vulnByLob[team]),
+	}
+	var err error
+	if d.Apps, err = pageApps(r, apps); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	apiWrite(w, r, d)
+}
+
+///////////////////////////////////////
+// Helpers                           //
+///////////////////////////////////////
+
+func (s *server) apiSnapshot(w http.ResponseWriter) (*snapshot, bool) {
+	snap := s.current()
+	if snap == nil {
+		apiError(w, http.StatusServiceUnavailable, "Metrics have not been gathered from ThreadFix yet, try again shortly")
+		return nil, false
+	}
+
+	return snap, true
+}
+
+// Write v as JSON with an ETag so clients can skip unchanged responses
+func apiWrite(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	etag := fmt.Sprintf("\"%x\"", sha256.Sum256(b.Bytes()))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if t = strings.TrimSpace(t); t == etag || t == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b.Bytes())
+}
+
+func apiError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{msg})
+}
@@ -0,0 +1,180 @@
+// api_test.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func apiGet(t *testing.T, s *server, path string, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.apiRoute(w, httptest.NewRequest("GET", path, nil))
+	if w.Code == http.StatusOK {
+		err := json.Unmarshal(w.Body.Bytes(), v)
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+	}
+
+	return w.Code
+}
+
+func TestAPIQuarterMonths(t *testing.T) {
+	s := &server{snap: fakeSnap(mustDay(t, "2026-10-19"))}
+
+	var labels []string
+	apiGet(t, s, "/api/v1/quarters", &labels)
+	want := []string{"Q4-2026", "Q3-2026", "Q2-2026", "Q1-2026"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Fatalf("quarters are %v, want %v", labels, want)
+	}
+
+	tests := []struct {
+		label  string
+		months []string
+		vulns  int
+	}{
+		{"Q4-2026", []string{"2026-10"}, 6},
+		{"Q3-2026", []string{"2026-09", "2026-08", "2026-07"}, 18},
+		{"Q2-2026", []string{"2026-06", "2026-05", "2026-04"}, 18},
+		{"Q1-2026", []string{"2026-03", "2026-02", "2026-01"}, 18},
+	}
+	for _, tt := range tests {
+		var q apiQuarter
+		code := apiGet(t, s, "/api/v1/quarters/"+tt.label, &q)
+		if code != http.StatusOK {
+			t.Errorf("%v: status %v", tt.label, code)
+			continue
+		}
+		if !reflect.DeepEqual(q.Months, tt.months) || q.TotVulns != tt.vulns {
+			t.Errorf("%v covers %v with %v findings, want %v with %v", tt.label, q.Months, q.TotVulns, tt.months, tt.vulns)
+		}
+	}
+
+	if code := apiGet(t, s, "/api/v1/quarters/Q4-2025", &apiQuarter{}); code != http.StatusNotFound {
+		t.Errorf("Q4-2025 status %v, want %v", code, http.StatusNotFound)
+	}
+}
+
+func TestAPIYears(t *testing.T) {
+	defer func(d map[int]string, e map[int]int) { qtrDefs, quarterEnd = d, e }(qtrDefs, quarterEnd)
+	tests := []struct {
+		quarters []string
+		end      string
+		years    []int
+		year     int
+		labels   []string
+	}{
+		// November 2024 to October 2026 is held, all of 2025 but not 2024 or 2026
+		{nil, "2026-10-19", []int{2025}, 2025, []string{"Q4-2025", "Q3-2025", "Q2-2025", "Q1-2025"}},
+		{nil, "2026-12-31", []int{2026, 2025}, 2026, []string{"Q4-2026", "Q3-2026", "Q2-2026", "Q1-2026"}},
+		// Fiscal 2026 runs from February 2025 to January 2026
+		{wrappingQuarters, "2026-10-19", []int{2026}, 2026, []string{"Q4-2026", "Q3-2026", "Q2-2026", "Q1-2026"}},
+	}
+	for _, tt := range tests {
+		useQuarters(t, tt.quarters)
+		s := &server{snap: fakeSnap(mustDay(t, tt.end))}
+		var years []int
+		apiGet(t, s, "/api/v1/years", &years)
+		if !reflect.DeepEqual(years, tt.years) {
+			t.Errorf("%v: years are %v, want %v", tt.end, years, tt.years)
+		}
+		var y apiYear
+		code := apiGet(t, s, "/api/v1/years/"+strconv.Itoa(tt.year), &y)
+		if code != http.StatusOK || y.Year != tt.year || !reflect.DeepEqual(y.Quarters, tt.labels) || y.TotVulns != 72 {
+			t.Errorf("%v: %v status %v covers %v with %v findings, want %v with 72",
+				tt.end, tt.year, code, y.Quarters, y.TotVulns, tt.labels)
+		}
+		for _, bad := range []string{strconv.Itoa(years[len(years)-1] - 1), strconv.Itoa(years[0] + 1), "twenty"} {
+			if code := apiGet(t, s, "/api/v1/years/"+bad, &apiYear{}); code != http.StatusNotFound {
+				t.Errorf("%v: %v status %v, want %v", tt.end, bad, code, http.StatusNotFound)
+			}
+		}
+	}
+}
+
+func TestAPIPage(t *testing.T) {
+	counts := map[string]int{"pay-web": 9, "shop": 4, "cart": 4, "pay-api": 7, "pay-x": 1}
+	tests := []struct {
+		query string
+		page  int
+		per   int
+		names string
+		next  int
+		bad   bool
+	}{
+		{"", 1, defaultPerPage, "pay-web pay-api cart shop pay-x", 0, false},
+		{"per_page=2", 1, 2, "pay-web pay-api", 2, false},
+		{"page=2&per_page=2", 2, 2, "cart shop", 3, false},
+		{"page=3&per_page=2", 3, 2, "pay-x", 0, false},
+		{"page=2&per_page=5", 2, 5, "", 0, false},
+		{"page=9&per_page=2", 9, 2, "", 0, false},
+		{"page=0", 0, 0, "", 0, true},
+		{"page=two", 0, 0, "", 0, true},
+		{"per_page=0", 0, 0, "", 0, true},
+		{"per_page=1001", 0, 0, "", 0, true},
+	}
+	for _, tt := range tests {
+		p, err := apiPage(httptest.NewRequest("GET", "/api/v1/months/2026-10?"+tt.query, nil), counts)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%q: no error", tt.query)
+			}
+			continue
+		}
+		var names []string
+		for _, a := range p.Items {
+			names = append(names, a.Name)
+		}
+		if err != nil || p.Total != len(counts) || p.Page != tt.page || p.PerPage != tt.per ||
+			strings.Join(names, " ") != tt.names || p.NextPage != tt.next {
+			t.Errorf("%q: got %+v %v", tt.query, p, err)
+		}
+	}
+}
+
+func TestAPIWriteETag(t *testing.T) {
+	first := httptest.NewRecorder()
+	apiWrite(first, httptest.NewRequest("GET", "/api/v1/teams", nil), []string{"Pay", "Retail & Co"})
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || !strings.Contains(first.Body.String(), "Retail \\u0026 Co") {
+		t.Fatalf("got %v with ETag %q and %q", first.Code, etag, first.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		match  string
+		v      interface{}
+		status int
+	}{
+		{"unchanged", etag, []string{"Pay", "Retail & Co"}, http.StatusNotModified},
+		{"one of several", `"abc", ` + etag, []string{"Pay", "Retail & Co"}, http.StatusNotModified},
+		{"anything", "*", []string{"Pay", "Retail & Co"}, http.StatusNotModified},
+		{"changed", etag, []string{"Pay"}, http.StatusOK},
+		{"other tag", `"abc"`, []string{"Pay", "Retail & Co"}, http.StatusOK},
+		{"no tag", "", []string{"Pay", "Retail & Co"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/teams", nil)
+		if tt.match != "" {
+			r.Header.Set("If-None-Match", tt.match)
+		}
+		apiWrite(w, r, tt.v)
+		if w.Code != tt.status {
+			t.Errorf("%v: status %v want %v", tt.name, w.Code, tt.status)
+		}
+		if tt.status == http.StatusNotModified && w.Body.Len() > 0 {
+			t.Errorf("%v: not modified with a body %q", tt.name, w.Body.String())
+		}
+		if w.Header().Get("ETag") == "" {
+			t.Errorf("%v: no ETag", tt.name)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// categories.go
+// CWEs grouped into OWASP Top 10, CWE Top 25 and custom categories
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// The bundled mapping of CWEs to categories, see the file for its format
+//
+//go:embed cwe-categories.toml
+var bundledCategories string
+
+// Findings with a CWE that isn't in any of a scheme's categories
+const otherCategory = "Other"
+
+// A way of grouping CWEs e.g. owasp-2021, maps a CWE ID to its categories
+type cweScheme map[int][]string
+
+// Schemes by name, the bundled ones and any from categories.file
+var cweSchemes = bundledSchemes()
+
+// Schemes reported on, in order
+var reportSchemes = []string{"owasp-2021", "owasp-2017", "cwe-top25"}
+
+// Names shown in reports for the bundled schemes, others are shown as named
+var schemeTitles = map[string]string{
+	"owasp-2021": "OWASP Top 10 2021",
+	"owasp-2017": "OWASP Top 10 2017",
+	"cwe-top25":  "CWE Top 25",
+}
+
+func bundledSchemes() map[string]cweScheme {
+	s, err := parseCategories("cwe-categories.toml", bundledCategories)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// Read schemes from a file in the same format as cwe-categories.toml, a
+// [scheme] header followed by "category" = [CWE IDs] lines
+func parseCategories(name string, text string) (map[string]cweScheme, error) {
+	vals, err := parseTOML(name, strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	schemes := make(map[string]cweScheme)
+	for _, k := range sortedKeys(vals) {
+		scheme, cat, ok := strings.Cut(k, ".")
+		if !ok {
+			return nil, fmt.Errorf("%v: category %v must be under a [scheme] header", name, k)
+		}
+		if schemes[scheme] == nil {
+			schemes[scheme] = make(cweScheme)
+		}
+		for _, id := range splitList(vals[k].s) {
+			n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(id), "CWE-"))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%v: %v has %v, CWEs look like 79 or CWE-79", name, k, id)
+			}
+			schemes[scheme][n] = append(schemes[scheme][n], cat)
+		}
+	}
+
+	return schemes, nil
+}
+
+// Add the schemes in a file, replacing bundled ones with the same name.
+// Schemes that aren't bundled are reported on after the bundled ones.
+func setCategoryFile(v string) error {
+	b, err := os.ReadFile(v)
+	if err != nil {
+		return err
+	}
+	schemes, err := parseCategories(v, string(b))
+	if err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(schemes) {
+		if _, ok := cweSchemes[k]; !ok {
+			reportSchemes = append(reportSchemes, k)
+		}
+		cweSchemes[k] = schemes[k]
+	}
+
+	return nil
+}
+
+func setSchemes(v []string) error {
+	var names []string
+	for _, s := range v {
+		if _, ok := cweSchemes[s]; !ok {
+			return fmt.Errorf("schemes are %v not %v", strings.Join(sortedKeys(cweSchemes), ", "), s)
+		}
+		names = append(names, s)
+	}
+	if len(names) == 0 {
+		return errors.New("must list at least one scheme")
+	}
+	reportSchemes = names
+
+	return nil
+}
+
+func schemeTitle(name string) string {
+	if t, ok := schemeTitles[name]; ok {
+		return t
+	}
+
+	return name
+}
+
+// Findings per category of each scheme reported on, as [scheme][category]
+// vuln counts and [scheme][LoB/Team][category] number of findings. A finding
+// whose CWE is in more than one category is counted in each of them.
+func categoryCounts(srch *tf.SrchResp) (map[string]map[string]VulnCount, map[string]map[string]map[string]int) {
+	cats := make(map[string]map[string]VulnCount)
+	byLob := make(map[string]map[string]map[string]int)
+	for _, name := range reportSchemes {
+		scheme := cweSchemes[name]
+		cats[name] = make(map[string]VulnCount)
+		byLob[name] = make(map[string]map[string]int)
+		for k, _ := range srch.Results {
+			r := srch.Results[k]
+			in := scheme[r.CweVuln.Id]
+			if len(in) == 0 {
+				in = []string{otherCategory}
+			}
+			if byLob[name][r.Team.Name] == nil {
+				byLob[name][r.Team.Name] = make(map[string]int)
+			}
+			for _, c := range in {
+				sumVulns(cats[name], c, r.Severity.Value)
+				sumApps(byLob[name][r.Team.Name], c, 1)
+			}
+		}
+	}
+
+	return cats, byLob
+}
+
+// Same as sumMaps for category vuln counts
+func sumCatMaps(s ...map[string]map[string]VulnCount) map[string]map[string]VulnCount {
+	tot := make(map[string]map[string]VulnCount)
+	for _, v := range s {
+		for scheme, cats := range v {
+			if tot[scheme] == nil {
+				tot[scheme] = make(map[string]VulnCount)
+			}
+			for c, n := range cats {
+				t := tot[scheme][c]
+				tot[scheme][c] = VulnCount{t.crit + n.crit, t.high + n.high, t.med + n.med, t.low + n.low}
+			}
+		}
+	}
+
+	return tot
+}
+
+// Same as sumMaps for category counts per LoB/Team
+func sumCatLobMaps(s ...map[string]map[string]map[string]int) map[string]map[string]map[string]int {
+	tot := make(map[string]map[string]map[string]int)
+	for _, v := range s {
+		for scheme, lobs := range v {
+			if tot[scheme] == nil {
+				tot[scheme] = make(map[string]map[string]int)
+			}
+			for lob, cats := range lobs {
+				tot[scheme][lob] = sumMaps(tot[scheme][lob], cats)
+			}
+		}
+	}
+
+	return tot
+}
@@ -0,0 +1,109 @@
+// categories_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCategories(t *testing.T) {
+	schemes, err := parseCategories("cwe-categories.toml", bundledCategories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, n := range map[string]int{"owasp-2021": 10, "owasp-2017": 10, "cwe-top25": 25} {
+		cats := make(map[string]bool)
+		for _, cs := range schemes[name] {
+			for _, c := range cs {
+				cats[c] = true
+			}
+		}
+		if len(cats) != n {
+			t.Errorf("%v has %v categories want %v", name, len(cats), n)
+		}
+	}
+
+	tests := []struct {
+		scheme string
+		cwe    int
+		want   []string
+	}{
+		{"owasp-2021", 79, []string{"A03:2021-Injection"}},
+		{"owasp-2021", 22, []string{"A01:2021-Broken Access Control"}},
+		{"owasp-2021", 918, []string{"A10:2021-Server-Side Request Forgery"}},
+		{"owasp-2017", 79, []string{"A7:2017-Cross-Site Scripting (XSS)"}},
+		{"owasp-2017", 89, []string{"A1:2017-Injection"}},
+		{"cwe-top25", 89, []string{"03 SQL Injection"}},
+		{"cwe-top25", 798, []string{"22 Use of Hard-coded Credentials"}},
+		{"cwe-top25", 1, nil},
+	}
+	for _, tt := range tests {
+		if got := schemes[tt.scheme][tt.cwe]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v CWE-%v: got %v want %v", tt.scheme, tt.cwe, got, tt.want)
+		}
+	}
+}
+
+func TestParseCategoryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want cweScheme
+		bad  bool
+	}{
+		{"ids", "[mine]\nweb = [79, \"CWE-89\", \"cwe-22\"]", cweScheme{79: {"web"}, 89: {"web"}, 22: {"web"}}, false},
+		{"in two", "[mine]\nweb = [79]\nxss = [79]", cweScheme{79: {"web", "xss"}}, false},
+		{"no scheme", "web = [79]", nil, true},
+		{"not a CWE", "[mine]\nweb = [\"XSS\"]", nil, true},
+		{"zero", "[mine]\nweb = [0]", nil, true},
+		{"bad toml", "[mine]\nweb = [79", nil, true},
+	}
+	for _, tt := range tests {
+		schemes, err := parseCategories("test.toml", tt.text)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error, got %v", tt.name, schemes)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(schemes["mine"], tt.want) {
+			t.Errorf("%v: got %v %v want %v", tt.name, schemes["mine"], err, tt.want)
+		}
+	}
+}
+
+func TestCategoryCounts(t *testing.T) {
+	defer func(s map[string]cweScheme, r []string) { cweSchemes, reportSchemes = s, r }(cweSchemes, reportSchemes)
+	cweSchemes = bundledSchemes()
+	cweSchemes["mine"] = cweScheme{79: {"XSS", "Web"}, 22: {"Web"}}
+	reportSchemes = []string{"owasp-2021", "owasp-2017", "cwe-top25", "mine"}
+	cats, byLob := categoryCounts(fakeSearch())
+
+	tests := []struct {
+		scheme   string
+		category string
+		want     VulnCount
+		pay      int
+		retail   int
+	}{
+		// XSS is a critical, medium and low, SQL injection a high and path traversal a critical and a low
+		{"owasp-2021", "A03:2021-Injection", VulnCount{crit: 1, high: 1, med: 1, low: 1}, 3, 1},
+		{"owasp-2021", "A01:2021-Broken Access Control", VulnCount{crit: 1, low: 1}, 0, 2},
+		{"owasp-2017", "A7:2017-Cross-Site Scripting (XSS)", VulnCount{crit: 1, med: 1, low: 1}, 2, 1},
+		{"owasp-2017", "A1:2017-Injection", VulnCount{high: 1}, 1, 0},
+		{"cwe-top25", "05 Path Traversal", VulnCount{crit: 1, low: 1}, 0, 2},
+		// Findings in two categories count in both, those in none are Other
+		{"mine", "XSS", VulnCount{crit: 1, med: 1, low: 1}, 2, 1},
+		{"mine", "Web", VulnCount{crit: 2, med: 1, low: 2}, 2, 3},
+		{"mine", otherCategory, VulnCount{high: 1}, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := cats[tt.scheme][tt.category]; got != tt.want {
+			t.Errorf("%v %v: got %+v want %+v", tt.scheme, tt.category, got, tt.want)
+		}
+		pay, retail := byLob[tt.scheme]["Pay"][tt.category], byLob[tt.scheme]["Retail & Co"][tt.category]
+		if pay != tt.pay || retail != tt.retail {
+			t.Errorf("%v %v: got Pay %v Retail & Co %v want %v %v", tt.scheme, tt.category, pay, retail, tt.pay, tt.retail)
+		}
+	}
+}
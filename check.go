@@ -0,0 +1,344 @@
+// check.go
+// policy rules checked against open findings to gate CI pipelines
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// What a rule is checked for - every app, every LoB/Team or all of ThreadFix
+var checkScopes = []string{"app", "team", "all"}
+
+// Name the all scope is reported under
+const allSubject = "ThreadFix"
+
+// Metrics a rule can check and what they measure
+var ruleMetrics = map[string]string{
+	"crits":    "open critical findings",
+	"highs":    "open high findings",
+	"findings": "open findings",
+	"score":    "weighted score of the open findings",
+	"crit_pct": "% of apps with open critical findings",
+	"high_pct": "% of apps with open high findings",
+}
+
+// A rule broken when the metric compares true with the value, e.g.
+// app crits > 0 after 7 days
+type rule struct {
+	spec   string  // as it was set
+	scope  string  // one of checkScopes
+	metric string  // one of ruleMetrics
+	op     string  // <, <=, > or >=
+	value  float64 // what the metric is compared with
+	after  int     // only count findings open more than this many days, 0 for all
+}
+
+// Rules set in the config for the check command
+var checkRules []rule
+
+// Months back open findings are looked for, 0 for all of them however old
+var checkMonths = 0
+
+// How fetchCheckSearches searches ThreadFix, swapped out by the tests
+var checkSearch = cappedSearch
+
+// A rule and what broke it
+type ruleResult struct {
+	rule
+	values map[string]float64 // the metric for each app, LoB/Team or all of ThreadFix
+	broken []string           // what broke the rule, in name order
+}
+
+// Exit code of the check command when rules are broken
+const violationExit = 2
+
+// Read a rule like scope metric op value [after N days]
+func parseRule(spec string) (rule, error) {
+	r := rule{spec: spec}
+	rest, after, hasAfter := strings.Cut(spec, " after ")
+	f := strings.Fields(rest)
+	if len(f) != 4 {
+		return r, fmt.Errorf("rules look like app crits > 0 [after 7 days] not %v", spec)
+	}
+
+	r.scope = strings.ToLower(f[0])
+	if !oneOf(r.scope, checkScopes) {
+		return r, fmt.Errorf("rules are for %v not %v", strings.Join(checkScopes, ", "), f[0])
+	}
+	r.metric = strings.ToLower(f[1])
+	if _, ok := ruleMetrics[r.metric]; !ok {
+		return r, fmt.Errorf("rule metrics are %v not %v", strings.Join(sortedKeys(ruleMetrics), ", "), f[1])
+	}
+	if r.scope == "app" && strings.HasSuffix(r.metric, "_pct") {
+		return r, fmt.Errorf("%v is for team and all rules, not app ones", r.metric)
+	}
+	r.op = f[2]
+	if !oneOf(r.op, compareOps) {
+		return r, fmt.Errorf("rules compare with <, <=, > or >= not %v", f[2])
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(f[3], "%"), 64)
+	if err != nil || v < 0 {
+		return r, fmt.Errorf("rule values must be a number, 0 or more, not %v", f[3])
+	}
+	r.value = v
+	if hasAfter {
+		a := strings.Fields(after)
+		if len(a) > 0 {
+			r.after, err = strconv.Atoi(a[0])
+		}
+		if len(a) == 0 || len(a) > 2 || err != nil || r.after < 0 ||
+			(len(a) == 2 && !oneOf(strings.ToLower(a[1]), []string{"day", "days"})) {
+			return r, fmt.Errorf("rules end with after N days not after %v", after)
+		}
+	}
+
+	return r, nil
+}
+
+// The open findings found from checkMonths ago, or ever, until more than
+// after days ago, for each after used by the rules. Ages are to the day as
+// ThreadFix is searched by date. A search reaching maxResults is an error
+// rather than a pass on the findings left out.
+func fetchCheckSearches(today time.Time) (map[int]*tf.SrchResp, error) {
+	start := time.Unix(0, 0).UTC()
+	if checkMonths > 0 {
+		start = today.AddDate(0, -checkMonths, 0)
+	}
+	var afters []int
+	seen := make(map[int]bool)
+	for _, r := range checkRules {
+		if !seen[r.after] {
+			seen[r.after] = true
+			afters = append(afters, r.after)
+		}
+	}
+
+	searches := make([]*tf.SrchResp, len(afters))
+	err := fetchAll(len(afters), func(i int) error {
+		var search tf.SrchResp
+		searches[i] = &search
+		end := today
+		if afters[i] > 0 {
+			end = today.AddDate(0, 0, -afters[i]-1)
+		}
+		if end.Before(start) {
+			return nil
+		}
+		capped, err := checkSearch(start, end, "open", &search)
+		if err == nil && capped {
+			err = fmt.Errorf("the search for open findings from %v to %v reached threadfix.max_results (%v) so some may "+
+				"be missing, raise it or narrow the check with --include", start.Format(dayKey), end.Format(dayKey), maxResults)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byAfter := make(map[int]*tf.SrchResp)
+	for i, a := range afters {
+		byAfter[a] = searches[i]
+	}
+
+	return byAfter, nil
+}
+
+// The rule's metric for everything in its scope, including those without
+// any findings
+func ruleValues(r rule, srch *tf.SrchResp) map[string]float64 {
+	vals := make(map[string]float64)
+	switch r.scope {
+	case "app":
+		for app, _ := range appLobs {
+			vals[app] = 0
+		}
+	case "team":
+		for team, _ := range teamCounts {
+			vals[team] = 0
+		}
+	default:
+		vals[allSubject] = 0
+	}
+
+	// Apps with findings of the severity for the percentages
+	pctSev := map[string]int{"crit_pct": 5, "high_pct": 4}[r.metric]
+	apps := make(map[string]map[string]bool)
+	for k, _ := range srch.Results {
+		res := srch.Results[k]
+		subject := allSubject
+		switch r.scope {
+		case "app":
+			subject = res.Apps.Name
+		case "team":
+			subject = res.Team.Name
+		}
+		sev := res.Severity.Value
+		switch r.metric {
+		case "crits":
+			if sev == 5 {
+				vals[subject]++
+			}
+		case "highs":
+			if sev == 4 {
+				vals[subject]++
+			}
+		case "findings":
+			vals[subject]++
+		case "score":
+			vals[subject] += float64(vulnWeight[sev])
+		default:
+			if sev == pctSev {
+				if apps[subject] == nil {
+					apps[subject] = make(map[string]bool)
+				}
+				apps[subject][res.Apps.Name] = true
+			}
+		}
+	}
+	if pctSev > 0 {
+		for subject, _ := range vals {
+			n := appCount
+			if r.scope == "team" {
+				n = teamCounts[subject]
+			}
+			vals[subject] = 0
+			if n > 0 {
+				vals[subject] = float64(len(apps[subject])) / float64(n) * 100
+			}
+		}
+	}
+
+	return vals
+}
+
+// Check every rule against the searches from fetchCheckSearches
+func checkResults(searches map[int]*tf.SrchResp) []ruleResult {
+	var results []ruleResult
+	for _, r := range checkRules {
+		rr := ruleResult{rule: r, values: ruleValues(r, searches[r.after])}
+		for _, name := range sortedKeys(rr.values) {
+			if compare(rr.values[name], r.op, r.value) {
+				rr.broken = append(rr.broken, name)
+			}
+		}
+		results = append(results, rr)
+	}
+
+	return results
+}
+
+func violations(results []ruleResult) int {
+	n := 0
+	for _, rr := range results {
+		n += len(rr.broken)
+	}
+
+	return n
+}
+
+// A metric value as it's shown, to 1 decimal place for the percentages
+func (r rule) format(v float64) string {
+	if strings.HasSuffix(r.metric, "_pct") {
+		return fmt.Sprintf("%.1f%%", v)
+	}
+
+	return strconv.FormatFloat(math.Round(v), 'f', -1, 64)
+}
+
+// Why name broke the rule
+func (rr ruleResult) message(name string) string {
+	return fmt.Sprintf("%v has %v %v, breaking %v", name, rr.format(rr.values[name]), rr.metric, rr.spec)
+}
+
+///////////////////////////////////////
+// JUnit XML                         //
+///////////////////////////////////////
+
+// JUnit XML as read by CI systems - a suite for each rule and a test case for
+// each app, LoB/Team or all of ThreadFix it was checked for
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Class   string        `xml:"classname,attr"`
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+func writeJUnit(name string, results []ruleResult) error {
+	js := junitSuites{Name: "tfmetrics check"}
+	for _, rr := range results {
+		s := junitSuite{Name: rr.spec}
+		for _, subject := range sortedKeys(rr.values) {
+			c := junitCase{Class: "tfmetrics." + rr.scope, Name: subject}
+			if oneOf(subject, rr.broken) {
+				c.Failure = &junitFailure{Message: rr.message(subject), Type: "PolicyViolation"}
+				s.Failures++
+			}
+			s.Cases = append(s.Cases, c)
+		}
+		s.Tests = len(s.Cases)
+		js.Tests += s.Tests
+		js.Failures += s.Failures
+		js.Suites = append(js.Suites, s)
+	}
+
+	out, err := xml.MarshalIndent(js, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, append([]byte(xml.Header), append(out, '\n')...), 0644)
+}
+
+///////////////////////////////////////
+// Setters                           //
+///////////////////////////////////////
+
+func setCheckRules(v []string) error {
+	var rs []rule
+	for _, spec := range v {
+		r, err := parseRule(spec)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+	}
+	checkRules = rs
+
+	return nil
+}
+
+func setCheckMonths(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a whole number, 0 or more, not %v", v)
+	}
+	checkMonths = n
+
+	return nil
+}
@@ -0,0 +1,166 @@
+// check_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Stand in for ThreadFix with a critical found on found, and as many results
+// as asked for if capped
+func fakeCheckSearch(found time.Time, capped bool) func(time.Time, time.Time, string, *tf.SrchResp) (bool, error) {
+	return func(st time.Time, e time.Time, show string, srch *tf.SrchResp) (bool, error) {
+		if !found.Before(st) && !found.After(e) {
+			var r tf.Result
+			r.Team.Name = "Pay"
+			r.Apps.Name = "pay-web"
+			r.Severity.Value = 5
+			srch.Results = append(srch.Results, r)
+		}
+		return capped, nil
+	}
+}
+
+func TestCheckGate(t *testing.T) {
+	defer func(s func(time.Time, time.Time, string, *tf.SrchResp) (bool, error)) { checkSearch = s }(checkSearch)
+	appLobs = map[string]string{"pay-web": "Pay", "shop": "Retail & Co"}
+	today := mustDay(t, "2026-10-19")
+
+	tests := []struct {
+		name    string
+		found   string // when the critical was found
+		months  int
+		capped  bool
+		broken  []string
+		errText string
+	}{
+		{"critical open for years", "2023-02-01", 0, false, []string{"pay-web"}, ""},
+		{"critical open for a month", "2026-09-19", 0, false, []string{"pay-web"}, ""},
+		{"critical found this week", "2026-10-15", 0, false, nil, ""},
+		{"critical before check.months", "2023-02-01", 12, false, nil, ""},
+		{"results capped", "2026-09-19", 0, true, nil, "max_results"},
+	}
+	for _, tt := range tests {
+		r, err := parseRule("app crits > 0 after 7 days")
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRules = []rule{r}
+		checkMonths = tt.months
+		checkSearch = fakeCheckSearch(mustDay(t, tt.found), tt.capped)
+
+		searches, err := fetchCheckSearches(today)
+		if tt.errText != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("%v: error %v, want one about %v", tt.name, err, tt.errText)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		results := checkResults(searches)
+		if got := results[0].broken; strings.Join(got, ",") != strings.Join(tt.broken, ",") {
+			t.Errorf("%v: broken by %v, want %v", tt.name, got, tt.broken)
+		}
+	}
+	checkMonths = 0
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec string
+		want rule
+		bad  bool
+	}{
+		{"app crits > 0", rule{scope: "app", metric: "crits", op: ">", value: 0}, false},
+		{"Team HIGH_PCT >= 20%", rule{scope: "team", metric: "high_pct", op: ">=", value: 20}, false},
+		{"all findings < 1000 after 30 days", rule{scope: "all", metric: "findings", op: "<", value: 1000, after: 30}, false},
+		{"app score <= 50 after 1 day", rule{scope: "app", metric: "score", op: "<=", value: 50, after: 1}, false},
+		{"app crits > 0 after 7", rule{scope: "app", metric: "crits", op: ">", value: 0, after: 7}, false},
+		{"app crits > 0 extra", rule{}, true},
+		{"lob crits > 0", rule{}, true},
+		{"app bugs > 0", rule{}, true},
+		{"app crit_pct > 5", rule{}, true},
+		{"app crits = 0", rule{}, true},
+		{"app crits > -1", rule{}, true},
+		{"app crits > none", rule{}, true},
+		{"app crits > 0 after a week", rule{}, true},
+		{"app crits > 0 after 7 weeks", rule{}, true},
+		{"app crits > 0 after", rule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRule(tt.spec)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error", tt.spec)
+			}
+			continue
+		}
+		tt.want.spec = tt.spec
+		if err != nil || got != tt.want {
+			t.Errorf("%v: got %+v %v want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	rule := func(spec string) rule {
+		r, err := parseRule(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		results []ruleResult
+		want    string
+	}{
+		{"nothing checked", nil, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="tfmetrics check" tests="0" failures="0"></testsuites>
+`},
+		{"passes and failures", []ruleResult{
+			{rule("app crits > 0 after 7 days"), map[string]float64{"shop": 0, "pay-web": 2}, []string{"pay-web"}},
+			{rule("team crit_pct > 20"), map[string]float64{"Retail & Co": 12.5, "Pay": 50}, []string{"Pay"}},
+			{rule("all findings >= 1000"), map[string]float64{"ThreadFix": 6}, nil},
+		}, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="tfmetrics check" tests="5" failures="2">
+  <testsuite name="app crits &gt; 0 after 7 days" tests="2" failures="1">
+    <testcase classname="tfmetrics.app" name="pay-web">
+      <failure message="pay-web has 2 crits, breaking app crits &gt; 0 after 7 days" type="PolicyViolation"></failure>
+    </testcase>
+    <testcase classname="tfmetrics.app" name="shop"></testcase>
+  </testsuite>
+  <testsuite name="team crit_pct &gt; 20" tests="2" failures="1">
+    <testcase classname="tfmetrics.team" name="Pay">
+      <failure message="Pay has 50.0% crit_pct, breaking team crit_pct &gt; 20" type="PolicyViolation"></failure>
+    </testcase>
+    <testcase classname="tfmetrics.team" name="Retail &amp; Co"></testcase>
+  </testsuite>
+  <testsuite name="all findings &gt;= 1000" tests="1" failures="0">
+    <testcase classname="tfmetrics.all" name="ThreadFix"></testcase>
+  </testsuite>
+</testsuites>
+`},
+	}
+	for _, tt := range tests {
+		name := filepath.Join(t.TempDir(), "junit.xml")
+		if err := writeJUnit(name, tt.results); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%v: got\n%v\nwant\n%v", tt.name, string(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,1069 @@
+// cli.go
+// tfmetrics sub-commands and their flags
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+type command struct {
+	name  string
+	short string // one line description for the command list
+	help  string // longer description shown by help <command>
+	run   func(fs *flag.FlagSet, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Set here rather than in the var to avoid an initialization loop with help
+	commands = []command{
+		{"report", "Full report - summary, last 3 months, quarter and LoB CSV (the default)",
+			"Prints the summary, the month and the 2 before it, the quarter and the\nLoB Crit/High CSV. Optionally emails it and writes a report per LoB/Team.",
+			runReport},
+		{"summary", "Apps and LoB/Teams in ThreadFix",
+			"Counts of apps per LoB/Team and the LoB/Teams with critical findings.",
+			runSummary},
+		{"month", "Metrics for a single month",
+			"Metrics for one month, by default the current month or, up to the\ncalendar.month_cutoff day (the 15th), the previous one,\nand spikes or drops in its findings per LoB/Team and scanner.",
+			runMonth},
+		{"quarter", "Metrics for a quarter",
+			"Metrics for a quarter e.g. --label Q3-2026, by default the current one, and\na forecast for the quarter after it.",
+			runQuarter},
+		{"year", "Metrics for the quarter a month is in and the 3 before it",
+			"Metrics for the year - 4 quarters - up to --ending yyyy-mm,\nby default the current month.",
+			runYear},
+		{"trend", "Month by month trend, the last 12 months by default",
+			"Totals, apps with criticals and highs and their percentages and each\nLoB/Team's critical and high findings for each of the last --months months\nending with --ending.",
+			runTrend},
+		{"teams", "Numbers for each LoB/Team for a month",
+			"Apps, critical findings and the month's vulnerability counts for every LoB/Team.",
+			runTeams},
+		{"apps", "Score and vulnerability counts for each app for a month",
+			"Every app's score and vulnerability counts for a month, worst first.\nUse --team to only list one LoB/Team's apps.",
+			runApps},
+		{"scanners", "Scanner overlap, severity mix and false positive rates",
+			"For each scanner, the open findings it reported alone or along with other\nscanners, their severities and the share of its findings marked as false\npositives, over the --months months ending with --month.",
+			runScanners},
+		{"risk", "Scores weighted by app business criticality for a month",
+			"App scores weighted by business criticality tier, the share of each tier's\napps with critical and high findings and each LoB/Team's risk exposure.\nTiers come from criticality.file or, with threadfix.url and\nthreadfix.api_key set, ThreadFix's app criticality.",
+			runRisk},
+		{"scorecards", "Graded scorecards and a leaderboard for each LoB/Team",
+			"Grades each LoB/Team on its open critical and high findings per app, SLA\ncompliance, scan coverage (with scan history) and trend over the quarter\n--label, by default the current one, then ranks them and explains each grade.",
+			runScorecards},
+		{"coverage", "Apps not scanned recently and coverage per LoB/Team",
+			"Lists the apps without a scan in the days allowed for their ThreadFix\ncriticality (the coverage settings, or --days for every app), the share of\neach LoB/Team's apps scanned in time and the apps never scanned by a SAST,\nDAST or SCA tool. Needs threadfix.url and threadfix.api_key for scan history.",
+			runCoverage},
+		{"check", "Check policy rules for CI pipelines, failing if any are broken",
+			"Checks the check.rules against the open findings of the last --months\nmonths, e.g. app crits > 0 after 7 days or team high_pct > 20, and lists\nwhat broke them. Exits with 2 if any rule is broken and 1 for errors.\nUse --junit to also write the results as JUnit XML.",
+			runCheck},
+		{"weeks", "Metrics for ISO weeks, rolled up into months",
+			"Metrics for each of the last --weeks ISO weeks, Monday to Sunday, ending\nwith the week --ending is in, and those weeks rolled up into months.",
+			runWeeks},
+		{"range", "Metrics for any range of dates",
+			"Metrics from --from to --to, both included, calculated the same way as a\nmonth's. Use --by to also show the range split by week, month or quarter.",
+			runRange},
+		{"export", "Write the full report as text, HTML, CSV and JSON files",
+			"Writes the full report to --dir as .txt, .html, .csv and .json files and\nwith --per-team a report for each LoB/Team as well.",
+			runExport},
+		{"serve", "Dashboard, JSON API and Prometheus metrics",
+			"Runs until stopped, refreshing metrics from ThreadFix every --refresh and\nserving a dashboard at /, a JSON API under /api/v1 and Prometheus metrics\nat /metrics.",
+			runServe},
+		{"help", "Help for a command",
+			"Shows the commands or the help and flags for one command.",
+			runHelp},
+	}
+}
+
+// Run the command named in args[0] with the rest of args, report if none is given
+func runCommand(args []string) error {
+	// A config file can be given before the command
+	config := ""
+	if len(args) > 1 && (args[0] == "--config" || args[0] == "-config") {
+		config, args = args[1], args[2:]
+	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--config=") || strings.HasPrefix(args[0], "-config=")) {
+		_, config, _ = strings.Cut(args[0], "=")
+		args = args[1:]
+	}
+	err := loadConfig(config)
+	if err != nil {
+		return err
+	}
+
+	name := "report"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	c, ok := findCommand(name)
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("Unknown command %v\n", name)
+	}
+
+	err = c.run(newFlagSet(c), args)
+	if err == flag.ErrHelp {
+		return nil
+	}
+
+	return err
+}
+
+// An error a command exits with code for rather than 1
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e exitError) Error() string {
+	return e.msg
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return command{}, false
+}
+
+func newFlagSet(c command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: tfmetrics %v [flags]\n\n%v\n", c.name, c.help)
+		n := 0
+		fs.VisitAll(func(*flag.Flag) { n++ })
+		if n > 0 {
+			fmt.Fprintln(w, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+
+	return fs
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: tfmetrics [--config file] [command] [flags]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8v %v\n", c.name, c.short)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Use tfmetrics help <command> for a command's flags. Settings are read from")
+	fmt.Fprintf(w, "--config, TFM_CONFIG or %v if it exists and TFM_* environment variables.\n", defaultConfig)
+}
+
+func runHelp(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		usage(os.Stdout)
+		return nil
+	}
+	c, ok := findCommand(fs.Arg(0))
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("Unknown command %v\n", fs.Arg(0))
+	}
+	fs = newFlagSet(c)
+	fs.SetOutput(os.Stdout)
+
+	return c.run(fs, []string{"-h"})
+}
+
+///////////////////////////////////////
+// Flags shared by commands          //
+///////////////////////////////////////
+
+// Where and how a command's report is written
+type output struct {
+	format string
+	out    string
+	email  bool
+	mail   *mailConfig
+}
+
+var formats = map[string]func(io.Writer, *report) error{
+	"text": func(w io.Writer, r *report) error {
+		writeText(w, r)
+		return nil
+	},
+	"html": writeHTML,
+	"csv":  writeCSV,
+	"json": writeJSON,
+}
+
+func outputFlags(fs *flag.FlagSet) *output {
+	o := &output{}
+	fs.StringVar(&o.format, "format", defaultFormat, "output format: text, html, csv or json")
+	fs.StringVar(&o.out, "out", getenv("TFM_OUT"), "file to write the report to instead of the screen")
+	// Email if it's been set up unless told otherwise
+	email := getenv("TFM_SMTP_HOST") != ""
+	if e := getenv("TFM_EMAIL"); e != "" {
+		email, _ = strconv.ParseBool(e)
+	}
+	fs.BoolVar(&o.email, "email", email, "email the report using the email settings")
+	addFilterFlags(fs)
+
+	return o
+}
+
+// Parse args and check the output flags before any metrics are gathered
+func (o *output) parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("Unexpected arguments: %v\n", strings.Join(fs.Args(), " "))
+	}
+	if _, ok := formats[o.format]; !ok {
+		return fmt.Errorf("Unknown format %v, use text, html, csv or json\n", o.format)
+	}
+	err = findingFilter.check()
+	if err != nil {
+		return err
+	}
+	if o.email {
+		o.mail, err = mailFromEnv()
+		if err != nil {
+			return err
+		}
+		if o.mail == nil {
+			return errors.New("Set the email host and the other email settings to email the report\n")
+		}
+	}
+
+	return nil
+}
+
+func (o *output) write(r *report) error {
+	var w io.Writer = os.Stdout
+	if o.out != "" {
+		f, err := os.Create(o.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	err := formats[o.format](w, r)
+	if err != nil {
+		return err
+	}
+
+	if o.mail != nil {
+		if to := o.mail.recipients(); len(to) > 0 {
+			fmt.Fprintf(os.Stderr, "Emailing report to %v\n", strings.Join(to, ", "))
+			return mailReport(o.mail, to, r)
+		}
+	}
+
+	return nil
+}
+
+func monthFlag(fs *flag.FlagSet, name string, use string) *string {
+	return fs.String(name, "", use+" as yyyy-mm, defaults to the current month")
+}
+
+// The time months and quarters given as flags and partial weeks are worked
+// out from, swapped out by the tests
+var now = time.Now
+
+// Turn a yyyy-mm flag into the time stamp sumMonth wants - the current time for
+// the month in progress or the last day for months that are over
+func monthStamp(s string) (time.Time, error) {
+	n := now()
+	if s == "" {
+		return reportMonth(n), nil
+	}
+	t, err := time.Parse(monthKey, s)
+	if err != nil {
+		return t, fmt.Errorf("Months look like 2026-09 not %v\n", s)
+	}
+	if t.Year() == n.Year() && t.Month() == n.Month() {
+		return n, nil
+	}
+	if t.After(n) {
+		return t, fmt.Errorf("%v hasn't happened yet\n", s)
+	}
+
+	return time.Date(t.Year(), t.Month(), lastDate(int(t.Month()), t.Year()), 0, 0, 0, 0, time.UTC), nil
+}
+
+// The time stamp of the last month of a quarter label like Q3-2026, or of the
+// latest month for the quarter under way. See quarterMonths for its months.
+func quarterStamp(label string) (time.Time, error) {
+	if label == "" {
+		return reportMonth(now()), nil
+	}
+	q, y, _ := strings.Cut(label, "-")
+	year, err := strconv.Atoi(y)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Quarters look like Q3-2026 not %v\n", label)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(q, "Q"))
+	if _, ok := quarterEnd[n]; err != nil || !ok {
+		return time.Time{}, fmt.Errorf("Unknown quarter %v in %v\n", q, label)
+	}
+
+	t, err := monthStamp(quarterEndDay(n, year).Format(monthKey))
+	if err != nil {
+		// The quarter is under way, use the latest month if it's part of it
+		t = reportMonth(now())
+		if getQuarter(t.Month(), t.Year()) != label {
+			return t, fmt.Errorf("%v hasn't started yet\n", label)
+		}
+	}
+
+	return t, nil
+}
+
+// Create the ThreadFix client
+func connect() error {
+	t, err := tf.CreateClient()
+	if err != nil {
+		return err
+	}
+	tfc = t
+	if tfTimeout > 0 {
+		tfc.Timeout = tfTimeout
+	}
+
+	return nil
+}
+
+// Connect and gather the summary metrics
+func gatherSummary() (*tf.TeamResp, error) {
+	err := connect()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "Gathering summary metrics...")
+	var teams tf.TeamResp
+	err = fetchTeams(tfc, &teams)
+	if err != nil {
+		return nil, err
+	}
+	createSummary(&teams)
+	resolveAppSizes(&teams)
+	resolveAppTiers(&teams)
+	if scansWanted() {
+		fmt.Fprintln(os.Stderr, "Gathering scan history...")
+		scanHistory, err = fetchScans(&teams)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &teams, nil
+}
+
+// LoB/Team of each app by app name
+func appTeamMap(teams *tf.TeamResp) map[string]string {
+	appTeams := make(map[string]string)
+	for _, t := range teams.Tm {
+		for _, a := range t.Apps {
+			appTeams[a.Name] = t.Name
+		}
+	}
+
+	return appTeams
+}
+
+///////////////////////////////////////
+// Commands                          //
+///////////////////////////////////////
+
+// Gather everything in the full report for month and the quarter it's in, up
+// to it. The month and the 2 before it are returned for the LoB/Team reports.
+func fullReport(month time.Time) (*report, []*tfMonth, error) {
+	teams, err := gatherSummary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Gather the month and the 2 before it once, the quarter is the ones of
+	// them in it
+	// TODO - Do partial and last full or just last full or just the partial?
+	fmt.Fprintln(os.Stderr, "Gathering month and quarter metrics...")
+	ms := sumMonths(month, 3)
+	m0, m1, m2 := ms[0], ms[1], ms[2]
+	qm := quarterMonths(month, ms)
+	var q0 tfQuarter
+	rollQuarter(&q0, appCount, qm[0], qm[1], qm[2])
+
+	r := newReport(m0)
+	r.addTargets(fetchTargetHistory(m0.tStamp))
+	for _, name := range reportSections {
+		switch name {
+		case "summary":
+			r.add(summarySection())
+		case "month":
+			r.add(monthSection("Month Metrics", m0))
+		case "month-1":
+			r.add(monthSection("Month - 1 Metrics", m1))
+		case "month-2":
+			r.add(monthSection("Month - 2 Metrics", m2))
+		case "quarter":
+			r.add(quarterSection(&q0))
+		case "forecast":
+			fcs, n, err := fetchForecasts(month)
+			if err != nil {
+				return nil, nil, err
+			}
+			r.add(forecastSection(nextQuarter(month), fcs, n))
+		case "lob-csv":
+			r.add(lobCSVSection(m0, m1, m2))
+		case "risk":
+			// Only with somewhere to get app criticality from
+			if tiersKnown() {
+				r.add(riskSection(r.month, m0, appTeamMap(teams)))
+			}
+		case "categories":
+			r.add(categorySection("Month CWE Categories", r.month, m0.cweCats, m0.cweCatsByLob),
+				categorySection("Quarter CWE Categories", q0.qLabel, q0.cweCats, q0.cweCatsByLob))
+		case "anomalies":
+			found, err := fetchAnomalies(month)
+			if err != nil {
+				return nil, nil, err
+			}
+			r.add(anomalySection(r.month, found))
+		}
+	}
+
+	return r, ms, nil
+}
+
+// Sections of the full report which can be picked in the config file
+var allSections = []string{"summary", "month", "month-1", "month-2", "quarter", "forecast", "lob-csv", "risk", "categories",
+	"anomalies"}
+
+func runReport(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	month := monthFlag(fs, "month", "month to report on")
+	teamDir := fs.String("team-dir", getenv("TFM_TEAM_DIR"), "directory to write a report per LoB/Team to")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+
+	r, ms, err := fullReport(t)
+	if err != nil {
+		return err
+	}
+	err = o.write(r)
+	if err != nil {
+		return err
+	}
+	if o.mail != nil && o.mail.perTeam {
+		err = mailTeamReports(o.mail, ms)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write a report per LoB/Team if asked to
+	if *teamDir != "" {
+		fmt.Fprintf(os.Stderr, "Writing LoB/Team reports to %v\n", *teamDir)
+		err = writeTeamReports(*teamDir, ms)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Done.")
+
+	return nil
+}
+
+func runSummary(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	r := &report{title: "ThreadFix Summary Metrics", created: time.Now()}
+	if findingFilter.active() {
+		r.add(filterSection())
+	}
+	r.addTargets(fetchTargetHistory(reportMonth(time.Now())))
+	r.add(summarySection())
+
+	return o.write(r)
+}
+
+func runMonth(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	month := monthFlag(fs, "month", "month to report on")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering month metrics...")
+	var m0 tfMonth
+	m0.tStamp = t
+	sumMonth(&m0)
+
+	found, err := fetchAnomalies(t)
+	if err != nil {
+		return err
+	}
+
+	r := newReport(&m0)
+	r.addTargets(fetchTargetHistory(m0.tStamp))
+	r.add(monthSection("Month Metrics", &m0),
+		categorySection("Month CWE Categories", r.month, m0.cweCats, m0.cweCatsByLob),
+		anomalySection(r.month, found))
+
+	return o.write(r)
+}
+
+func runQuarter(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	label := fs.String("label", "", "quarter to report on e.g. Q3-2026, defaults to the current quarter")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := quarterStamp(*label)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	// Only the months of the quarter up to t, the rest haven't happened yet
+	fmt.Fprintln(os.Stderr, "Gathering quarter metrics...")
+	ms := sumMonths(t, 3-monthsLeft(t))
+	qm := quarterMonths(t, ms)
+	var q0 tfQuarter
+	rollQuarter(&q0, appCount, qm[0], qm[1], qm[2])
+
+	fmt.Fprintln(os.Stderr, "Forecasting the next quarter...")
+	fcs, n, err := fetchForecasts(t)
+	if err != nil {
+		return err
+	}
+
+	r := newReport(ms[0])
+	r.addTargets(fetchTargetHistory(ms[0].tStamp))
+	r.title = "ThreadFix Metrics for " + q0.qLabel
+	r.add(quarterSection(&q0), forecastSection(nextQuarter(t), fcs, n), lobCSVSection(ms...),
+		categorySection("Quarter CWE Categories", q0.qLabel, q0.cweCats, q0.cweCatsByLob))
+
+	return o.write(r)
+}
+
+func runYear(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	ending := monthFlag(fs, "ending", "last month of the year")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*ending)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering year metrics...")
+	ms := sumMonths(t, 12)
+	var y tfYear
+	rollYear(&y, appCount, ms)
+
+	r := newReport(ms[0])
+	r.addTargets(fetchTargetHistory(ms[0].tStamp))
+	r.title = "ThreadFix Metrics for the year ending " + y.yearEnds
+	r.add(yearSection(&y), categorySection("Year CWE Categories", "the year ending "+y.yearEnds, y.cweCats, y.cweCatsByLob))
+
+	return o.write(r)
+}
+
+func runTrend(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	n := fs.Int("months", 12, "number of months in the trend")
+	ending := monthFlag(fs, "ending", "last month of the trend")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *n < 1 {
+		return errors.New("--months must be 1 or more\n")
+	}
+	t, err := monthStamp(*ending)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering trend metrics...")
+	ms := sumMonths(t, *n)
+
+	r := newReport(ms[0])
+	r.addTargets(fetchTargetHistory(ms[0].tStamp))
+	r.title = fmt.Sprintf("ThreadFix %v Month Trend to %v %v", *n, ms[0].tStamp.Month(), ms[0].tStamp.Year())
+	r.add(trendSection(ms))
+
+	return o.write(r)
+}
+
+func runTeams(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	month := monthFlag(fs, "month", "month to report on")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering month metrics...")
+	var m0 tfMonth
+	m0.tStamp = t
+	sumMonth(&m0)
+
+	r := newReport(&m0)
+	r.addTargets(fetchTargetHistory(m0.tStamp))
+	r.add(teamsSection(&m0))
+
+	return o.write(r)
+}
+
+func runApps(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	month := monthFlag(fs, "month", "month to report on")
+	team := fs.String("team", "", "only list apps for this LoB/Team")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+	teams, err := gatherSummary()
+	if err != nil {
+		return err
+	}
+	if _, ok := teamCounts[*team]; *team != "" && !ok {
+		return fmt.Errorf("No LoB/Team named %v in ThreadFix\n", *team)
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering month metrics...")
+	var m0 tfMonth
+	m0.tStamp = t
+	sumMonth(&m0)
+
+	r := newReport(&m0)
+	r.addTargets(fetchTargetHistory(m0.tStamp))
+	r.team = *team
+	r.add(appsSection(&m0, appTeamMap(teams), *team))
+
+	return o.write(r)
+}
+
+func runWeeks(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	n := fs.Int("weeks", 2, "number of weeks to report on")
+	ending := fs.String("ending", "", "a day in the last week as yyyy-mm-dd, defaults to today")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *n < 1 {
+		return errors.New("--weeks must be 1 or more\n")
+	}
+	end := time.Now()
+	if *ending != "" {
+		end, err = time.Parse(dayKey, *ending)
+		if err != nil {
+			return fmt.Errorf("Days look like 2026-09-30 not %v\n", *ending)
+		}
+		if end.After(time.Now()) {
+			return fmt.Errorf("%v hasn't happened yet\n", *ending)
+		}
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering week metrics...")
+	ws, err := fetchWeeks(end, *n)
+	if err != nil {
+		return err
+	}
+
+	r := newReport(ws[0].metrics)
+	r.addTargets(fetchTargetHistory(ws[0].metrics.tStamp))
+	r.title = "ThreadFix Metrics for " + weekLabel(ws[0])
+	r.month = weekLabel(ws[0])
+	r.add(weeksSection(ws, rollWeeks(ws, appCount)))
+
+	return o.write(r)
+}
+
+func runRange(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	from := fs.String("from", "", "first day of the range as yyyy-mm-dd")
+	to := fs.String("to", "", "last day of the range as yyyy-mm-dd, defaults to today")
+	by := fs.String("by", "", "split the range by week, month or quarter")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	start, end, err := rangeDays(*from, *to)
+	if err != nil {
+		return err
+	}
+	if *by != "" && !oneOf(*by, rangeBuckets) {
+		return fmt.Errorf("Ranges can be split by week, month or quarter not %v\n", *by)
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering date range metrics...")
+	rg, err := fetchRange(start, end, *by)
+	if err != nil {
+		return err
+	}
+
+	r := newReport(rg.metrics)
+	r.addTargets(fetchTargetHistory(rg.metrics.tStamp))
+	r.title = "ThreadFix Metrics for " + rg.label
+	r.month = rg.label
+	r.add(rangeSection(rg))
+
+	return o.write(r)
+}
+
+func runScanners(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	month := monthFlag(fs, "month", "last month to report on")
+	n := fs.Int("months", 1, "number of months to report on")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *n < 1 {
+		return errors.New("--months must be 1 or more\n")
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering scanner metrics...")
+	st, ms, err := fetchScanners(t, *n)
+	if err != nil {
+		return err
+	}
+
+	r := newReport(ms[0])
+	r.addTargets(fetchTargetHistory(ms[0].tStamp))
+	r.title = "ThreadFix Scanner Metrics for " + monthsLabel(ms)
+	r.add(scannerSection(monthsLabel(ms), st))
+
+	return o.write(r)
+}
+
+func runRisk(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	month := monthFlag(fs, "month", "month to report on")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+	if !tiersKnown() {
+		return errors.New("Risk weighting needs app criticality, set criticality.file or threadfix.url and threadfix.api_key\n")
+	}
+	teams, err := gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering month metrics...")
+	var m0 tfMonth
+	m0.tStamp = t
+	sumMonth(&m0)
+
+	r := newReport(&m0)
+	r.addTargets(fetchTargetHistory(m0.tStamp))
+	r.add(riskSection(r.month, &m0, appTeamMap(teams)))
+
+	return o.write(r)
+}
+
+func runScorecards(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	label := fs.String("label", "", "quarter to grade e.g. Q3-2026, defaults to the current quarter")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	t, err := quarterStamp(*label)
+	if err != nil {
+		return err
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Gathering scorecard metrics...")
+	scores, err := fetchScorecards(t)
+	if err != nil {
+		return err
+	}
+
+	q := getQuarter(t.Month(), t.Year())
+	r := newReport(&tfMonth{tStamp: t, quarter: q})
+	r.addTargets(fetchTargetHistory(t))
+	r.title = "ThreadFix LoB/Team Scorecards for " + q
+	r.add(scorecardSection(q, scores))
+
+	return o.write(r)
+}
+
+func runCoverage(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	days := fs.Int("days", 0, "days every app can go without a scan, overrides the coverage settings")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *days < 0 {
+		return errors.New("--days must be 0 or more, 0 uses the coverage settings\n")
+	}
+	if *days > 0 {
+		coverageDays = map[string]int{"": *days}
+	}
+	if !scansWanted() {
+		return errors.New("Scan coverage needs scan history, set threadfix.url and threadfix.api_key or TFM_TF_URL and TFM_TF_API_KEY\n")
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	n := time.Now()
+	today := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, time.UTC)
+	r := newReport(&tfMonth{tStamp: today, quarter: getQuarter(today.Month(), today.Year())})
+	r.addTargets(fetchTargetHistory(today))
+	r.title = "ThreadFix Scan Coverage for " + today.Format(dayKey)
+	r.add(coverageSection(appCoverages(n), n))
+
+	return o.write(r)
+}
+
+func runCheck(fs *flag.FlagSet, args []string) error {
+	o := outputFlags(fs)
+	months := fs.Int("months", checkMonths, "months back to look for open findings, 0 for all of them")
+	junit := fs.String("junit", getenv("TFM_JUNIT"), "file to write the results to as JUnit XML")
+	err := o.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *months < 0 {
+		return errors.New("--months must be 0 or more\n")
+	}
+	checkMonths = *months
+	if len(checkRules) == 0 {
+		return errors.New("No rules to check, set check.rules or TFM_CHECK_RULES\n")
+	}
+	_, err = gatherSummary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Checking rules...")
+	n := time.Now()
+	today := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, time.UTC)
+	searches, err := fetchCheckSearches(today)
+	if err != nil {
+		return err
+	}
+	results := checkResults(searches)
+
+	r := &report{title: "ThreadFix Policy Check for " + today.Format(dayKey), created: n}
+	if findingFilter.active() {
+		r.add(filterSection())
+	}
+	r.add(checkSection(results))
+	err = o.write(r)
+	if err != nil {
+		return err
+	}
+	if *junit != "" {
+		err = writeJUnit(*junit, results)
+		if err != nil {
+			return err
+		}
+	}
+
+	if v := violations(results); v > 0 {
+		return exitError{violationExit, fmt.Sprintf("Policy check failed, %v rule violations\n", v)}
+	}
+	fmt.Fprintln(os.Stderr, "Policy check passed.")
+
+	return nil
+}
+
+// Check and parse the --from and --to days of a range
+func rangeDays(from string, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	if from == "" {
+		return start, end, errors.New("--from is needed, e.g. --from 2026-01-15\n")
+	}
+	start, err := time.Parse(dayKey, from)
+	if err != nil {
+		return start, end, fmt.Errorf("Days look like 2026-01-15 not %v\n", from)
+	}
+	n := time.Now()
+	today := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, time.UTC)
+	end = today
+	if to != "" {
+		end, err = time.Parse(dayKey, to)
+		if err != nil {
+			return start, end, fmt.Errorf("Days look like 2026-04-30 not %v\n", to)
+		}
+	}
+	if end.After(today) {
+		end = today
+	}
+	if start.After(end) {
+		return start, end, fmt.Errorf("The range can't start after it ends or in the future\n")
+	}
+
+	return start, end, nil
+}
+
+func oneOf(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runExport(fs *flag.FlagSet, args []string) error {
+	dir := fs.String("dir", ".", "directory to write the report files to")
+	month := monthFlag(fs, "month", "month to report on")
+	perTeam := fs.Bool("per-team", false, "also write a report for each LoB/Team")
+	addFilterFlags(fs)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = findingFilter.check()
+	if err != nil {
+		return err
+	}
+	t, err := monthStamp(*month)
+	if err != nil {
+		return err
+	}
+
+	r, ms, err := fullReport(t)
+	if err != nil {
+		return err
+	}
+	base := "tfmetrics-" + t.Format(monthKey)
+	fmt.Fprintf(os.Stderr, "Writing %v.* to %v\n", base, *dir)
+	err = saveReport(*dir, base, r)
+	if err != nil {
+		return err
+	}
+	if *perTeam {
+		fmt.Fprintf(os.Stderr, "Writing LoB/Team reports to %v\n", filepath.Join(*dir, "teams"))
+		return writeTeamReports(filepath.Join(*dir, "teams"), ms)
+	}
+
+	return nil
+}
+
+func runServe(fs *flag.FlagSet, args []string) error {
+	listen := fs.String("listen", defaultListen, "address to serve on")
+	refresh := fs.Duration("refresh", defaultRefresh, "how often to refresh metrics from ThreadFix")
+	// loadConfig has already checked these
+	if l := getenv("TFM_LISTEN"); l != "" {
+		fs.Set("listen", l)
+	}
+	if r := getenv("TFM_REFRESH"); r != "" {
+		fs.Set("refresh", r)
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *refresh <= 0 {
+		return errors.New("--refresh must be more than 0\n")
+	}
+	err = connect()
+	if err != nil {
+		return err
+	}
+
+	return serve(*listen, *refresh)
+}
@@ -0,0 +1,59 @@
+// cli_test.go
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuarterStamp(t *testing.T) {
+	defer func(n func() time.Time) { now = n }(now)
+	defer func(d map[int]string, e map[int]int) { qtrDefs, quarterEnd = d, e }(qtrDefs, quarterEnd)
+	tests := []struct {
+		quarters []string
+		today    string
+		label    string
+		want     string
+		bad      bool
+	}{
+		{nil, "2026-10-19", "Q1-2020", "2020-03-31", false},
+		{nil, "2026-10-19", "Q4-2019", "2019-12-31", false},
+		{nil, "2026-10-19", "Q2-2021", "2021-06-30", false},
+		{nil, "2026-10-19", "Q3-2026", "2026-09-30", false},
+		// The quarter under way only has the months up to the latest
+		{nil, "2026-10-19", "", "2026-10-19", false},
+		{nil, "2026-10-19", "Q4-2026", "2026-10-19", false},
+		{nil, "2026-11-20", "Q4-2026", "2026-11-20", false},
+		// Up to month_cutoff the latest month is the one before
+		{nil, "2026-11-10", "", "2026-10-31", false},
+		{nil, "2026-11-10", "Q4-2026", "2026-10-31", false},
+		{nil, "2026-10-10", "", "2026-09-30", false},
+		{nil, "2026-10-10", "Q4-2026", "", true},
+		{nil, "2026-10-19", "Q1-2027", "", true},
+		{nil, "2026-10-19", "Q5-2020", "", true},
+		{nil, "2026-10-19", "Q1", "", true},
+		{nil, "2026-10-19", "Q1-2999", "", true},
+		// Fiscal years are named for the year Q4 ends in
+		{wrappingQuarters, "2026-10-19", "Q4-2026", "2026-01-31", false},
+		{wrappingQuarters, "2026-10-19", "Q1-2027", "2026-04-30", false},
+		{wrappingQuarters, "2026-10-19", "Q3-2027", "2026-10-19", false},
+		{wrappingQuarters, "2026-10-19", "", "2026-10-19", false},
+		{wrappingQuarters, "2026-11-20", "Q4-2027", "2026-11-20", false},
+		{wrappingQuarters, "2026-10-19", "Q4-2027", "", true},
+	}
+	for _, tt := range tests {
+		useQuarters(t, tt.quarters)
+		today := mustDay(t, tt.today)
+		now = func() time.Time { return today }
+		got, err := quarterStamp(tt.label)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v on %v: no error", tt.label, tt.today)
+			}
+			continue
+		}
+		if err != nil || got.Format(dayKey) != tt.want {
+			t.Errorf("%v on %v: %v %v, want %v", tt.label, tt.today, got.Format(dayKey), err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,626 @@
+// config.go
+// settings read from a TOML config file with environment variable overrides
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config file used if TFM_CONFIG or --config don't name one. It's fine for it
+// not to exist, everything has a default.
+const defaultConfig = "tfmetrics.toml"
+
+// A setting that can be made in the config file as key under [section] or in
+// the environment variable env, which wins if both are set. set checks the
+// value and applies it, settings without set are read later with getenv. It's
+// a func(v string) error or for lists a func(vs []string) error, which gets
+// the items of an array or the comma separated items of anything else.
+type setting struct {
+	key string
+	env string
+	set interface{}
+}
+
+var settings = []setting{
+	// ThreadFix connection - host and API key are read by tfclient itself
+	{"threadfix.timeout", "TFM_TIMEOUT", setTimeout},
+	{"threadfix.max_results", "TFM_MAX_RESULTS", setMaxResults},
+	{"threadfix.workers", "TFM_WORKERS", setWorkers},
+	// Only needed for scan history, see scans.go
+	{"threadfix.url", "TFM_TF_URL", checkURL},
+	{"threadfix.api_key", "TFM_TF_API_KEY", nil},
+	// Fiscal calendar
+	{"calendar.month_cutoff", "TFM_MONTH_CUTOFF", setMonthCutoff},
+	{"calendar.quarters", "TFM_QUARTERS", setQuarters},
+	// Scoring
+	{"scoring.critical", "TFM_WEIGHT_CRITICAL", weightSetter(5)},
+	{"scoring.high", "TFM_WEIGHT_HIGH", weightSetter(4)},
+	{"scoring.medium", "TFM_WEIGHT_MEDIUM", weightSetter(3)},
+	{"scoring.low", "TFM_WEIGHT_LOW", weightSetter(2)},
+	{"scoring.info", "TFM_WEIGHT_INFO", weightSetter(1)},
+	// What's searched for and reported
+	{"search.severities", "TFM_SEVERITIES", setSeverities},
+	{"report.sections", "TFM_SECTIONS", setSections},
+	{"filter.include", "TFM_INCLUDE", filterSetter("include")},
+	{"filter.exclude", "TFM_EXCLUDE", filterSetter("exclude")},
+	// CWE categories - see categories.go
+	{"categories.file", "TFM_CATEGORIES", setCategoryFile},
+	{"categories.schemes", "TFM_SCHEMES", setSchemes},
+	// Scan coverage - see coverage.go
+	{"coverage.days", "TFM_COVERAGE_DAYS", coverageDaysSetter("")},
+	{"coverage.critical_days", "TFM_COVERAGE_CRITICAL_DAYS", coverageDaysSetter("critical")},
+	{"coverage.high_days", "TFM_COVERAGE_HIGH_DAYS", coverageDaysSetter("high")},
+	{"coverage.medium_days", "TFM_COVERAGE_MEDIUM_DAYS", coverageDaysSetter("medium")},
+	{"coverage.low_days", "TFM_COVERAGE_LOW_DAYS", coverageDaysSetter("low")},
+	{"coverage.sast", "TFM_SAST_TOOLS", toolTypeSetter("SAST")},
+	{"coverage.dast", "TFM_DAST_TOOLS", toolTypeSetter("DAST")},
+	{"coverage.sca", "TFM_SCA_TOOLS", toolTypeSetter("SCA")},
+	// Business criticality - see criticality.go
+	{"criticality.file", "TFM_CRITICALITY", setCriticalityFile},
+	{"criticality.default", "TFM_DEFAULT_TIER", setDefaultTier},
+	{"criticality.critical", "TFM_TIER_CRITICAL", tierWeightSetter("Critical")},
+	{"criticality.high", "TFM_TIER_HIGH", tierWeightSetter("High")},
+	{"criticality.medium", "TFM_TIER_MEDIUM", tierWeightSetter("Medium")},
+	{"criticality.low", "TFM_TIER_LOW", tierWeightSetter("Low")},
+	// SLAs and scorecards - see scorecard.go
+	{"sla.critical", "TFM_SLA_CRITICAL", slaSetter(5)},
+	{"sla.high", "TFM_SLA_HIGH", slaSetter(4)},
+	{"sla.medium", "TFM_SLA_MEDIUM", slaSetter(3)},
+	{"sla.low", "TFM_SLA_LOW", slaSetter(2)},
+	{"sla.info", "TFM_SLA_INFO", slaSetter(1)},
+	{"scorecard.months", "TFM_SCORECARD_MONTHS", setScorecardMonths},
+	{"scorecard.open_penalty", "TFM_OPEN_PENALTY", setOpenPenalty},
+	{"scorecard.open", "TFM_SCORE_OPEN", scoreWeightSetter("open")},
+	{"scorecard.sla", "TFM_SCORE_SLA", scoreWeightSetter("sla")},
+	{"scorecard.coverage", "TFM_SCORE_COVERAGE", scoreWeightSetter("coverage")},
+	{"scorecard.trend", "TFM_SCORE_TREND", scoreWeightSetter("trend")},
+	{"scorecard.grades", "TFM_GRADES", setGrades},
+	// Targets - see targets.go
+	{"targets.goals", "TFM_TARGETS", setTargets},
+	{"targets.months", "TFM_TARGET_MONTHS", setTargetMonths},
+	// Forecasts - see forecast.go
+	{"forecast.months", "TFM_FORECAST_MONTHS", setForecastMonths},
+	{"forecast.alpha", "TFM_FORECAST_ALPHA", smoothSetter(&smoothAlpha)},
+	{"forecast.beta", "TFM_FORECAST_BETA", smoothSetter(&smoothBeta)},
+	{"forecast.confidence", "TFM_FORECAST_CONFIDENCE", setForecastConfidence},
+	// Anomalies - see anomalies.go
+	{"anomalies.method", "TFM_ANOMALY_METHOD", setAnomalyMethod},
+	{"anomalies.threshold", "TFM_ANOMALY_THRESHOLD", setAnomalyThreshold},
+	{"anomalies.months", "TFM_ANOMALY_MONTHS", setAnomalyMonths},
+	{"anomalies.min_change", "TFM_ANOMALY_MIN_CHANGE", setAnomalyMinChange},
+	// Policy check - see check.go
+	{"check.rules", "TFM_CHECK_RULES", setCheckRules},
+	{"check.months", "TFM_CHECK_MONTHS", setCheckMonths},
+	{"check.junit", "TFM_JUNIT", nil},
+	// App sizes for densities - see sizes.go
+	{"apps.size_classes", "TFM_SIZE_CLASSES", setSizeClasses},
+	{"apps.sizes", "TFM_APP_SIZES", setAppSizes},
+	// Output formats and destinations
+	{"output.format", "TFM_FORMAT", setFormat},
+	{"output.file", "TFM_OUT", nil},
+	{"output.team_dir", "TFM_TEAM_DIR", nil},
+	{"output.email", "TFM_EMAIL", checkBool},
+	// Email - see email.go
+	{"email.host", "TFM_SMTP_HOST", nil},
+	{"email.port", "TFM_SMTP_PORT", checkInt},
+	{"email.security", "TFM_SMTP_SECURITY", nil},
+	{"email.insecure", "TFM_SMTP_INSECURE", checkBool},
+	{"email.user", "TFM_SMTP_USER", nil},
+	{"email.pass", "TFM_SMTP_PASS", nil},
+	{"email.from", "TFM_MAIL_FROM", nil},
+	{"email.to", "TFM_MAIL_TO", nil},
+	{"email.lob_to", "TFM_MAIL_LOB_TO", nil},
+	{"email.per_team", "TFM_MAIL_PER_TEAM", checkBool},
+	{"email.subject", "TFM_MAIL_SUBJECT", nil},
+	// Serve mode
+	{"serve.listen", "TFM_LISTEN", nil},
+	{"serve.refresh", "TFM_REFRESH", checkDuration},
+}
+
+// Values from the config file keyed by their environment variable
+var confValues = make(map[string]tomlValue)
+
+// An environment variable or if it's not set the same setting from the config file
+func getenv(env string) string {
+	if v, ok := os.LookupEnv(env); ok {
+		return v
+	}
+
+	return confValues[env].s
+}
+
+// Same as getenv for a list, config file arrays are kept as they are so their
+// items can hold commas
+func getlist(env string) []string {
+	if v, ok := os.LookupEnv(env); ok {
+		return splitList(v)
+	}
+	if v := confValues[env]; v.items != nil {
+		return v.items
+	}
+
+	return splitList(confValues[env].s)
+}
+
+// Read the config file, if there is one, then check and apply every setting.
+// All the problems found are returned together.
+func loadConfig(path string) error {
+	required := path != ""
+	if path == "" {
+		path = os.Getenv("TFM_CONFIG")
+		required = path != ""
+	}
+	if path == "" {
+		path = defaultConfig
+	}
+
+	var errs []string
+	vals, err := parseConfig(path)
+	if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return fmt.Errorf("can't read config file: %v", err)
+	}
+	confValues = make(map[string]tomlValue)
+	for _, k := range sortedKeys(vals) {
+		v := vals[k]
+		s, ok := findSetting(k)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%v: unknown setting %v", path, k))
+			continue
+		}
+		confValues[s.env] = v
+	}
+
+	for _, s := range settings {
+		v := getenv(s.env)
+		if v == "" || s.set == nil {
+			continue
+		}
+		var err error
+		switch set := s.set.(type) {
+		case func(v string) error:
+			err = set(v)
+		case func(vs []string) error:
+			err = set(getlist(s.env))
+		}
+		if err != nil {
+			from := s.key + " in " + path
+			if _, ok := os.LookupEnv(s.env); ok {
+				from = s.env
+			}
+			errs = append(errs, fmt.Sprintf("%v: %v", from, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Bad configuration:\n  %v\n", strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
+
+func findSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+
+	return setting{}, false
+}
+
+///////////////////////////////////////
+// Setters - check and apply a value //
+///////////////////////////////////////
+
+// How long to wait on ThreadFix, 0 for no limit
+var tfTimeout time.Duration
+
+func setTimeout(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return fmt.Errorf("must be a duration like 30s or 2m not %v", v)
+	}
+	tfTimeout = d
+
+	return nil
+}
+
+func setMaxResults(v string) error {
+	n, err := positiveInt(v)
+	if err != nil {
+		return err
+	}
+	maxResults = n
+
+	return nil
+}
+
+func setWorkers(v string) error {
+	n, err := positiveInt(v)
+	if err != nil {
+		return err
+	}
+	fetchWorkers = n
+
+	return nil
+}
+
+func setMonthCutoff(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 28 {
+		return fmt.Errorf("must be a day from 0 to 28 not %v", v)
+	}
+	monthCutoff = n
+
+	return nil
+}
+
+// 12 quarter names for January to December e.g. Q4,Q1,Q1,Q1,Q2,... for a
+// fiscal year starting in February. Each quarter must be 3 months in a row.
+func setQuarters(qs []string) error {
+	if len(qs) != 12 {
+		return fmt.Errorf("must list a quarter for each of the 12 months not %v", len(qs))
+	}
+	defs := make(map[int]string)
+	ends := make(map[int]int)
+	for i, q := range qs {
+		defs[i+1] = q
+	}
+	for m := 1; m <= 12; m++ {
+		n, err := strconv.Atoi(strings.TrimPrefix(defs[m], "Q"))
+		if err != nil || !strings.HasPrefix(defs[m], "Q") || n < 1 || n > 4 {
+			return fmt.Errorf("quarters are Q1 to Q4 not %v", defs[m])
+		}
+		// The last month of a quarter is followed by a different one
+		if defs[m%12+1] != defs[m] {
+			if _, ok := ends[n]; ok {
+				return fmt.Errorf("%v must be 3 months in a row", defs[m])
+			}
+			ends[n] = m
+		}
+	}
+	for n := 1; n <= 4; n++ {
+		if _, ok := ends[n]; !ok {
+			return fmt.Errorf("Q%v must be 3 months in a row", n)
+		}
+		if defs[ends[n]] != defs[(ends[n]+9)%12+1] || defs[ends[n]] != defs[(ends[n]+10)%12+1] {
+			return fmt.Errorf("Q%v must be 3 months in a row", n)
+		}
+		// Fiscal years are named for the year Q4 ends in, so Q1 to Q4 run in order
+		if next := ends[n%4+1]; next != (ends[n]+2)%12+1 {
+			return fmt.Errorf("Q%v must follow Q%v", n%4+1, n)
+		}
+	}
+	qtrDefs = defs
+	quarterEnd = ends
+
+	return nil
+}
+
+func weightSetter(sev int) func(v string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("must be a whole number, 0 or more, not %v", v)
+		}
+		vulnWeight[sev] = n
+
+		return nil
+	}
+}
+
+var sevValues = map[string]int{
+	"critical": 5,
+	"high":     4,
+	"medium":   3,
+	"low":      2,
+	"info":     1,
+}
+
+func setSeverities(v []string) error {
+	var sevs []int
+	for _, s := range v {
+		n, ok := sevValues[strings.ToLower(s)]
+		if !ok {
+			return fmt.Errorf("severities are critical, high, medium, low or info not %v", s)
+		}
+		sevs = append(sevs, n)
+	}
+	if len(sevs) == 0 {
+		return errors.New("must list at least one severity")
+	}
+	searchSeverities = sevs
+
+	return nil
+}
+
+func filterSetter(how string) func(v string) error {
+	return func(v string) error {
+		err := findingFilter.addAll(how, v)
+		if err != nil {
+			return err
+		}
+		if len(findingFilter.severities(searchSeverities)) == 0 {
+			return errors.New("the severity filters leave no severities to search for")
+		}
+
+		return nil
+	}
+}
+
+func setSections(v []string) error {
+	var secs []string
+	for _, s := range v {
+		if !oneOf(s, allSections) {
+			return fmt.Errorf("sections are %v not %v", strings.Join(allSections, ", "), s)
+		}
+		secs = append(secs, s)
+	}
+	if len(secs) == 0 {
+		return errors.New("must list at least one section")
+	}
+	reportSections = secs
+
+	return nil
+}
+
+// Default format for commands that print a report
+var defaultFormat = "text"
+
+func setFormat(v string) error {
+	if _, ok := formats[v]; !ok {
+		return fmt.Errorf("must be text, html, csv or json not %v", v)
+	}
+	defaultFormat = v
+
+	return nil
+}
+
+func checkBool(v string) error {
+	_, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("must be true or false not %v", v)
+	}
+
+	return nil
+}
+
+func checkInt(v string) error {
+	_, err := positiveInt(v)
+
+	return err
+}
+
+func checkDuration(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("must be a duration like 15m or 1h not %v", v)
+	}
+
+	return nil
+}
+
+func checkURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be a URL like https://tf.example.com/threadfix not %v", v)
+	}
+
+	return nil
+}
+
+func positiveInt(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("must be a whole number more than 0 not %v", v)
+	}
+
+	return n, nil
+}
+
+///////////////////////////////////////
+// TOML parsing                      //
+///////////////////////////////////////
+
+// Read the subset of TOML tfmetrics needs - [section] headers and key = value
+// lines where values are strings, numbers, booleans or arrays of those. Keys
+// come back as section.key.
+func parseConfig(path string) (map[string]tomlValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseTOML(path, f)
+}
+
+// Same as parseConfig reading from r, name is used in errors. Keys can be
+// quoted to hold spaces or dots.
+func parseTOML(name string, r io.Reader) (map[string]tomlValue, error) {
+	vals := make(map[string]tomlValue)
+	section := ""
+	scan := bufio.NewScanner(r)
+	n := 0
+	for scan.Scan() {
+		n++
+		start := n
+		tl := scanTOML(scan.Text())
+		line, depth := tl.text, tl.depth
+		// Arrays can be spread over several lines
+		for depth > 0 && tl.eq >= 0 && scan.Scan() {
+			n++
+			more := scanTOML(scan.Text())
+			line += " " + more.text
+			depth += more.depth
+		}
+		text := strings.TrimSpace(line)
+		if text == "" {
+			continue
+		}
+		if tl.eq < 0 && strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") || strings.HasPrefix(text, "[[") {
+				return nil, fmt.Errorf("%v:%v: bad section header %v", name, start, text)
+			}
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+		// Split on the first = outside of a quoted key
+		var k, v string
+		if tl.eq >= 0 {
+			k, v = strings.TrimSpace(line[:tl.eq]), line[tl.eq+1:]
+		}
+		if k == "" {
+			return nil, fmt.Errorf("%v:%v: expected key = value not %v", name, start, text)
+		}
+		if strings.HasPrefix(k, `"`) {
+			uk, err := strconv.Unquote(k)
+			if err != nil {
+				return nil, fmt.Errorf("%v:%v: bad key %v", name, start, k)
+			}
+			k = uk
+		}
+		if section != "" {
+			k = section + "." + k
+		}
+		if _, dup := vals[k]; dup {
+			return nil, fmt.Errorf("%v:%v: %v is set twice", name, start, k)
+		}
+		val, err := parseValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v %v", name, start, k, err)
+		}
+		vals[k] = val
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
+
+// A value from a TOML file as a string, arrays keep their items as well so
+// commas in them aren't taken as separators
+type tomlValue struct {
+	s     string
+	items []string
+}
+
+func parseValue(v string) (tomlValue, error) {
+	if strings.HasPrefix(v, "[") {
+		if !strings.HasSuffix(v, "]") {
+			return tomlValue{}, errors.New("has an unterminated array")
+		}
+		items := []string{}
+		for _, i := range splitArray(v[1 : len(v)-1]) {
+			item, err := scalarValue(i)
+			if err != nil {
+				return tomlValue{}, err
+			}
+			items = append(items, item)
+		}
+		return tomlValue{strings.Join(items, ","), items}, nil
+	}
+	s, err := scalarValue(v)
+
+	return tomlValue{s: s}, err
+}
+
+func scalarValue(v string) (string, error) {
+	if strings.HasPrefix(v, "[") {
+		return "", fmt.Errorf("has an array in an array %v", v)
+	}
+	switch {
+	case strings.HasPrefix(v, `"`):
+		s, err := strconv.Unquote(v)
+		if err != nil {
+			return "", fmt.Errorf("has a bad string %v", v)
+		}
+		return s, nil
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("has a bad string %v", v)
+		}
+		return v[1 : len(v)-1], nil
+	case v == "true" || v == "false":
+		return v, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(v, "_", ""), 64); err == nil {
+		return strings.ReplaceAll(v, "_", ""), nil
+	}
+
+	return "", fmt.Errorf("has a value that isn't a string, number, boolean or array: %v", v)
+}
+
+// Split array items on the commas outside of strings
+func splitArray(s string) []string {
+	var items []string
+	start := 0
+	for _, i := range scanTOML(s).commas {
+		items = append(items, s[start:i])
+		start = i + 1
+	}
+	items = append(items, s[start:])
+
+	var trimmed []string
+	for _, i := range items {
+		if i = strings.TrimSpace(i); i != "" {
+			trimmed = append(trimmed, i)
+		}
+	}
+
+	return trimmed
+}
+
+// A line of TOML with any comment dropped, where its first = is (-1 if there
+// isn't one), where its commas are and how many more [ than ] it has, only
+// counting those outside of strings
+type tomlLine struct {
+	text   string
+	eq     int
+	commas []int
+	depth  int
+}
+
+// Go through line a character at a time keeping track of whether it's in a
+// string and whether the last character was a backslash escaping this one
+func scanTOML(line string) tomlLine {
+	tl := tomlLine{text: line, eq: -1}
+	quote := rune(0)
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			// Only basic strings have escapes, 'literal' ones don't
+			if c == '\\' && quote == '"' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			tl.text = line[:i]
+			return tl
+		case c == '=' && tl.eq < 0:
+			tl.eq = i
+		case c == ',':
+			tl.commas = append(tl.commas, i)
+		case c == '[':
+			tl.depth++
+		case c == ']':
+			tl.depth--
+		}
+	}
+
+	return tl
+}
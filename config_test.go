@@ -0,0 +1,138 @@
+// config_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		key   string
+		s     string
+		items []string
+		bad   bool
+	}{
+		{"string", `[email]
+from = "tfmetrics@example.com" # a comment`, "email.from", "tfmetrics@example.com", nil, false},
+		{"literal", "[email]\nsubject = 'Metrics # {{.Month}}'", "email.subject", "Metrics # {{.Month}}", nil, false},
+		{"number", "[scorecard]\nmonths = 1_2", "scorecard.months", "12", nil, false},
+		{"bool", "[output]\nemail = true", "output.email", "true", nil, false},
+		{"quoted key", "[owasp]\n" + `"Input, Validation" = [20, "CWE-79"]`,
+			"owasp.Input, Validation", "20,CWE-79", []string{"20", "CWE-79"}, false},
+		{"array", `to = ["a@example.com", 'b@example.com']`,
+			"to", "a@example.com,b@example.com", []string{"a@example.com", "b@example.com"}, false},
+		{"commas in items", `to = ["\"Doe, Jo\" <jo@example.com>", "b@example.com"]`,
+			"to", `"Doe, Jo" <jo@example.com>,b@example.com`, []string{`"Doe, Jo" <jo@example.com>`, "b@example.com"}, false},
+		{"multi-line array", "[targets]\ngoals = [\n  \"Pay, Inc: crit_pct < 5\", # first\n  \"coverage >= 90\",\n]",
+			"targets.goals", "Pay, Inc: crit_pct < 5,coverage >= 90", []string{"Pay, Inc: crit_pct < 5", "coverage >= 90"}, false},
+		{"empty array", "to = []", "to", "", []string{}, false},
+		// Brackets, backslashes and = inside strings are part of the string
+		{"bracket in key", "[owasp]\n\"A[1\" = \"x\"\nnext = 1", "owasp.A[1", "x", nil, false},
+		{"bracket in item", "goals = [\n  \"x]\", # first\n  \"y\",\n]", "goals", "x],y", []string{"x]", "y"}, false},
+		{"escaped backslash", `path = "C:\\" # a comment`, "path", `C:\`, nil, false},
+		{"escaped backslash in item", `to = ["a\\", "b"]`, "to", `a\,b`, []string{`a\`, "b"}, false},
+		{"escaped quote", `to = "say \"hi\" # not a comment"`, "to", `say "hi" # not a comment`, nil, false},
+		{"= in key", `"a=b" = 1`, "a=b", "1", nil, false},
+		{"unterminated array", `to = ["a@example.com"`, "", "", nil, true},
+		{"nested array", `to = [["a"]]`, "", "", nil, true},
+		{"bare word", "to = someone", "", "", nil, true},
+		{"bad string", `to = "open`, "", "", nil, true},
+		{"no value", "[email]\nto", "", "", nil, true},
+		{"twice", "to = 1\nto = 2", "", "", nil, true},
+		{"bad header", "[[email]]", "", "", nil, true},
+	}
+	for _, tt := range tests {
+		vals, err := parseTOML("test.toml", strings.NewReader(tt.text))
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error, got %v", tt.name, vals)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		v, ok := vals[tt.key]
+		if !ok || v.s != tt.s || !reflect.DeepEqual(v.items, tt.items) {
+			t.Errorf("%v: got %q %#v want %q %#v", tt.name, v.s, v.items, tt.s, tt.items)
+		}
+	}
+}
+
+func TestGetlist(t *testing.T) {
+	defer func() { confValues = make(map[string]tomlValue) }()
+	confValues = map[string]tomlValue{
+		"TFM_MAIL_TO":    {`"Doe, Jo" <jo@example.com>,b@example.com`, []string{`"Doe, Jo" <jo@example.com>`, "b@example.com"}},
+		"TFM_SAST_TOOLS": {s: "Fortify, Checkmarx"},
+	}
+	tests := []struct {
+		env  string
+		set  string
+		want []string
+	}{
+		{"TFM_MAIL_TO", "", []string{`"Doe, Jo" <jo@example.com>`, "b@example.com"}},
+		{"TFM_SAST_TOOLS", "", []string{"Fortify", "Checkmarx"}},
+		{"TFM_MAIL_TO", "a@example.com, b@example.com", []string{"a@example.com", "b@example.com"}},
+		{"TFM_DAST_TOOLS", "", nil},
+	}
+	for _, tt := range tests {
+		if tt.set != "" {
+			t.Setenv(tt.env, tt.set)
+		}
+		if got := getlist(tt.env); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v=%q: got %#v want %#v", tt.env, tt.set, got, tt.want)
+		}
+		os.Unsetenv(tt.env)
+	}
+}
+
+func TestLoadConfigLists(t *testing.T) {
+	defer func() { targets = nil; confValues = make(map[string]tomlValue) }()
+	path := filepath.Join(t.TempDir(), "tfmetrics.toml")
+	text := "[targets]\ngoals = [\"Pay, Inc: crit_pct < 5\", \"coverage >= 90\"]\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].team != "Pay, Inc" || targets[1].metric != "coverage" {
+		t.Errorf("got targets %+v", targets)
+	}
+}
+
+func TestSetQuarters(t *testing.T) {
+	defer func(d map[int]string, e map[int]int) { qtrDefs, quarterEnd = d, e }(qtrDefs, quarterEnd)
+	tests := []struct {
+		name string
+		qs   []string
+		ends map[int]int
+		bad  bool
+	}{
+		{"calendar", calendarQuarters, map[int]int{1: 3, 2: 6, 3: 9, 4: 12}, false},
+		{"wrapping", wrappingQuarters, map[int]int{1: 4, 2: 7, 3: 10, 4: 1}, false},
+		{"too few", calendarQuarters[:11], nil, true},
+		{"Q5", []string{"Q1", "Q1", "Q1", "Q2", "Q2", "Q2", "Q3", "Q3", "Q3", "Q5", "Q5", "Q5"}, nil, true},
+		{"split", []string{"Q1", "Q2", "Q1", "Q1", "Q2", "Q2", "Q3", "Q3", "Q3", "Q4", "Q4", "Q4"}, nil, true},
+		{"out of order", []string{"Q1", "Q1", "Q1", "Q3", "Q3", "Q3", "Q2", "Q2", "Q2", "Q4", "Q4", "Q4"}, nil, true},
+	}
+	for _, tt := range tests {
+		err := setQuarters(tt.qs)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(quarterEnd, tt.ends) {
+			t.Errorf("%v: got %v %v want %v", tt.name, quarterEnd, err, tt.ends)
+		}
+	}
+}
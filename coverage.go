@@ -0,0 +1,133 @@
+// coverage.go
+// apps not scanned recently and apps never scanned by a type of tool
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Days an app can go without a scan by ThreadFix criticality, lower case.
+// Apps with a criticality not listed use the default under "".
+var coverageDays = map[string]int{"": 90}
+
+// Types of tool and the scanner names, lower case, that are of each type. A
+// scanner is of a type if its name contains one of the type's names.
+var toolTypes = []string{"SAST", "DAST", "SCA"}
+
+var toolTypeNames = map[string][]string{
+	"SAST": {"checkmarx", "fortify", "appscan source", "brakeman", "findbugs", "spotbugs", "pmd", "sonarqube",
+		"veracode", "coverity", "cppcheck", "clang", "semgrep", "codeql"},
+	"DAST": {"zed attack proxy", "zap", "burp", "arachni", "acunetix", "netsparker", "appscan standard",
+		"webinspect", "qualys", "w3af", "skipfish", "nto spider", "whitehat"},
+	"SCA": {"dependency", "sonatype", "black duck", "blackduck", "snyk", "retire", "npm audit", "whitesource",
+		"mend"},
+}
+
+// How well an app is covered by scans
+type appCoverage struct {
+	app         string
+	team        string
+	criticality string          // ThreadFix criticality, empty if unknown
+	maxDays     int             // days it can go without a scan
+	lastScan    time.Time       // zero if it's never been scanned
+	types       map[string]bool // tool types it's ever been scanned by
+}
+
+// Days since the app's last scan on asOf, -1 if it's never been scanned
+func (c appCoverage) age(asOf time.Time) int {
+	if c.lastScan.IsZero() {
+		return -1
+	}
+
+	return int(asOf.Sub(c.lastScan).Hours() / 24)
+}
+
+// Scanned recently enough for its criticality
+func (c appCoverage) covered(asOf time.Time) bool {
+	a := c.age(asOf)
+
+	return a >= 0 && a <= c.maxDays
+}
+
+// The type of tool a scanner is, empty if it's not a known one
+func toolType(scanner string) string {
+	n := strings.ToLower(scanner)
+	for _, t := range toolTypes {
+		for _, name := range toolTypeNames[t] {
+			if strings.Contains(n, name) {
+				return t
+			}
+		}
+	}
+
+	return ""
+}
+
+// The coverage of every app from the scan history up to asOf, sorted by team
+// then app
+func appCoverages(asOf time.Time) []appCoverage {
+	byApp := make(map[string]*appCoverage)
+	var cov []*appCoverage
+	for app, team := range appLobs {
+		crit := knownTier(app)
+		days, ok := coverageDays[strings.ToLower(crit)]
+		if !ok {
+			days = coverageDays[""]
+		}
+		c := &appCoverage{app: app, team: team, criticality: crit, maxDays: days, types: make(map[string]bool)}
+		byApp[app] = c
+		cov = append(cov, c)
+	}
+	for _, s := range scanHistory {
+		c, ok := byApp[s.app]
+		if !ok || s.date.After(asOf) {
+			continue
+		}
+		if s.date.After(c.lastScan) {
+			c.lastScan = s.date
+		}
+		if t := toolType(s.scanner); t != "" {
+			c.types[t] = true
+		}
+	}
+
+	sort.Slice(cov, func(i, j int) bool {
+		if cov[i].team != cov[j].team {
+			return cov[i].team < cov[j].team
+		}
+		return cov[i].app < cov[j].app
+	})
+	var out []appCoverage
+	for _, c := range cov {
+		out = append(out, *c)
+	}
+
+	return out
+}
+
+func coverageDaysSetter(crit string) func(v string) error {
+	return func(v string) error {
+		n, err := positiveInt(v)
+		if err != nil {
+			return err
+		}
+		coverageDays[crit] = n
+
+		return nil
+	}
+}
+
+// Replace the scanner names of a tool type
+func toolTypeSetter(t string) func(vs []string) error {
+	return func(vs []string) error {
+		var names []string
+		for _, n := range vs {
+			names = append(names, strings.ToLower(n))
+		}
+		toolTypeNames[t] = names
+
+		return nil
+	}
+}
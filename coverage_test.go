@@ -0,0 +1,96 @@
+// coverage_test.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestToolType(t *testing.T) {
+	defer func(n []string) { toolTypeNames["SAST"] = n }(toolTypeNames["SAST"])
+	tests := []struct {
+		sast    []string // the SAST scanner names, the defaults if nil
+		scanner string
+		want    string
+	}{
+		{nil, "OWASP Zed Attack Proxy", "DAST"},
+		{nil, "Burp Suite", "DAST"},
+		{nil, "Fortify 360", "SAST"},
+		{nil, "CodeQL", "SAST"},
+		{nil, "OWASP Dependency Check", "SCA"},
+		{nil, "Snyk", "SCA"},
+		{nil, "Nessus", ""},
+		{[]string{"Nessus"}, "Tenable Nessus", "SAST"},
+		{[]string{"Nessus"}, "Fortify 360", ""},
+	}
+	defaults := toolTypeNames["SAST"]
+	for _, tt := range tests {
+		toolTypeNames["SAST"] = defaults
+		if tt.sast != nil {
+			toolTypeSetter("SAST")(tt.sast)
+		}
+		if got := toolType(tt.scanner); got != tt.want {
+			t.Errorf("%v with SAST %v: got %q want %q", tt.scanner, tt.sast, got, tt.want)
+		}
+	}
+}
+
+func TestAppCoverages(t *testing.T) {
+	defer func(l map[string]string, c map[string]string, tiers map[string]string, d map[string]int, h []tfScan) {
+		appLobs, appCriticality, appTiers, coverageDays, scanHistory = l, c, tiers, d, h
+	}(appLobs, appCriticality, appTiers, coverageDays, scanHistory)
+	appLobs = map[string]string{"pay-web": "Pay", "pay-api": "Pay", "shop": "Retail & Co", "cart": "Retail & Co"}
+	appCriticality = map[string]string{"pay-web": "Critical", "shop": "High"}
+	appTiers = nil
+	coverageDays = map[string]int{"": 90, "critical": 30}
+	scanHistory = []tfScan{
+		{app: "pay-web", scanner: "ZAP", date: mustDay(t, "2026-09-01")},
+		{app: "pay-web", scanner: "Fortify", date: mustDay(t, "2026-08-01")},
+		{app: "shop", scanner: "Burp Suite", date: mustDay(t, "2026-10-10")},
+		{app: "shop", scanner: "Snyk", date: mustDay(t, "2026-11-01")},
+		{app: "cart", scanner: "Nessus", date: mustDay(t, "2026-05-01")},
+		{app: "gone", scanner: "ZAP", date: mustDay(t, "2026-10-01")},
+	}
+	tests := []struct {
+		asOf string
+		want []string // team/app criticality, days allowed, age, covered and tool types
+	}{
+		{"2026-10-19", []string{
+			"Pay/pay-api  90 -1 false ",
+			"Pay/pay-web Critical 30 48 false DAST,SAST",
+			"Retail & Co/cart  90 171 false ",
+			"Retail & Co/shop High 90 9 true DAST",
+		}},
+		// Scans after asOf aren't counted
+		{"2026-11-05", []string{
+			"Pay/pay-api  90 -1 false ",
+			"Pay/pay-web Critical 30 65 false DAST,SAST",
+			"Retail & Co/cart  90 188 false ",
+			"Retail & Co/shop High 90 4 true DAST,SCA",
+		}},
+		{"2026-08-15", []string{
+			"Pay/pay-api  90 -1 false ",
+			"Pay/pay-web Critical 30 14 true SAST",
+			"Retail & Co/cart  90 106 false ",
+			"Retail & Co/shop High 90 -1 false ",
+		}},
+	}
+	for _, tt := range tests {
+		asOf := mustDay(t, tt.asOf)
+		var got []string
+		for _, c := range appCoverages(asOf) {
+			var types []string
+			for k, _ := range c.types {
+				types = append(types, k)
+			}
+			sort.Strings(types)
+			got = append(got, fmt.Sprintf("%v/%v %v %v %v %v %v", c.team, c.app, c.criticality, c.maxDays,
+				c.age(asOf), c.covered(asOf), strings.Join(types, ",")))
+		}
+		if g, w := strings.Join(got, "\n"), strings.Join(tt.want, "\n"); g != w {
+			t.Errorf("as of %v: got\n%v\nwant\n%v", tt.asOf, g, w)
+		}
+	}
+}
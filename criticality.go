@@ -0,0 +1,173 @@
+// criticality.go
+// business criticality tiers of apps and scores weighted by them
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Business criticality tiers, most critical first, the same as ThreadFix's
+// application criticality
+var critTiers = []string{"Critical", "High", "Medium", "Low"}
+
+// How much more an app's score counts for each tier
+var tierWeight = map[string]int{
+	"Critical": 8,
+	"High":     4,
+	"Medium":   2,
+	"Low":      1,
+}
+
+// Tier of apps whose criticality isn't known
+var defaultTier = "Medium"
+
+// Tiers from the local criticality file by app name, lower case. Apps given
+// by ThreadFix ID are keyed by the ID until resolveAppTiers swaps it for their
+// name. These win over ThreadFix's criticality.
+var appTiers map[string]string
+
+// A tier name in any case as the tier, false if it isn't one
+func tierName(v string) (string, bool) {
+	for _, t := range critTiers {
+		if strings.EqualFold(t, strings.TrimSpace(v)) {
+			return t, true
+		}
+	}
+
+	return "", false
+}
+
+// An app's tier from the local file or ThreadFix, empty if neither has it
+func knownTier(app string) string {
+	if t, ok := appTiers[strings.ToLower(app)]; ok {
+		return t
+	}
+	if t, ok := tierName(appCriticality[app]); ok {
+		return t
+	}
+
+	return ""
+}
+
+// An app's tier, defaultTier if it isn't known
+func appTier(app string) string {
+	if t := knownTier(app); t != "" {
+		return t
+	}
+
+	return defaultTier
+}
+
+// There's somewhere to get app criticality from
+func tiersKnown() bool {
+	return appTiers != nil || scansWanted()
+}
+
+// Read app tiers from a CSV file with a header row and app (name or ThreadFix
+// ID) and criticality columns, e.g.
+//
+//	app,criticality
+//	pay-web,Critical
+func setCriticalityFile(v string) error {
+	f, err := os.Open(v)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	head, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%v: can't read the header row: %v", v, err)
+	}
+	appCol, critCol := -1, -1
+	for i, h := range head {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "app":
+			appCol = i
+		case "criticality":
+			critCol = i
+		}
+	}
+	if appCol < 0 || critCol < 0 {
+		return fmt.Errorf("%v: needs app and criticality columns", v)
+	}
+
+	tiers := make(map[string]string)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", v, err)
+		}
+		if appCol >= len(rec) || critCol >= len(rec) || strings.TrimSpace(rec[appCol]) == "" {
+			continue
+		}
+		t, ok := tierName(rec[critCol])
+		if !ok {
+			line, _ := r.FieldPos(0)
+			return fmt.Errorf("%v:%v: criticality is %v not %v", v, line, strings.Join(critTiers, ", "), rec[critCol])
+		}
+		tiers[strings.ToLower(strings.TrimSpace(rec[appCol]))] = t
+	}
+	appTiers = tiers
+
+	return nil
+}
+
+func setDefaultTier(v string) error {
+	t, ok := tierName(v)
+	if !ok {
+		return fmt.Errorf("must be %v not %v", strings.Join(critTiers, ", "), v)
+	}
+	defaultTier = t
+
+	return nil
+}
+
+func tierWeightSetter(tier string) func(v string) error {
+	return func(v string) error {
+		n, err := positiveInt(v)
+		if err != nil {
+			return err
+		}
+		tierWeight[tier] = n
+
+		return nil
+	}
+}
+
+// Key the tiers given by ThreadFix ID by app name instead
+func resolveAppTiers(teams *tf.TeamResp) {
+	for _, tm := range teams.Tm {
+		for _, a := range tm.Apps {
+			id := strconv.Itoa(a.Id)
+			if t, ok := appTiers[id]; ok {
+				if _, named := appTiers[strings.ToLower(a.Name)]; !named {
+					appTiers[strings.ToLower(a.Name)] = t
+				}
+				delete(appTiers, id)
+			}
+		}
+	}
+}
+
+// App scores weighted by their tier
+func riskScores(scores map[string]int) map[string]int {
+	risk := make(map[string]int)
+	for app, s := range scores {
+		risk[app] = s * tierWeight[appTier(app)]
+	}
+
+	return risk
+}
@@ -0,0 +1,84 @@
+// criticality_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+func TestSetCriticalityFile(t *testing.T) {
+	defer func(tiers map[string]string) { appTiers = tiers }(appTiers)
+	tests := []struct {
+		name string
+		csv  string
+		want map[string]string
+		bad  bool
+	}{
+		{"tiers", "app,criticality\nPay-Web,critical\nshop, LOW \n",
+			map[string]string{"pay-web": "Critical", "shop": "Low"}, false},
+		{"other columns", "team,Criticality,App\nPay,High,3\nPay,Medium,\n", map[string]string{"3": "High"}, false},
+		{"no criticality", "app,tier\nshop,High\n", nil, true},
+		{"bad tier", "app,criticality\nshop,Urgent\n", nil, true},
+		{"empty", "", nil, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "criticality.csv")
+		if err := os.WriteFile(path, []byte(tt.csv), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		appTiers = nil
+		err := setCriticalityFile(path)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error, got %v", tt.name, appTiers)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(appTiers, tt.want) {
+			t.Errorf("%v: got %v %v want %v", tt.name, appTiers, err, tt.want)
+		}
+	}
+}
+
+func TestResolveAppTiers(t *testing.T) {
+	defer func(tiers map[string]string) { appTiers = tiers }(appTiers)
+	appTiers = map[string]string{"1": "Critical", "3": "Low", "shop": "High", "9": "Medium"}
+	resolveAppTiers(&tf.TeamResp{Tm: []tf.Team{
+		{Name: "Pay", Apps: []tf.App{{Id: 1, Name: "Pay-Web"}, {Id: 2, Name: "pay-api"}}},
+		{Name: "Retail & Co", Apps: []tf.App{{Id: 3, Name: "shop"}}},
+	}})
+	// Names given win over IDs, unknown IDs are kept
+	want := map[string]string{"pay-web": "Critical", "shop": "High", "9": "Medium"}
+	if !reflect.DeepEqual(appTiers, want) {
+		t.Errorf("got %v want %v", appTiers, want)
+	}
+}
+
+func TestRiskScores(t *testing.T) {
+	defer func(tiers map[string]string, crits map[string]string, d string) {
+		appTiers, appCriticality, defaultTier = tiers, crits, d
+	}(appTiers, appCriticality, defaultTier)
+	appTiers = map[string]string{"pay-web": "Critical", "shop": "Low"}
+	appCriticality = map[string]string{"pay-web": "Low", "pay-api": "High", "cart": "Unknown"}
+	scores := map[string]int{"pay-web": 10, "pay-api": 10, "shop": 10, "cart": 10}
+	tests := []struct {
+		defaultTier string
+		want        map[string]int
+	}{
+		// The local file wins over ThreadFix, apps in neither get the default tier
+		{"Medium", map[string]int{"pay-web": 80, "pay-api": 40, "shop": 10, "cart": 20}},
+		{"low", map[string]int{"pay-web": 80, "pay-api": 40, "shop": 10, "cart": 10}},
+	}
+	for _, tt := range tests {
+		if err := setDefaultTier(tt.defaultTier); err != nil {
+			t.Fatal(err)
+		}
+		if got := riskScores(scores); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("default %v: got %v want %v", tt.defaultTier, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+// dashboard.go
+// web dashboard served by serve mode with drill-down from LoB to app to findings
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names for the severity ints ThreadFix uses
+var sevNames = map[int]string{
+	5: "Critical",
+	4: "High",
+	3: "Medium",
+	2: "Low",
+	1: "Info",
+}
+
+const monthKey = "2006-01"
+
+func (s *server) dashboard(mux *http.ServeMux) {
+	mux.HandleFunc("/", s.locked(s.overviewPage))
+	mux.HandleFunc("/lob", s.locked(s.lobPage))
+	mux.HandleFunc("/app", s.locked(s.appPage))
+}
+
+// Data common to every dashboard page
+type dashPage struct {
+	Title   string
+	Taken   string
+	Period  string       // the months being shown e.g. July 2026 - September 2026
+	From    string       // first month shown as yyyy-mm
+	To      string       // last month shown as yyyy-mm
+	Months  []string     // months that can be picked, oldest first
+	Month   template.URL // range for the latest month
+	Quarter template.URL // range for the latest quarter
+	Year    template.URL // range for the latest year
+	Query   template.URL // from / to query string to keep the range when drilling down
+	Charts  []template.HTML
+}
+
+type dashCount struct {
+	Name  string
+	Count int
+}
+
+type dashRow struct {
+	Name     string
+	Apps     int
+	Assessed int
+	Score    int
+	Crit     int
+	High     int
+	Med      int
+	Low      int
+}
+
+type dashFinding struct {
+	Month    string
+	Severity string
+	CWE      string
+	Scanners string
+}
+
+type overviewData struct {
+	dashPage
+	TotVulns   int
+	AppCount   int
+	TotAssess  int
+	CritApps   int
+	PercntCrit float64
+	HighApps   int
+	PercntHigh float64
+	Lobs       []dashRow
+	Worst      []dashRow
+	Best       []dashRow
+	Tools      []dashCount
+	CWEs       []dashCount
+}
+
+type lobData struct {
+	dashPage
+	Lob  string
+	Apps []dashRow
+}
+
+type appData struct {
+	dashPage
+	App      string
+	Lob      string
+	Findings []dashFinding
+}
+
+// Work out the months asked for with from / to, defaulting to the latest month
+func (snap *snapshot) period(r *http.Request) (dashPage, []*tfMonth, error) {
+	ms := snap.months
+	// The quarter the latest month is in, up to it, and the 12 months to it
+	qm := quarterMonths(ms[0].tStamp, ms)
+	y := len(ms) - 1
+	if y > 11 {
+		y = 11
+	}
+	p := dashPage{
+		Taken:   snap.taken.Format(time.RFC1123),
+		Month:   monthRange(ms[0], ms[0]),
+		Quarter: monthRange(qm[2], ms[0]),
+		Year:    monthRange(ms[y], ms[0]),
+	}
+	for i := len(ms) - 1; i >= 0; i-- {
+		p.Months = append(p.Months, ms[i].tStamp.Format(monthKey))
+	}
+
+	p.From = r.FormValue("from")
+	p.To = r.FormValue("to")
+	if p.To == "" {
+		p.To = ms[0].tStamp.Format(monthKey)
+	}
+	if p.From == "" {
+		p.From = p.To
+	}
+	if p.From > p.To {
+		p.From, p.To = p.To, p.From
+	}
+
+	var picked []*tfMonth
+	for _, m := range ms {
+		k := m.tStamp.Format(monthKey)
+		if k >= p.From && k <= p.To {
+			picked = append(picked, m)
+		}
+	}
+	if len(picked) == 0 {
+		return p, nil, fmt.Errorf("No metrics for %v to %v, the dashboard has %v to %v",
+			p.From, p.To, p.Months[0], p.Months[len(p.Months)-1])
+	}
+
+	first, last := picked[len(picked)-1].tStamp, picked[0].tStamp
+	p.Period = fmt.Sprintf("%v %v", last.Month(), last.Year())
+	if len(picked) > 1 {
+		p.Period = fmt.Sprintf("%v %v - %v", first.Month(), first.Year(), p.Period)
+	}
+	p.Query = monthRange(picked[len(picked)-1], picked[0])
+
+	return p, picked, nil
+}
+
+// Query string selecting the months from first to last
+func monthRange(first *tfMonth, last *tfMonth) template.URL {
+	return template.URL("from=" + first.tStamp.Format(monthKey) + "&to=" + last.tStamp.Format(monthKey))
+}
+
+// Combine the findings of the months sent into one set of metrics
+func combineMonths(ms []*tfMonth, apps int) *tfMonth {
+	all := *ms[0].search
+	all.Results = nil
+	for _, m := range ms {
+		all.Results = append(all.Results, m.search.Results...)
+	}
+	c := tfMonth{
+		tStamp:  ms[0].tStamp,
+		quarter: ms[0].quarter,
+	}
+	calcMonth(&c, &all, apps)
+
+	return &c
+}
+
+// Sets up a page and its months or writes an error and returns false
+func (s *server) pageSetup(w http.ResponseWriter, r *http.Request) (*snapshot, dashPage, []*tfMonth, bool) {
+	snap := s.current()
+	if snap == nil {
+		http.Error(w, "Metrics have not been gathered from ThreadFix yet, try again shortly", http.StatusServiceUnavailable)
+		return nil, dashPage{}, nil, false
+	}
+	p, ms, err := snap.period(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, dashPage{}, nil, false
+	}
+
+	return snap, p, ms, true
+}
+
+func (s *server) overviewPage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	snap, p, ms, ok := s.pageSetup(w, r)
+	if !ok {
+		return
+	}
+	m := combineMonths(ms, snap.apps)
+
+	d := overviewData{
+		dashPage:   p,
+		TotVulns:   m.totVulns,
+		AppCount:   snap.apps,
+		TotAssess:  m.totAssess,
+		CritApps:   len(m.critApps),
+		PercntCrit: m.percntCrit,
+		HighApps:   len(m.highApps),
+		PercntHigh: m.percntHigh,
+	}
+	d.Title = "ThreadFix Metrics"
+
+	for _, t := range sortedKeys(snap.teamCounts) {
+		v := m.vulnByLob[t]
+		d.Lobs = append(d.Lobs, dashRow{Name: t, Apps: snap.teamCounts[t], Assessed: m.assessByLob[t],
+			Crit: v.crit, High: v.high, Med: v.med, Low: v.low})
+	}
+	d.Worst = scoreRows(m.worstApps, m.wAppsCnt, false)
+	d.Best = scoreRows(m.bestApps, m.bAppsCnt, true)
+	d.Tools = countRows(m.toolUsage, 0)
+	d.CWEs = countRows(m.topCWE, 10)
+
+	// Trends over everything the dashboard holds
+	labels, total, crit, high, pCrit, pHigh := []string{}, []float64{}, []float64{}, []float64{}, []float64{}, []float64{}
+	for i := len(snap.months) - 1; i >= 0; i-- {
+		sm := snap.months[i]
+		labels = append(labels, sm.tStamp.Format("Jan 06"))
+		total = append(total, float64(sm.totVulns))
+		crit = append(crit, float64(len(sm.critApps)))
+		high = append(high, float64(len(sm.highApps)))
+		pCrit = append(pCrit, sm.percntCrit)
+		pHigh = append(pHigh, sm.percntHigh)
+	}
+	d.Charts = []template.HTML{
+		lineChart("Total vulnerabilities", labels, chartSeries{"Vulnerabilities", total}),
+		lineChart("Apps with criticals and highs", labels, chartSeries{"Criticals", crit}, chartSeries{"Highs", high}),
+		lineChart("Percentage of apps with criticals and highs", labels, chartSeries{"% Critical", pCrit}, chartSeries{"% High", pHigh}),
+	}
+
+	render(w, "overview", d)
+}
+
+func (s *server) lobPage(w http.ResponseWriter, r *http.Request) {
+	snap, p, ms, ok := s.pageSetup(w, r)
+	if !ok {
+		return
+	}
+	lob := r.FormValue("name")
+	if _, ok := snap.teamCounts[lob]; !ok {
+		http.Error(w, "No LoB/Team named "+lob, http.StatusNotFound)
+		return
+	}
+	m := combineMonths(ms, snap.apps)
+	srch := teamSearch(m.search, lob)
+
+	d := lobData{dashPage: p, Lob: lob}
+	d.Title = lob
+	scores := appScores(srch)
+	cnts := appVulnCounts(srch, appFindings(srch))
+	for _, a := range snap.teamApps[lob] {
+		d.Apps = append(d.Apps, dashRow{Name: a, Score: scores[a],
+			Crit: cnts[a].crit, High: cnts[a].high, Med: cnts[a].med, Low: cnts[a].low})
+	}
+	sortRows(d.Apps)
+
+	labels, crit, high := []string{}, []float64{}, []float64{}
+	for i := len(snap.months) - 1; i >= 0; i-- {
+		sm := snap.months[i]
+		labels = append(labels, sm.tStamp.Format("Jan 06"))
+		crit = append(crit, float64(sm.vulnByLob[lob].crit))
+		high = append(high, float64(sm.vulnByLob[lob].high))
+	}
+	d.Charts = []template.HTML{
+		lineChart("Critical and high findings", labels, chartSeries{"Critical", crit}, chartSeries{"High", high}),
+	}
+
+	render(w, "lob", d)
+}
+
+func (s *server) appPage(w http.ResponseWriter, r *http.Request) {
+	snap, p, ms, ok := s.pageSetup(w, r)
+	if !ok {
+		return
+	}
+	app := r.FormValue("name")
+
+	d := appData{dashPage: p, App: app}
+	d.Title = app
+	for t, apps := range snap.teamApps {
+		for _, a := range apps {
+			if a == app {
+				d.Lob = t
+			}
+		}
+	}
+	if d.Lob == "" {
+		http.Error(w, "No app named "+app, http.StatusNotFound)
+		return
+	}
+
+	for _, m := range ms {
+		for k, _ := range m.search.Results {
+			f := m.search.Results[k]
+			if f.Apps.Name != app {
+				continue
+			}
+			d.Findings = append(d.Findings, dashFinding{
+				Month:    m.tStamp.Format("Jan 2006"),
+				Severity: sevNames[f.Severity.Value],
+				CWE:      fmt.Sprintf("CWE-%v: %v", f.CweVuln.Id, f.CweVuln.Name),
+				Scanners: strings.Join(f.Scanners, ", "),
+			})
+		}
+	}
+
+	labels, crit, high := []string{}, []float64{}, []float64{}
+	for i := len(snap.months) - 1; i >= 0; i-- {
+		sm := snap.months[i]
+		labels = append(labels, sm.tStamp.Format("Jan 06"))
+		crit = append(crit, float64(sm.critApps[app]))
+		high = append(high, float64(sm.highApps[app]))
+	}
+	d.Charts = []template.HTML{
+		lineChart("Critical and high findings", labels, chartSeries{"Critical", crit}, chartSeries{"High", high}),
+	}
+
+	render(w, "app", d)
+}
+
+// Apps and their score, sorted by score
+func scoreRows(scores map[string]int, cnts map[string]VulnCount, ascending bool) []dashRow {
+	var rows []dashRow
+	for _, r := range rankCounts(scores, ascending) {
+		rows = append(rows, dashRow{Name: r.name, Score: r.count,
+			Crit: cnts[r.name].crit, High: cnts[r.name].high, Med: cnts[r.name].med, Low: cnts[r.name].low})
+	}
+
+	return rows
+}
+
+// Name / count pairs sorted by count, max of 0 means all of them
+func countRows(m map[string]int, max int) []dashCount {
+	var rows []dashCount
+	sorted := rankCounts(m, false)
+	if max > 0 && len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	for _, r := range sorted {
+		rows = append(rows, dashCount{r.name, r.count})
+	}
+
+	return rows
+}
+
+// Worst score first then by name
+func sortRows(rows []dashRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Score != rows[j].Score {
+			return rows[i].Score > rows[j].Score
+		}
+		return rows[i].Name < rows[j].Name
+	})
+}
+
+// Rows for the apps table plus the range so app links keep it
+type appsTable struct {
+	Rows  []dashRow
+	Query template.URL
+}
+
+func render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashTmpl.ExecuteTemplate(w, name, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+///////////////////////////////////////
+// Trend charts drawn as inline SVG  //
+///////////////////////////////////////
+
+type chartSeries struct {
+	name   string
+	values []float64
+}
+
+var chartColors = []string{"#c0392b", "#e67e22", "#2980b9", "#27ae60"}
+
+func lineChart(title string, labels []string, series ...chartSeries) template.HTML {
+	const w, h, left, bottom, top = 640.0, 220.0, 50.0, 30.0, 30.0
+	plotW, plotH := w-left-25, h-bottom-top
+
+	max := 0.0
+	for _, s := range series {
+		for _, v := range s.values {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	step := plotW
+	if len(labels) > 1 {
+		step = plotW / float64(len(labels)-1)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" width="%v" height="%v" viewBox="0 0 %v %v">`, w, h, w, h)
+	fmt.Fprintf(&b, `<text x="%v" y="16" class="title">%v</text>`, left, template.HTMLEscapeString(title))
+	// Axes and the max value
+	fmt.Fprintf(&b, `<line x1="%v" y1="%v" x2="%v" y2="%v" class="axis"/>`, left, top, left, top+plotH)
+	fmt.Fprintf(&b, `<line x1="%v" y1="%v" x2="%v" y2="%v" class="axis"/>`, left, top+plotH, left+plotW, top+plotH)
+	fmt.Fprintf(&b, `<text x="%v" y="%v" text-anchor="end">%.4g</text>`, left-4, top+4, max)
+	fmt.Fprintf(&b, `<text x="%v" y="%v" text-anchor="end">0</text>`, left-4, top+plotH+4)
+	for i, l := range labels {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%v" text-anchor="middle">%v</text>`,
+			left+float64(i)*step, h-10, template.HTMLEscapeString(l))
+	}
+	// A line and legend entry per series
+	for i, s := range series {
+		c := chartColors[i%len(chartColors)]
+		var pts []string
+		for j, v := range s.values {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", left+float64(j)*step, top+plotH-(v/max)*plotH))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%v" stroke-width="2" points="%v"/>`, c, strings.Join(pts, " "))
+		fmt.Fprintf(&b, `<rect x="%v" y="%v" width="10" height="10" fill="%v"/><text x="%v" y="%v">%v</text>`,
+			w-160, 6+float64(i)*14, c, w-145, 15+float64(i)*14, template.HTMLEscapeString(s.name))
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+var dashTmpl = template.Must(template.New("dash").Funcs(template.FuncMap{
+	"pct":  func(f float64) string { return fmt.Sprintf("%.2f%%", f) },
+	"apps": func(rows []dashRow, q template.URL) appsTable { return appsTable{rows, q} },
+}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - tfmetrics</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+th { background: #eee; }
+.cards div { display: inline-block; border: 1px solid #ccc; padding: 8px 16px; margin: 0 8px 8px 0; }
+.cards b { display: block; font-size: 22px; }
+.chart { margin: 0 1em 1em 0; border: 1px solid #eee; }
+.chart text { font-size: 11px; }
+.chart .title { font-size: 13px; font-weight: bold; }
+.chart .axis { stroke: #999; }
+nav a { margin-right: 1em; }
+</style>
+</head>
+<body>
+<nav><a href="/?{{.Query}}">Overview</a> <a href="/?{{.Month}}">Latest month</a> <a href="/?{{.Quarter}}">Latest quarter</a> <a href="/?{{.Year}}">Latest year</a></nav>
+<form method="get">
+From <select name="from">{{$from := .From}}{{range .Months}}<option{{if eq . $from}} selected{{end}}>{{.}}</option>{{end}}</select>
+to <select name="to">{{$to := .To}}{{range .Months}}<option{{if eq . $to}} selected{{end}}>{{.}}</option>{{end}}</select>
+<input type="submit" value="Show">
+</form>
+<h1>{{.Title}}</h1>
+<p>{{.Period}} - gathered from ThreadFix {{.Taken}}</p>
+{{range .Charts}}{{.}}{{end}}
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+
+{{define "overview"}}{{template "head" .}}
+<div class="cards">
+<div><b>{{.TotVulns}}</b>Vulnerabilities</div>
+<div><b>{{.TotAssess}} / {{.AppCount}}</b>Apps assessed</div>
+<div><b>{{.CritApps}} ({{pct .PercntCrit}})</b>Apps with criticals</div>
+<div><b>{{.HighApps}} ({{pct .PercntHigh}})</b>Apps with highs</div>
+</div>
+<h2>LoB/Teams</h2>
+<table>
+<tr><th>LoB/Team</th><th>Apps</th><th>Assessed</th><th>Critical</th><th>High</th><th>Medium</th><th>Low</th></tr>
+{{range .Lobs}}<tr><td><a href="/lob?name={{.Name}}&amp;{{$.Query}}">{{.Name}}</a></td><td>{{.Apps}}</td><td>{{.Assessed}}</td><td>{{.Crit}}</td><td>{{.High}}</td><td>{{.Med}}</td><td>{{.Low}}</td></tr>
+{{end}}</table>
+<h2>Worst apps</h2>
+{{template "apps" apps .Worst .Query}}
+<h2>Best apps</h2>
+{{template "apps" apps .Best .Query}}
+<h2>Findings by tool</h2>
+{{template "counts" .Tools}}
+<h2>Top 10 CWEs</h2>
+{{template "counts" .CWEs}}
+{{template "foot"}}{{end}}
+
+{{define "lob"}}{{template "head" .}}
+<h2>Apps</h2>
+{{template "apps" apps .Apps .Query}}
+{{template "foot"}}{{end}}
+
+{{define "app"}}{{template "head" .}}
+<p>Part of <a href="/lob?name={{.Lob}}&amp;{{.Query}}">{{.Lob}}</a></p>
+<h2>Findings</h2>
+<table>
+<tr><th>Month</th><th>Severity</th><th>CWE</th><th>Scanners</th></tr>
+{{range .Findings}}<tr><td>{{.Month}}</td><td>{{.Severity}}</td><td>{{.CWE}}</td><td>{{.Scanners}}</td></tr>
+{{else}}<tr><td colspan="4">No open findings</td></tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+
+{{define "apps"}}<table>
+<tr><th>App</th><th>Score</th><th>Critical</th><th>High</th><th>Medium</th><th>Low</th></tr>
+{{range .Rows}}<tr><td><a href="/app?name={{.Name}}&amp;{{$.Query}}">{{.Name}}</a></td><td>{{.Score}}</td><td>{{.Crit}}</td><td>{{.High}}</td><td>{{.Med}}</td><td>{{.Low}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{define "counts"}}<table>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}
+`))
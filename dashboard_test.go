@@ -0,0 +1,28 @@
+// dashboard_test.go
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPeriodQuarterLink(t *testing.T) {
+	tests := []struct {
+		end     string
+		quarter string
+	}{
+		{"2026-10-19", "from=2026-10&to=2026-10"},
+		{"2026-11-30", "from=2026-10&to=2026-11"},
+		{"2026-09-30", "from=2026-07&to=2026-09"},
+	}
+	for _, tt := range tests {
+		snap := fakeSnap(mustDay(t, tt.end))
+		p, _, err := snap.period(httptest.NewRequest("GET", "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p.Quarter) != tt.quarter {
+			t.Errorf("%v: quarter link %v, want %v", tt.end, p.Quarter, tt.quarter)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// email.go
+// delivers the metrics report by SMTP email
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Settings for emailing the report. These are read from the environment or
+// the [email] section of the config file - see config.go:
+//
+//	TFM_SMTP_HOST      SMTP server - email is only sent if this is set
+//	TFM_SMTP_PORT      SMTP port, defaults to 25, 465 for tls and 587 for starttls
+//	TFM_SMTP_SECURITY  none, starttls or tls - defaults to starttls
+//	TFM_SMTP_INSECURE  set to true to skip verifying the server's certificate
+//	TFM_SMTP_USER      username for SMTP auth, no auth is done if empty
+//	TFM_SMTP_PASS      password for SMTP auth
+//	TFM_MAIL_FROM      address the report is sent from
+//	TFM_MAIL_TO        comma separated list of recipients
+//	TFM_MAIL_LOB_TO    recipients per LoB e.g. Payments=a@ex.com,b@ex.com;Retail=c@ex.com
+//	TFM_MAIL_PER_TEAM  set to true to send LoB recipients only their own team's report
+//	TFM_MAIL_SUBJECT   subject template, {{.Month}}, {{.Quarter}}, {{.Team}} and {{.Title}} are available
+type mailConfig struct {
+	host     string
+	port     int
+	security string
+	insecure bool
+	user     string
+	pass     string
+	from     string
+	to       []string
+	lobTo    map[string][]string
+	perTeam  bool
+	subject  string
+}
+
+const defaultSubject = "ThreadFix metrics for {{.Month}} ({{.Quarter}})"
+
+func mailFromEnv() (*mailConfig, error) {
+	c := &mailConfig{
+		host:     getenv("TFM_SMTP_HOST"),
+		security: strings.ToLower(getenv("TFM_SMTP_SECURITY")),
+		user:     getenv("TFM_SMTP_USER"),
+		pass:     getenv("TFM_SMTP_PASS"),
+		from:     getenv("TFM_MAIL_FROM"),
+		to:       getlist("TFM_MAIL_TO"),
+		lobTo:    make(map[string][]string),
+		subject:  getenv("TFM_MAIL_SUBJECT"),
+	}
+	// No SMTP host means no email
+	if c.host == "" {
+		return nil, nil
+	}
+
+	if p := getenv("TFM_SMTP_PORT"); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("TFM_SMTP_PORT is not a number: %v", p)
+		}
+		c.port = port
+	}
+	if i := getenv("TFM_SMTP_INSECURE"); i != "" {
+		insecure, err := strconv.ParseBool(i)
+		if err != nil {
+			return nil, fmt.Errorf("TFM_SMTP_INSECURE must be true or false: %v", i)
+		}
+		c.insecure = insecure
+	}
+	if p := getenv("TFM_MAIL_PER_TEAM"); p != "" {
+		perTeam, err := strconv.ParseBool(p)
+		if err != nil {
+			return nil, fmt.Errorf("TFM_MAIL_PER_TEAM must be true or false: %v", p)
+		}
+		c.perTeam = perTeam
+	}
+	for _, l := range strings.Split(getenv("TFM_MAIL_LOB_TO"), ";") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("TFM_MAIL_LOB_TO entries must look like LoB=addr,addr: %v", l)
+		}
+		lob := strings.TrimSpace(kv[0])
+		c.lobTo[lob] = append(c.lobTo[lob], splitList(kv[1])...)
+	}
+
+	return c, c.check()
+}
+
+// Fill in defaults and make sure there's enough to send an email
+func (c *mailConfig) check() error {
+	switch c.security {
+	case "":
+		c.security = "starttls"
+	case "none", "starttls", "tls":
+	default:
+		return fmt.Errorf("SMTP security must be none, starttls or tls not %v", c.security)
+	}
+	if c.port == 0 {
+		switch c.security {
+		case "tls":
+			c.port = 465
+		case "starttls":
+			c.port = 587
+		default:
+			c.port = 25
+		}
+	}
+	if c.subject == "" {
+		c.subject = defaultSubject
+	}
+	if _, err := template.New("subject").Parse(c.subject); err != nil {
+		return fmt.Errorf("Unable to parse the email subject template: %v", err)
+	}
+	if c.from == "" {
+		return errors.New("An email from address is required to send the report")
+	}
+	if len(c.recipients()) == 0 && !(c.perTeam && len(c.lobTo) > 0) {
+		return errors.New("At least one email recipient is required to send the report")
+	}
+
+	return nil
+}
+
+// Everyone who gets the full report - the overall list plus the LoB contacts
+// unless they are being sent their own team's report instead
+func (c *mailConfig) recipients() []string {
+	seen := make(map[string]bool)
+	var all []string
+	add := func(addrs []string) {
+		for _, a := range addrs {
+			if !seen[strings.ToLower(a)] {
+				seen[strings.ToLower(a)] = true
+				all = append(all, a)
+			}
+		}
+	}
+	add(c.to)
+	if c.perTeam {
+		return all
+	}
+	for _, lob := range c.lobs() {
+		add(c.lobTo[lob])
+	}
+
+	return all
+}
+
+// LoBs with their own recipients in name order so output is stable
+func (c *mailConfig) lobs() []string {
+	lobs := make([]string, 0, len(c.lobTo))
+	for lob := range c.lobTo {
+		lobs = append(lobs, lob)
+	}
+	sort.Strings(lobs)
+
+	return lobs
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+
+	return l
+}
+
+func mailSubject(c *mailConfig, r *report) (string, error) {
+	t, err := template.New("subject").Parse(c.subject)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = t.Execute(&b, struct{ Title, Team, Month, Quarter string }{r.title, r.team, r.month, r.quarter})
+
+	return b.String(), err
+}
+
+// Build a MIME message with the report as the body - plain text and HTML
+// alternatives - and the CSV and JSON versions attached
+func mailMessage(c *mailConfig, to []string, r *report) ([]byte, error) {
+	subj, err := mailSubject(c, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	mixed := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %v\r\n", c.from)
+	fmt.Fprintf(&msg, "To: %v\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", subj))
+	fmt.Fprintf(&msg, "Date: %v\r\n", r.created.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%v\r\n\r\n", mixed.Boundary())
+
+	// Body as text and HTML
+	var alt bytes.Buffer
+	altW := multipart.NewWriter(&alt)
+	var text, html bytes.Buffer
+	writeText(&text, r)
+	if err := writeHTML(&html, r); err != nil {
+		return nil, err
+	}
+	if err := qpPart(altW, "text/plain; charset=utf-8", text.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := qpPart(altW, "text/html; charset=utf-8", html.Bytes()); err != nil {
+		return nil, err
+	}
+	altW.Close()
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", "multipart/alternative; boundary="+altW.Boundary())
+	p, err := mixed.CreatePart(h)
+	if err != nil {
+		return nil, err
+	}
+	p.Write(alt.Bytes())
+
+	// Attachments, named for the month reported on or when it was run for
+	// reports without one
+	when := r.created
+	if m, err := time.Parse("January 2006", r.month); err == nil {
+		when = m
+	}
+	name := "tfmetrics-" + when.Format(monthKey)
+	if r.team != "" {
+		name += "-" + teamFileName(r.team)
+	}
+	var csvOut, jsonOut bytes.Buffer
+	if err := writeCSV(&csvOut, r); err != nil {
+		return nil, err
+	}
+	if err := writeJSON(&jsonOut, r); err != nil {
+		return nil, err
+	}
+	if err := attachPart(mixed, "text/csv", name+".csv", csvOut.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := attachPart(mixed, "application/json", name+".json", jsonOut.Bytes()); err != nil {
+		return nil, err
+	}
+	mixed.Close()
+
+	return msg.Bytes(), nil
+}
+
+func qpPart(w *multipart.Writer, ctype string, body []byte) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", ctype)
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	p, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(p)
+	if _, err := qp.Write(body); err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
+
+func attachPart(w *multipart.Writer, ctype string, file string, body []byte) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", ctype+"; name=\""+file+"\"")
+	h.Set("Content-Disposition", "attachment; filename=\""+file+"\"")
+	h.Set("Content-Transfer-Encoding", "base64")
+	p, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	// Wrap base64 at 76 characters per RFC 2045
+	enc := base64.StdEncoding.EncodeToString(body)
+	for len(enc) > 76 {
+		fmt.Fprintf(p, "%v\r\n", enc[:76])
+		enc = enc[76:]
+	}
+	_, err = fmt.Fprintf(p, "%v\r\n", enc)
+
+	return err
+}
+
+// Send the report to the recipients sent in a single message
+func mailReport(c *mailConfig, to []string, r *report) error {
+	msg, err := mailMessage(c, to, r)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(c, to, msg)
+}
+
+func sendMail(c *mailConfig, to []string, msg []byte) error {
+	addr := net.JoinHostPort(c.host, strconv.Itoa(c.port))
+	tlsConf := &tls.Config{ServerName: c.host, InsecureSkipVerify: c.insecure}
+
+	var conn net.Conn
+	var err error
+	if c.security == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConf)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to connect to SMTP server %v: %v", addr, err)
+	}
+	s, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer s.Close()
+
+	if c.security == "starttls" {
+		if err := s.StartTLS(tlsConf); err != nil {
+			return fmt.Errorf("STARTTLS with %v failed: %v", addr, err)
+		}
+	}
+	if c.user != "" {
+		if err := s.Auth(smtp.PlainAuth("", c.user, c.pass, c.host)); err != nil {
+			return fmt.Errorf("SMTP auth with %v failed: %v", addr, err)
+		}
+	}
+	if err := s.Mail(c.from); err != nil {
+		return err
+	}
+	for _, t := range to {
+		if err := s.Rcpt(t); err != nil {
+			return fmt.Errorf("SMTP server refused recipient %v: %v", t, err)
+		}
+	}
+	d, err := s.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := d.Write(msg); err != nil {
+		return err
+	}
+	if err := d.Close(); err != nil {
+		return err
+	}
+
+	return s.Quit()
+}
@@ -0,0 +1,151 @@
+// email_test.go
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Just enough of an SMTP server to take one message, sent back on msgs
+func fakeSMTP(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := make(chan []byte, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				reply("250 fake")
+			case "MAIL", "RCPT":
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				var msg []byte
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					msg = append(msg, strings.TrimPrefix(l, ".")...)
+				}
+				msgs <- msg
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not here")
+			}
+		}
+	}()
+
+	return l.Addr().String(), msgs
+}
+
+func TestMailReport(t *testing.T) {
+	addr, msgs := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	c := &mailConfig{host: host, security: "none", from: "metrics@example.com", to: []string{"a@example.com", "b@example.com"}}
+	err := c.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.port, _ = strconv.Atoi(port)
+
+	// Run in October about September
+	m0 := fakeMonths(mustDay(t, "2026-09-30"), 1)[0]
+	r := newTeamReport(m0, "Retail & Co")
+	r.created = mustDay(t, "2026-10-19")
+	r.add(monthSection("Month Metrics", m0))
+	err = mailReport(c, c.to, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw []byte
+	select {
+	case raw = <-msgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message sent")
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(mime.WordDecoder)
+	subj, _ := dec.DecodeHeader(msg.Header.Get("Subject"))
+	for h, want := range map[string]string{
+		"From":         "metrics@example.com",
+		"To":           "a@example.com, b@example.com",
+		"Subject":      "ThreadFix metrics for September 2026 (Q3-2026)",
+		"MIME-Version": "1.0",
+	} {
+		got := msg.Header.Get(h)
+		if h == "Subject" {
+			got = subj
+		}
+		if got != want {
+			t.Errorf("%v is %q, want %q", h, got, want)
+		}
+	}
+
+	ctype, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || ctype != "multipart/mixed" {
+		t.Fatalf("Content-Type %v %v", ctype, err)
+	}
+	var parts, files []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts = append(parts, ct)
+		if f := p.FileName(); f != "" {
+			files = append(files, f)
+			if p.Header.Get("Content-Transfer-Encoding") != "base64" {
+				t.Errorf("%v isn't base64", f)
+			}
+		}
+	}
+	if want := []string{"multipart/alternative", "text/csv", "application/json"}; !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts are %v, want %v", parts, want)
+	}
+	// Named for the month reported on, not the day it was run
+	if want := []string{"tfmetrics-2026-09-Retail---Co.csv", "tfmetrics-2026-09-Retail---Co.json"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("attachments are %v, want %v", files, want)
+	}
+}
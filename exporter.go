@@ -0,0 +1,134 @@
+// exporter.go
+// exposes the metrics gathered by serve mode for Prometheus to scrape
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Prometheus text format of the current month plus how refreshes are going
+func (s *server) metrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if s.snap != nil {
+		w.Write(promMetrics(s.snap))
+	}
+	var p promWriter
+	p.metric("tfmetrics_refresh_errors_total", "Refreshes from ThreadFix that failed.", "counter")
+	p.sample("tfmetrics_refresh_errors_total", nil, float64(s.refreshErrs))
+	p.metric("tfmetrics_last_refresh_seconds", "How long the last refresh from ThreadFix took.", "gauge")
+	p.sample("tfmetrics_last_refresh_seconds", nil, s.lastTook)
+	if s.snap != nil {
+		p.metric("tfmetrics_last_success_timestamp_seconds", "Unix time of the last good refresh from ThreadFix.", "gauge")
+		p.sample("tfmetrics_last_success_timestamp_seconds", nil, float64(s.snap.taken.Unix()))
+	}
+	w.Write(p.b.Bytes())
+}
+
+// Render the summary and current month of a snapshot
+func promMetrics(snap *snapshot) []byte {
+	var p promWriter
+	m := snap.months[0]
+	month := fmt.Sprintf("%v-%02d", m.tStamp.Year(), int(m.tStamp.Month()))
+
+	// Summary
+	p.metric("tfmetrics_apps", "Applications in ThreadFix.", "gauge")
+	p.sample("tfmetrics_apps", nil, float64(snap.apps))
+	p.metric("tfmetrics_team_apps", "Applications in ThreadFix per LoB/Team.", "gauge")
+	for _, t := range sortedKeys(snap.teamCounts) {
+		p.sample("tfmetrics_team_apps", []string{"team", t}, float64(snap.teamCounts[t]))
+	}
+	p.metric("tfmetrics_team_critical_vulns", "Critical findings per LoB/Team from the team summary.", "gauge")
+	for _, t := range sortedKeys(snap.critsByLob) {
+		p.sample("tfmetrics_team_critical_vulns", []string{"team", t}, float64(snap.critsByLob[t]))
+	}
+
+	// Current month - the month label is only on the info metric so series
+	// don't change each month
+	p.metric("tfmetrics_month_info", "The month the metrics below are for.", "gauge")
+	p.sample("tfmetrics_month_info", []string{"month", month}, 1)
+	p.metric("tfmetrics_open_vulns_total", "Open findings, all but info, for the month.", "gauge")
+	p.sample("tfmetrics_open_vulns_total", nil, float64(m.totVulns))
+	p.metric("tfmetrics_open_vulns", "Open findings by LoB/Team and severity for the month.", "gauge")
+	for _, t := range sortedKeys(m.vulnByLob) {
+		promVulnCount(&p, "tfmetrics_open_vulns", []string{"team", t}, m.vulnByLob[t])
+	}
+	p.metric("tfmetrics_app_open_vulns", "Open findings by application and severity for the month.", "gauge")
+	apps := appVulnCounts(m.search, appFindings(m.search))
+	for _, a := range sortedKeys(apps) {
+		promVulnCount(&p, "tfmetrics_app_open_vulns", []string{"app", a}, apps[a])
+	}
+	p.metric("tfmetrics_apps_with_criticals", "Applications with critical findings for the month.", "gauge")
+	p.sample("tfmetrics_apps_with_criticals", nil, float64(len(m.critApps)))
+	p.metric("tfmetrics_apps_with_highs", "Applications with high findings for the month.", "gauge")
+	p.sample("tfmetrics_apps_with_highs", nil, float64(len(m.highApps)))
+	p.metric("tfmetrics_apps_critical_percent", "Percentage of applications with critical findings for the month.", "gauge")
+	p.sample("tfmetrics_apps_critical_percent", nil, m.percntCrit)
+	p.metric("tfmetrics_apps_high_percent", "Percentage of applications with high findings for the month.", "gauge")
+	p.sample("tfmetrics_apps_high_percent", nil, m.percntHigh)
+	p.metric("tfmetrics_team_assessed_apps", "Applications with findings per LoB/Team for the month.", "gauge")
+	for _, t := range sortedKeys(m.assessByLob) {
+		p.sample("tfmetrics_team_assessed_apps", []string{"team", t}, float64(m.assessByLob[t]))
+	}
+	p.metric("tfmetrics_tool_findings", "Findings reported per scanner for the month.", "gauge")
+	for _, t := range sortedKeys(m.toolUsage) {
+		p.sample("tfmetrics_tool_findings", []string{"tool", t}, float64(m.toolUsage[t]))
+	}
+	p.metric("tfmetrics_cwe_findings", "Findings per CWE for the month.", "gauge")
+	for _, c := range sortedKeys(m.topCWE) {
+		p.sample("tfmetrics_cwe_findings", []string{"cwe", c}, float64(m.topCWE[c]))
+	}
+
+	return p.b.Bytes()
+}
+
+func promVulnCount(p *promWriter, name string, labels []string, v VulnCount) {
+	p.sample(name, append(labels, "severity", "critical"), float64(v.crit))
+	p.sample(name, append(labels, "severity", "high"), float64(v.high))
+	p.sample(name, append(labels, "severity", "medium"), float64(v.med))
+	p.sample(name, append(labels, "severity", "low"), float64(v.low))
+}
+
+// Writes the Prometheus text exposition format
+type promWriter struct {
+	b bytes.Buffer
+}
+
+func (p *promWriter) metric(name string, help string, kind string) {
+	fmt.Fprintf(&p.b, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// labels are name / value pairs e.g. []string{"team", "Payments"}
+func (p *promWriter) sample(name string, labels []string, v float64) {
+	p.b.WriteString(name)
+	if len(labels) > 0 {
+		p.b.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				p.b.WriteString(",")
+			}
+			fmt.Fprintf(&p.b, "%v=\"%v\"", labels[i], promEscape.Replace(labels[i+1]))
+		}
+		p.b.WriteString("}")
+	}
+	fmt.Fprintf(&p.b, " %v\n", v)
+}
+
+var promEscape = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// Keys of a map in name order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k, _ := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,96 @@
+// exporter_test.go
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPromMetrics(t *testing.T) {
+	out := string(promMetrics(fakeSnap(mustDay(t, "2026-10-19"))))
+	lines := make(map[string]bool)
+	for _, l := range strings.Split(out, "\n") {
+		lines[l] = true
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"apps", "tfmetrics_apps 5"},
+		{"team apps", `tfmetrics_team_apps{team="Retail & Co"} 2`},
+		{"team criticals", `tfmetrics_team_critical_vulns{team="Pay"} 1`},
+		{"month", `tfmetrics_month_info{month="2026-10"} 1`},
+		{"findings", "tfmetrics_open_vulns_total 6"},
+		{"team findings", `tfmetrics_open_vulns{team="Pay",severity="critical"} 1`},
+		{"no team findings", `tfmetrics_open_vulns{team="Retail & Co",severity="high"} 0`},
+		{"app findings", `tfmetrics_app_open_vulns{app="pay-web",severity="high"} 1`},
+		{"apps with criticals", "tfmetrics_apps_with_criticals 2"},
+		{"apps with highs", "tfmetrics_apps_with_highs 1"},
+		{"critical percent", "tfmetrics_apps_critical_percent 40"},
+		{"high percent", "tfmetrics_apps_high_percent 20"},
+		{"assessed", `tfmetrics_team_assessed_apps{team="Pay"} 2`},
+		{"tools", `tfmetrics_tool_findings{tool="ZAP"} 2`},
+		{"CWEs", `tfmetrics_cwe_findings{cwe="CWE-79: CWE-79"} 3`},
+		{"gauge", "# TYPE tfmetrics_open_vulns gauge"},
+		{"help", "# HELP tfmetrics_apps Applications in ThreadFix."},
+	}
+	for _, tt := range tests {
+		if !lines[tt.want] {
+			t.Errorf("%v: no %q in\n%v", tt.name, tt.want, out)
+		}
+	}
+}
+
+func TestPromSample(t *testing.T) {
+	tests := []struct {
+		labels []string
+		v      float64
+		want   string
+	}{
+		{nil, 3, "m 3\n"},
+		{[]string{"team", "Pay"}, 2.5, "m{team=\"Pay\"} 2.5\n"},
+		{[]string{"team", "Pay", "severity", "high"}, 0, "m{team=\"Pay\",severity=\"high\"} 0\n"},
+		{[]string{"team", `A "B" \ C` + "\n"}, 1, `m{team="A \"B\" \\ C\n"} 1` + "\n"},
+	}
+	for _, tt := range tests {
+		var p promWriter
+		p.sample("m", tt.labels, tt.v)
+		if got := p.b.String(); got != tt.want {
+			t.Errorf("%v: got %q want %q", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *server
+		want []string
+		not  []string
+	}{
+		{"before the first refresh", &server{refreshErrs: 2},
+			[]string{"tfmetrics_refresh_errors_total 2"},
+			[]string{"tfmetrics_apps ", "tfmetrics_last_success_timestamp_seconds"}},
+		{"refreshed", &server{snap: fakeSnap(mustDay(t, "2026-10-19")), lastTook: 1.5},
+			[]string{"tfmetrics_apps 5", "tfmetrics_last_refresh_seconds 1.5",
+				"tfmetrics_last_success_timestamp_seconds " + fmt.Sprint(float64(mustDay(t, "2026-10-19").Unix()))},
+			nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.s.metrics(w, httptest.NewRequest("GET", "/metrics", nil))
+		out := w.Body.String()
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%v: no %q in\n%v", tt.name, want, out)
+			}
+		}
+		for _, not := range tt.not {
+			if strings.Contains(out, not) {
+				t.Errorf("%v: %q in\n%v", tt.name, not, out)
+			}
+		}
+	}
+}
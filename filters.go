@@ -0,0 +1,294 @@
+// filters.go
+// include / exclude filters applied to every search and to the list of teams
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// What a filter can match on
+var filterKinds = []string{"team", "app", "scanner", "cwe", "severity"}
+
+// Values to match for each kind. Names are kept lower case, apps can be given
+// by name or ThreadFix ID, CWEs as 79 or CWE-79 and severities by name.
+type filterSet struct {
+	teams        map[string]bool
+	apps         map[string]bool
+	scanners     map[string]bool
+	scannerNames []string // as given, for searching ThreadFix
+	cwes         map[int]bool
+	sevs         map[int]bool
+}
+
+// A finding is kept if it matches every kind that has includes and none of
+// the excludes. ThreadFix is only searched for what's included, the excludes
+// are dropped from what it sends back.
+type filters struct {
+	include filterSet
+	exclude filterSet
+	desc    []string // the filters as given e.g. include team=Payments
+	teamIds []int    // ThreadFix IDs of the teams included, set by filterTeams
+	appIds  []int    // and of the apps included
+}
+
+// What a ThreadFix search is narrowed to, nothing narrows it to everything
+type searchScope struct {
+	teams    []int
+	apps     []int
+	scanners []string
+	cwes     []int
+}
+
+// Add kind=value[,value...] to the set
+func (f *filterSet) add(spec string) error {
+	kind, vals, ok := strings.Cut(spec, "=")
+	if !ok || !oneOf(kind, filterKinds) || strings.TrimSpace(vals) == "" {
+		return fmt.Errorf("filters look like team=Payments or scanner=Fortify,ZAP, kinds are %v not %v",
+			strings.Join(filterKinds, ", "), spec)
+	}
+	for _, v := range splitList(vals) {
+		lv := strings.ToLower(v)
+		switch kind {
+		case "team":
+			f.teams = addName(f.teams, lv)
+		case "app":
+			f.apps = addName(f.apps, lv)
+		case "scanner":
+			f.scanners = addName(f.scanners, lv)
+			f.scannerNames = append(f.scannerNames, v)
+		case "cwe":
+			n, err := strconv.Atoi(strings.TrimPrefix(lv, "cwe-"))
+			if err != nil {
+				return fmt.Errorf("CWEs look like 79 or CWE-79 not %v", v)
+			}
+			if f.cwes == nil {
+				f.cwes = make(map[int]bool)
+			}
+			f.cwes[n] = true
+		case "severity":
+			n, ok := sevValues[lv]
+			if !ok {
+				return fmt.Errorf("severities are critical, high, medium, low or info not %v", v)
+			}
+			if f.sevs == nil {
+				f.sevs = make(map[int]bool)
+			}
+			f.sevs[n] = true
+		}
+	}
+
+	return nil
+}
+
+func addName(m map[string]bool, name string) map[string]bool {
+	if m == nil {
+		m = make(map[string]bool)
+	}
+	m[name] = true
+
+	return m
+}
+
+// Add include or exclude filters like "team=Payments;scanner=Fortify,ZAP". A
+// comma can be used in place of the semicolon as each filter starts with kind=.
+func (f *filters) addAll(how string, v string) error {
+	var specs []string
+	for _, p := range strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' }) {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+		case strings.Contains(p, "=") || len(specs) == 0:
+			specs = append(specs, p)
+		default:
+			specs[len(specs)-1] += "," + p
+		}
+	}
+	set := &f.include
+	if how == "exclude" {
+		set = &f.exclude
+	}
+	for _, spec := range specs {
+		err := set.add(spec)
+		if err != nil {
+			return err
+		}
+		f.desc = append(f.desc, how+" "+spec)
+	}
+
+	return nil
+}
+
+// Filters given on the command line with --include and --exclude
+type filterFlag struct {
+	how string
+	f   *filters
+}
+
+func (ff filterFlag) String() string {
+	return ""
+}
+
+func (ff filterFlag) Set(v string) error {
+	return ff.f.addAll(ff.how, v)
+}
+
+func addFilterFlags(fs *flag.FlagSet) {
+	fs.Var(filterFlag{"include", &findingFilter}, "include",
+		"only include findings matching kind=value[,value], kinds are "+strings.Join(filterKinds, ", ")+", can be repeated")
+	fs.Var(filterFlag{"exclude", &findingFilter}, "exclude",
+		"leave out findings matching kind=value[,value], can be repeated")
+}
+
+// Make sure the filters leave something to search for
+func (f *filters) check() error {
+	if len(f.severities(searchSeverities)) == 0 {
+		return errors.New("The severity filters leave no severities to search for\n")
+	}
+
+	return nil
+}
+
+func (f *filters) active() bool {
+	return !f.include.empty() || !f.exclude.empty()
+}
+
+func (f *filterSet) empty() bool {
+	return len(f.teams) == 0 && len(f.apps) == 0 && len(f.scanners) == 0 && len(f.cwes) == 0 && len(f.sevs) == 0
+}
+
+// The severities to search for once the severity filters are applied
+func (f *filters) severities(sevs []int) []int {
+	var keep []int
+	for _, s := range sevs {
+		if (len(f.include.sevs) == 0 || f.include.sevs[s]) && !f.exclude.sevs[s] {
+			keep = append(keep, s)
+		}
+	}
+
+	return keep
+}
+
+func (f *filters) team(name string) bool {
+	n := strings.ToLower(name)
+
+	return (len(f.include.teams) == 0 || f.include.teams[n]) && !f.exclude.teams[n]
+}
+
+func (f *filters) app(name string, id int) bool {
+	n, i := strings.ToLower(name), strconv.Itoa(id)
+	if len(f.include.apps) > 0 && !f.include.apps[n] && !f.include.apps[i] {
+		return false
+	}
+
+	return !f.exclude.apps[n] && !f.exclude.apps[i]
+}
+
+func (f *filters) scanner(name string) bool {
+	n := strings.ToLower(name)
+
+	return (len(f.include.scanners) == 0 || f.include.scanners[n]) && !f.exclude.scanners[n]
+}
+
+// Drop the teams and apps filtered out so the app counts match the findings,
+// noting the IDs of those included for the searches
+func (f *filters) filterTeams(t *tf.TeamResp) {
+	f.teamIds, f.appIds = nil, nil
+	if !f.active() {
+		return
+	}
+	var tms []tf.Team
+	for _, tm := range t.Tm {
+		if !f.team(tm.Name) {
+			continue
+		}
+		if len(f.include.teams) > 0 {
+			f.teamIds = append(f.teamIds, tm.Id)
+		}
+		var apps []tf.App
+		for _, a := range tm.Apps {
+			if f.app(a.Name, a.Id) {
+				apps = append(apps, a)
+				if len(f.include.apps) > 0 {
+					f.appIds = append(f.appIds, a.Id)
+				}
+			}
+		}
+		tm.Apps = apps
+		tms = append(tms, tm)
+	}
+	t.Tm = tms
+}
+
+// What to search ThreadFix for so only the findings included are sent back.
+// False if the teams or apps included aren't in ThreadFix, leaving nothing to
+// search for.
+func (f *filters) scope() (searchScope, bool) {
+	var sc searchScope
+	if (len(f.include.teams) > 0 && len(f.teamIds) == 0) || (len(f.include.apps) > 0 && len(f.appIds) == 0) {
+		return sc, false
+	}
+	sc.teams, sc.apps, sc.scanners = f.teamIds, f.appIds, f.include.scannerNames
+	for c, _ := range f.include.cwes {
+		sc.cwes = append(sc.cwes, c)
+	}
+	sort.Ints(sc.cwes)
+
+	return sc, true
+}
+
+// Narrow the search s to the findings included
+func (sc searchScope) narrow(s *tf.Search) {
+	if len(sc.teams) > 0 {
+		tf.TeamSearch(s, sc.teams...)
+	}
+	if len(sc.apps) > 0 {
+		tf.AppSearch(s, sc.apps...)
+	}
+	if len(sc.scanners) > 0 {
+		tf.ScannerSearch(s, sc.scanners...)
+	}
+	if len(sc.cwes) > 0 {
+		tf.CweSearch(s, sc.cwes...)
+	}
+}
+
+// Drop the findings excluded, ThreadFix has already left out those not
+// included. Scanners filtered out are also taken off the findings that are
+// kept so tool counts only show the scanners asked for.
+func (f *filters) filterSearch(srch *tf.SrchResp) {
+	if !f.active() {
+		return
+	}
+	var keep []tf.Result
+	for _, r := range srch.Results {
+		n, id := strings.ToLower(r.Apps.Name), strconv.Itoa(r.Apps.Id)
+		if f.exclude.teams[strings.ToLower(r.Team.Name)] || f.exclude.apps[n] || f.exclude.apps[id] {
+			continue
+		}
+		if f.exclude.cwes[r.CweVuln.Id] || f.exclude.sevs[r.Severity.Value] {
+			continue
+		}
+		if len(f.include.scanners) > 0 || len(f.exclude.scanners) > 0 {
+			var scanners []string
+			for _, s := range r.Scanners {
+				if f.scanner(s) {
+					scanners = append(scanners, s)
+				}
+			}
+			// Keep findings without scanners unless scanners were asked for
+			if len(scanners) == 0 && (len(f.include.scanners) > 0 || len(r.Scanners) > 0) {
+				continue
+			}
+			r.Scanners = scanners
+		}
+		keep = append(keep, r)
+	}
+	srch.Results = keep
+}
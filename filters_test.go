@@ -0,0 +1,170 @@
+// filters_test.go
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// The apps and scanners of the findings kept e.g. pay-web:ZAP+Fortify
+func keptFindings(srch *tf.SrchResp) string {
+	var kept []string
+	for _, r := range srch.Results {
+		kept = append(kept, r.Apps.Name+":"+strings.Join(r.Scanners, "+"))
+	}
+
+	return strings.Join(kept, " ")
+}
+
+func TestFilterSearch(t *testing.T) {
+	tests := []struct {
+		include string
+		exclude string
+		want    string
+	}{
+		{"", "", "pay-web:ZAP pay-web:ZAP+Fortify pay-api:Fortify shop:Burp shop:Burp cart:Burp"},
+		{"", "app=shop", "pay-web:ZAP pay-web:ZAP+Fortify pay-api:Fortify cart:Burp"},
+		{"", "team=retail & co", "pay-web:ZAP pay-web:ZAP+Fortify pay-api:Fortify"},
+		{"", "cwe=79", "pay-web:ZAP+Fortify shop:Burp shop:Burp"},
+		{"", "severity=medium,low", "pay-web:ZAP pay-web:ZAP+Fortify shop:Burp"},
+		{"", "scanner=Fortify", "pay-web:ZAP pay-web:ZAP shop:Burp shop:Burp cart:Burp"},
+		// ThreadFix only sends back findings included, these are left to it
+		{"team=Pay;cwe=22", "", "pay-web:ZAP pay-web:ZAP+Fortify pay-api:Fortify shop:Burp shop:Burp cart:Burp"},
+		// but the other scanners of the findings it sends back are taken off
+		{"scanner=zap", "", "pay-web:ZAP pay-web:ZAP"},
+		{"team=Pay,scanner=Fortify,Burp", "cwe=89", "pay-api:Fortify shop:Burp shop:Burp cart:Burp"},
+	}
+	for _, tt := range tests {
+		var f filters
+		if tt.include != "" {
+			if err := f.addAll("include", tt.include); err != nil {
+				t.Errorf("include %v: %v", tt.include, err)
+				continue
+			}
+		}
+		if tt.exclude != "" {
+			if err := f.addAll("exclude", tt.exclude); err != nil {
+				t.Errorf("exclude %v: %v", tt.exclude, err)
+				continue
+			}
+		}
+		srch := fakeSearch()
+		f.filterSearch(srch)
+		if got := keptFindings(srch); got != tt.want {
+			t.Errorf("include %q exclude %q: got %q want %q", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	tests := []struct {
+		include string
+		exclude string
+		want    searchScope
+		ok      bool
+	}{
+		{"", "", searchScope{}, true},
+		// Included teams are searched for by their ThreadFix IDs
+		{"team=pay", "", searchScope{teams: []int{10}}, true},
+		{"team=Pay,Retail & Co", "", searchScope{teams: []int{10, 20}}, true},
+		{"app=shop", "", searchScope{apps: []int{3}}, true},
+		{"app=2", "", searchScope{apps: []int{2}}, true},
+		{"scanner=ZAP,Fortify", "", searchScope{scanners: []string{"ZAP", "Fortify"}}, true},
+		{"cwe=CWE-89,79", "", searchScope{cwes: []int{79, 89}}, true},
+		// Excludes are left out of the search
+		{"team=Pay", "app=pay-web;scanner=ZAP", searchScope{teams: []int{10}}, true},
+		{"severity=critical", "team=Pay", searchScope{}, true},
+		{"team=Nobody", "", searchScope{}, false},
+		{"team=Pay;app=shop", "", searchScope{}, false},
+	}
+	for _, tt := range tests {
+		var f filters
+		if tt.include != "" {
+			f.addAll("include", tt.include)
+		}
+		if tt.exclude != "" {
+			f.addAll("exclude", tt.exclude)
+		}
+		teams := tf.TeamResp{Tm: []tf.Team{
+			{Id: 10, Name: "Pay", Apps: []tf.App{{Id: 1, Name: "pay-web"}, {Id: 2, Name: "pay-api"}}},
+			{Id: 20, Name: "Retail & Co", Apps: []tf.App{{Id: 3, Name: "shop"}}},
+		}}
+		f.filterTeams(&teams)
+		got, ok := f.scope()
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("include %q exclude %q: got %+v %v want %+v %v", tt.include, tt.exclude, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFilterSpecs(t *testing.T) {
+	tests := []struct {
+		spec string
+		desc string
+		bad  bool
+	}{
+		{"team=Pay", "include team=Pay", false},
+		{"team=Pay;app=shop, cart", "include team=Pay|include app=shop,cart", false},
+		{"cwe=79,CWE-89", "include cwe=79,CWE-89", false},
+		{"team", "", true},
+		{"team=", "", true},
+		{"colour=red", "", true},
+		{"cwe=XSS", "", true},
+		{"severity=urgent", "", true},
+	}
+	for _, tt := range tests {
+		var f filters
+		err := f.addAll("include", tt.spec)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error", tt.spec)
+			}
+			continue
+		}
+		if got := strings.Join(f.desc, "|"); err != nil || got != tt.desc {
+			t.Errorf("%v: got %q %v want %q", tt.spec, got, err, tt.desc)
+		}
+	}
+}
+
+func TestFilterTeams(t *testing.T) {
+	tests := []struct {
+		include string
+		exclude string
+		want    string
+	}{
+		{"", "", "Pay:pay-web,pay-api Retail & Co:shop"},
+		{"team=retail & co", "", "Retail & Co:shop"},
+		{"app=2", "", "Pay:pay-api Retail & Co:"},
+		{"", "app=pay-web", "Pay:pay-api Retail & Co:shop"},
+		{"severity=critical", "", "Pay:pay-web,pay-api Retail & Co:shop"},
+	}
+	for _, tt := range tests {
+		var f filters
+		if tt.include != "" {
+			f.addAll("include", tt.include)
+		}
+		if tt.exclude != "" {
+			f.addAll("exclude", tt.exclude)
+		}
+		teams := tf.TeamResp{Tm: []tf.Team{
+			{Name: "Pay", Apps: []tf.App{{Id: 1, Name: "pay-web"}, {Id: 2, Name: "pay-api"}}},
+			{Name: "Retail & Co", Apps: []tf.App{{Id: 3, Name: "shop"}}},
+		}}
+		f.filterTeams(&teams)
+		var got []string
+		for _, tm := range teams.Tm {
+			var apps []string
+			for _, a := range tm.Apps {
+				apps = append(apps, a.Name)
+			}
+			got = append(got, tm.Name+":"+strings.Join(apps, ","))
+		}
+		if g := strings.Join(got, " "); g != tt.want {
+			t.Errorf("include %q exclude %q: got %q want %q", tt.include, tt.exclude, g, tt.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// forecast.go
+// next quarter forecasts from the monthly series by linear regression and
+// exponential smoothing
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Full months forecasts are worked out from
+var forecastMonths = 12
+
+// Holt's exponential smoothing - how fast the level and the trend follow the
+// latest months, 0 to 1
+var smoothAlpha = 0.5
+var smoothBeta = 0.3
+
+// Confidence of the forecast bands in percent and the z score for each
+var forecastConfidence = 95
+
+var confidenceZ = map[int]float64{80: 1.2816, 90: 1.6449, 95: 1.96, 99: 2.5758}
+
+// Monthly series forecast and how the next quarter is worked out from them
+type forecastMetric struct {
+	name  string
+	value func(m *tfMonth) float64
+	sum   bool    // the quarter is the sum of its months, otherwise their average
+	max   float64 // highest possible value, 0 for no limit
+}
+
+var forecastMetrics = []forecastMetric{
+	{"Findings found (quarter total)", func(m *tfMonth) float64 { return float64(m.totVulns) }, true, 0},
+	{"Apps with criticals (monthly average)", func(m *tfMonth) float64 { return float64(len(m.critApps)) }, false, 0},
+	{"% of apps with criticals (monthly average)", func(m *tfMonth) float64 { return m.percntCrit }, false, 100},
+}
+
+// A forecast for each of the months ahead with its confidence band
+type forecast struct {
+	values []float64
+	low    []float64
+	high   []float64
+}
+
+// A metric's forecast for the next quarter by one method
+type quarterForecast struct {
+	metric string
+	method string
+	value  float64
+	low    float64
+	high   float64
+}
+
+// Least squares fit of ys over their index
+func linearFit(ys []float64) (slope float64, intercept float64) {
+	n := float64(len(ys))
+	if n < 2 {
+		if n == 1 {
+			return 0, ys[0]
+		}
+		return 0, 0
+	}
+	var sx, sy, sxy, sxx float64
+	for i, y := range ys {
+		x := float64(i)
+		sx += x
+		sy += y
+		sxy += x * y
+		sxx += x * x
+	}
+	slope = (n*sxy - sx*sy) / (n*sxx - sx*sx)
+
+	return slope, (sy - slope*sx) / n
+}
+
+// Forecast steps months past ys, oldest first and at least 3, by linear
+// regression with a prediction interval
+func linearForecast(ys []float64, steps int, z float64) forecast {
+	slope, intercept := linearFit(ys)
+	n := float64(len(ys))
+	var sse, mean, sxx float64
+	for i, y := range ys {
+		e := y - (intercept + slope*float64(i))
+		sse += e * e
+	}
+	mean = (n - 1) / 2
+	for i, _ := range ys {
+		sxx += (float64(i) - mean) * (float64(i) - mean)
+	}
+	s := math.Sqrt(sse / (n - 2))
+
+	var f forecast
+	for h := 1; h <= steps; h++ {
+		x := n - 1 + float64(h)
+		v := intercept + slope*x
+		band := z * s * math.Sqrt(1+1/n+(x-mean)*(x-mean)/sxx)
+		f.values = append(f.values, v)
+		f.low = append(f.low, v-band)
+		f.high = append(f.high, v+band)
+	}
+
+	return f
+}
+
+// Forecast steps months past ys, oldest first and at least 3, by Holt's
+// linear exponential smoothing. The band widens with the square root of the
+// months ahead from the spread of the one month ahead errors.
+func smoothForecast(ys []float64, steps int, z float64) forecast {
+	level, trend := ys[0], ys[1]-ys[0]
+	var sse float64
+	for i := 1; i < len(ys); i++ {
+		if i > 1 {
+			e := ys[i] - (level + trend)
+			sse += e * e
+		}
+		prev := level
+		level = smoothAlpha*ys[i] + (1-smoothAlpha)*(level+trend)
+		trend = smoothBeta*(level-prev) + (1-smoothBeta)*trend
+	}
+	s := math.Sqrt(sse / float64(len(ys)-2))
+
+	var f forecast
+	for h := 1; h <= steps; h++ {
+		v := level + float64(h)*trend
+		band := z * s * math.Sqrt(float64(h))
+		f.values = append(f.values, v)
+		f.low = append(f.low, v-band)
+		f.high = append(f.high, v+band)
+	}
+
+	return f
+}
+
+// Forecast each metric for the quarter after the one ending with qEnd by both
+// methods from ms, newest first, and the number of months used. Partial months
+// are left out as they'd drag the trend down. Empty if there aren't 3 full
+// months.
+func quarterForecasts(ms []*tfMonth, qEnd time.Time) ([]quarterForecast, int) {
+	var full []*tfMonth
+	for i := len(ms) - 1; i >= 0; i-- {
+		if !ms[i].mpartial {
+			full = append(full, ms[i])
+		}
+	}
+	if len(full) < 3 {
+		return nil, len(full)
+	}
+	// Months from the last full one to the end of the next quarter
+	last := full[len(full)-1].tStamp
+	steps := (qEnd.Year()-last.Year())*12 + int(qEnd.Month()-last.Month()) + 3
+
+	z := confidenceZ[forecastConfidence]
+	var out []quarterForecast
+	for _, fm := range forecastMetrics {
+		var ys []float64
+		for _, m := range full {
+			ys = append(ys, fm.value(m))
+		}
+		for _, method := range []struct {
+			name string
+			f    forecast
+		}{
+			{"Linear regression", linearForecast(ys, steps, z)},
+			{"Exponential smoothing", smoothForecast(ys, steps, z)},
+		} {
+			qf := quarterForecast{metric: fm.name, method: method.name}
+			for h := steps - 3; h < steps; h++ {
+				qf.value += method.f.values[h]
+				qf.low += method.f.low[h]
+				qf.high += method.f.high[h]
+			}
+			if !fm.sum {
+				qf.value, qf.low, qf.high = qf.value/3, qf.low/3, qf.high/3
+			}
+			qf.value, qf.low, qf.high = bound(qf.value, fm.max), bound(qf.low, fm.max), bound(qf.high, fm.max)
+			out = append(out, qf)
+		}
+	}
+
+	return out, len(full)
+}
+
+// Forecast the quarter after the one ending with the month end from the
+// forecastMonths months up to it
+func fetchForecasts(end time.Time) ([]quarterForecast, int, error) {
+	ms, err := fetchHistory(end, forecastMonths)
+	if err != nil {
+		return nil, 0, err
+	}
+	fcs, n := quarterForecasts(ms, quarterLastMonth(end))
+
+	return fcs, n, nil
+}
+
+// v kept to 0 or more and to max if there is one
+func bound(v float64, max float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if max > 0 && v > max {
+		return max
+	}
+
+	return v
+}
+
+// The last month of the quarter t is in
+func quarterLastMonth(t time.Time) time.Time {
+	ahead := (quarterEnd[quarterNum(t.Month())] - int(t.Month()) + 12) % 12
+
+	return monthEnd(time.Date(t.Year(), t.Month()+time.Month(ahead), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// The label of the quarter after the one t is in
+func nextQuarter(t time.Time) string {
+	n := quarterLastMonth(t).AddDate(0, 0, 1)
+
+	return getQuarter(n.Month(), n.Year())
+}
+
+///////////////////////////////////////
+// Setters                           //
+///////////////////////////////////////
+
+func setForecastMonths(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 4 {
+		return fmt.Errorf("must be 4 or more months not %v", v)
+	}
+	forecastMonths = n
+
+	return nil
+}
+
+func smoothSetter(to *float64) func(v string) error {
+	return func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			return fmt.Errorf("must be more than 0 and up to 1 not %v", v)
+		}
+		*to = f
+
+		return nil
+	}
+}
+
+func setForecastConfidence(v string) error {
+	n, err := strconv.Atoi(v)
+	if _, ok := confidenceZ[n]; err != nil || !ok {
+		return fmt.Errorf("must be 80, 90, 95 or 99 not %v", v)
+	}
+	forecastConfidence = n
+
+	return nil
+}
@@ -0,0 +1,178 @@
+// forecast_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func nearAll(a []float64, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !near(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestLinearFit(t *testing.T) {
+	tests := []struct {
+		ys        []float64
+		slope     float64
+		intercept float64
+	}{
+		{nil, 0, 0},
+		{[]float64{5}, 0, 5},
+		{[]float64{3, 1}, -2, 3},
+		{[]float64{2, 2, 2}, 0, 2},
+		{[]float64{1, 3, 5, 7}, 2, 1},
+		{[]float64{0, 2, 2}, 1, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		slope, intercept := linearFit(tt.ys)
+		if !near(slope, tt.slope) || !near(intercept, tt.intercept) {
+			t.Errorf("%v: got %v, %v want %v, %v", tt.ys, slope, intercept, tt.slope, tt.intercept)
+		}
+	}
+}
+
+func TestLinearForecast(t *testing.T) {
+	// The 0, 2, 2 band is s * sqrt(1 + 1/n + (x-mean)^2/sxx) with s = sqrt(2/3),
+	// mean 1 and sxx 2
+	band := func(x float64) float64 {
+		return math.Sqrt(2.0/3) * math.Sqrt(1+1.0/3+(x-1)*(x-1)/2)
+	}
+	tests := []struct {
+		name   string
+		ys     []float64
+		steps  int
+		z      float64
+		values []float64
+		bands  []float64
+	}{
+		{"straight line", []float64{1, 3, 5, 7}, 2, 1.96, []float64{9, 11}, []float64{0, 0}},
+		{"flat", []float64{4, 4, 4}, 3, 1.96, []float64{4, 4, 4}, []float64{0, 0, 0}},
+		{"noisy", []float64{0, 2, 2}, 2, 1, []float64{10.0 / 3, 13.0 / 3}, []float64{band(3), band(4)}},
+		{"noisy at 95%", []float64{0, 2, 2}, 1, 1.96, []float64{10.0 / 3}, []float64{1.96 * band(3)}},
+	}
+	for _, tt := range tests {
+		f := linearForecast(tt.ys, tt.steps, tt.z)
+		var low, high []float64
+		for i, v := range tt.values {
+			low = append(low, v-tt.bands[i])
+			high = append(high, v+tt.bands[i])
+		}
+		if !nearAll(f.values, tt.values) || !nearAll(f.low, low) || !nearAll(f.high, high) {
+			t.Errorf("%v: got %v %v %v want %v %v %v", tt.name, f.values, f.low, f.high, tt.values, low, high)
+		}
+	}
+}
+
+func TestSmoothForecast(t *testing.T) {
+	defer func(a float64, b float64) { smoothAlpha, smoothBeta = a, b }(smoothAlpha, smoothBeta)
+	tests := []struct {
+		name   string
+		ys     []float64
+		alpha  float64
+		beta   float64
+		steps  int
+		values []float64
+		bands  []float64
+	}{
+		{"straight line", []float64{1, 3, 5, 7}, 0.5, 0.3, 2, []float64{9, 11}, []float64{0, 0}},
+		{"flat", []float64{4, 4, 4}, 0.5, 0.3, 2, []float64{4, 4}, []float64{0, 0}},
+		// Level 0 trend 2, then level 2 trend 2, then a miss of -2 leaves
+		// level 3 trend 1.7 and s 2
+		{"levels off", []float64{0, 2, 2}, 0.5, 0.3, 2, []float64{4.7, 6.4}, []float64{2, 2 * math.Sqrt2}},
+		// Alpha and beta of 1 follow the last month and its change
+		{"no smoothing", []float64{1, 2, 4, 8}, 1, 1, 2, []float64{12, 16}, nil},
+	}
+	for _, tt := range tests {
+		smoothAlpha, smoothBeta = tt.alpha, tt.beta
+		f := smoothForecast(tt.ys, tt.steps, 1)
+		if !nearAll(f.values, tt.values) {
+			t.Errorf("%v: got %v want %v", tt.name, f.values, tt.values)
+			continue
+		}
+		if tt.bands == nil {
+			continue
+		}
+		for i, v := range tt.values {
+			if !near(f.low[i], v-tt.bands[i]) || !near(f.high[i], v+tt.bands[i]) {
+				t.Errorf("%v: month %v band %v to %v want ±%v", tt.name, i+1, f.low[i], f.high[i], tt.bands[i])
+			}
+		}
+	}
+}
+
+func TestQuarterForecasts(t *testing.T) {
+	defer func(d map[int]string, e map[int]int) { qtrDefs, quarterEnd = d, e }(qtrDefs, quarterEnd)
+	end := mustDay(t, "2026-10-19")
+	tests := []struct {
+		name     string
+		quarters []string
+		months   int
+		findings []int // full months' findings, oldest first, if not fakeSearch's 6
+		n        int
+		want     map[string]float64
+	}{
+		// October is partial so May to September are used
+		{"flat", nil, 6, nil, 5, map[string]float64{
+			"Findings found (quarter total)":             18,
+			"Apps with criticals (monthly average)":      2,
+			"% of apps with criticals (monthly average)": 40,
+		}},
+		// 60, 70 and 80 for October to December then 90 + 100 + 110
+		{"rising", nil, 6, []int{10, 20, 30, 40, 50}, 5, map[string]float64{
+			"Findings found (quarter total)": 300,
+		}},
+		// Falling to below nothing is kept at 0
+		{"falling", nil, 4, []int{50, 30, 10}, 3, map[string]float64{
+			"Findings found (quarter total)": 0,
+		}},
+		{"too few", nil, 3, nil, 2, nil},
+		// October ends Q3 so the next quarter is November to January, 70 + 80 + 90
+		{"wrapping", wrappingQuarters, 6, []int{10, 20, 30, 40, 50}, 5, map[string]float64{
+			"Findings found (quarter total)": 240,
+		}},
+	}
+	for _, tt := range tests {
+		useQuarters(t, tt.quarters)
+		ms := fakeMonths(end, tt.months)
+		for i, v := range tt.findings {
+			ms[len(tt.findings)-i].totVulns = v
+		}
+		fcs, n := quarterForecasts(ms, quarterLastMonth(end))
+		if n != tt.n {
+			t.Errorf("%v: used %v months want %v", tt.name, n, tt.n)
+		}
+		if tt.want == nil {
+			if fcs != nil {
+				t.Errorf("%v: got forecasts %v", tt.name, fcs)
+			}
+			continue
+		}
+		if len(fcs) != 2*len(forecastMetrics) {
+			t.Errorf("%v: got %v forecasts", tt.name, len(fcs))
+			continue
+		}
+		for _, qf := range fcs {
+			want, ok := tt.want[qf.metric]
+			if !ok {
+				continue
+			}
+			// Straight lines have no band
+			if !near(qf.value, want) || !near(qf.low, want) || !near(qf.high, want) {
+				t.Errorf("%v: %v by %v got %v (%v to %v) want %v", tt.name, qf.metric, qf.method, qf.value, qf.low, qf.high, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// helpers_test.go
+// fake ThreadFix findings and snapshots shared by the tests
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Six findings over two LoB/Teams, four apps and three scanners
+func fakeSearch() *tf.SrchResp {
+	var s tf.SrchResp
+	add := func(team string, app string, sev int, cwe int, scanners ...string) {
+		var r tf.Result
+		r.Team.Name = team
+		r.Apps.Name = app
+		r.Severity.Value = sev
+		r.CweVuln.Id = cwe
+		r.CweVuln.Name = "CWE-" + strconv.Itoa(cwe)
+		r.Scanners = scanners
+		s.Results = append(s.Results, r)
+	}
+	add("Pay", "pay-web", 5, 79, "ZAP")
+	add("Pay", "pay-web", 4, 89, "ZAP", "Fortify")
+	add("Pay", "pay-api", 3, 79, "Fortify")
+	add("Retail & Co", "shop", 5, 22, "Burp")
+	add("Retail & Co", "shop", 2, 22, "Burp")
+	add("Retail & Co", "cart", 2, 79, "Burp")
+
+	return &s
+}
+
+// n months of fakeSearch findings, newest first, ending with end
+func fakeMonths(end time.Time, n int) []*tfMonth {
+	var ms []*tfMonth
+	t := end
+	for i := 0; i < n; i++ {
+		m := &tfMonth{tStamp: t, quarter: getQuarter(t.Month(), t.Year())}
+		m.mpartial = t.Day() != lastDate(int(t.Month()), t.Year())
+		calcMonth(m, fakeSearch(), 5)
+		ms = append(ms, m)
+		t = previousMonth(t)
+	}
+
+	return ms
+}
+
+// A serve snapshot of historyMonths months ending with end
+func fakeSnap(end time.Time) *snapshot {
+	return &snapshot{
+		taken:      end,
+		apps:       5,
+		teamCounts: map[string]int{"Pay": 3, "Retail & Co": 2},
+		critsByLob: map[string]int{"Pay": 1, "Retail & Co": 1},
+		teamApps:   map[string][]string{"Pay": {"pay-web", "pay-api", "pay-x"}, "Retail & Co": {"shop", "cart"}},
+		months:     fakeMonths(end, historyMonths),
+	}
+}
+
+// A date like 2026-10-19 for the tests
+func mustDay(t *testing.T, v string) time.Time {
+	t.Helper()
+	d, err := time.Parse(dayKey, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+// Fiscal calendars, the second with a Q4 from November to January
+var (
+	calendarQuarters = []string{"Q1", "Q1", "Q1", "Q2", "Q2", "Q2", "Q3", "Q3", "Q3", "Q4", "Q4", "Q4"}
+	wrappingQuarters = []string{"Q4", "Q1", "Q1", "Q1", "Q2", "Q2", "Q2", "Q3", "Q3", "Q3", "Q4", "Q4"}
+)
+
+// Switch to the fiscal calendar qs, the calendar quarters if nil. Callers
+// put back qtrDefs and quarterEnd when they're done.
+func useQuarters(t *testing.T, qs []string) {
+	t.Helper()
+	if qs == nil {
+		qs = calendarQuarters
+	}
+	if err := setQuarters(qs); err != nil {
+		t.Fatal(err)
+	}
+}
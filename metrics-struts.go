@@ -5,6 +5,8 @@ package main
 import (
 	"net/http"
 	"time"
+
+	tf "github.com/mtesauro/tfclient"
 )
 
 // Data structures to handle metrics from ThreadFix API as documented at
@@ -29,14 +31,23 @@ type tfMonth struct {
 	highApps    map[string]int       // map of [app name] count of highs
 	percntHigh  float64              // apps with highs / total apps * 100 e.g. 23.72%
 	// maps of [app name] vuln score for the next 2
-	bestApps      map[string]int       // top 10 apps with least vuln score
-	bAppsCnt      map[string]VulnCount //For each best app, the Vuln counts for that app
-	worstApps     map[string]int       // top 10 apps with the greatest vuln score
-	wAppsCnt      map[string]VulnCount //For each worst app, the Vuln counts for that app
-	toolUsage     map[string]int       // map of [tool name] / count of usage
-	topCWE        map[string]int       // top 10 CWEs in this month's findings
-	trackerCount  map[string]int       // map of [app name] issue tracker count
-	percntTracker float64              // apps with issue tracker / total apps
+	bestApps      map[string]int                       // top 10 apps with least vuln score
+	bAppsCnt      map[string]VulnCount                 //For each best app, the Vuln counts for that app
+	worstApps     map[string]int                       // top 10 apps with the greatest vuln score
+	wAppsCnt      map[string]VulnCount                 //For each worst app, the Vuln counts for that app
+	toolUsage     map[string]int                       // map of [tool name] / count of usage
+	topCWE        map[string]int                       // top 10 CWEs in this month's findings
+	cweBySev      map[int]map[string]int               // map of [severity][CWE] count of findings
+	cweScore      map[string]int                       // map of [CWE] findings weighted by severity with vulnWeight
+	cweCats       map[string]map[string]VulnCount      // map of [scheme][category] vuln counts, see categories.go
+	cweCatsByLob  map[string]map[string]map[string]int // map of [scheme][LoB/Team name][category] count of findings
+	scansByTool   map[string]int                       // map of [scanner] scans / uploads, nil without scan history
+	scansByLob    map[string]int                       // map of [LoB/Team name] scans / uploads
+	totScans      int                                  // scans / uploads including clean ones
+	cleanScans    int                                  // scans / uploads without findings
+	trackerCount  map[string]int                       // map of [app name] issue tracker count
+	percntTracker float64                              // apps with issue tracker / total apps
+	search        *tf.SrchResp                         // the findings the month's metrics were calculated from
 }
 
 type VulnCount struct {
@@ -60,12 +71,20 @@ type tfQuarter struct {
 	highApps   map[string]int // map of [app name] count of highs
 	percntHigh float64        // apps with highs / total apps * 100 e.g. 23.72%
 	// maps of [app name] vuln score for the next 2
-	bestApps      map[string]int // top 10 apps with least vuln score
-	worstApps     map[string]int // top 10 apps with the greatest vuln score
-	toolUsage     map[string]int // map of [tool name] / count of usage
-	topCWE        map[string]int // top 10 CWEs in this month's findings
-	trackerCount  map[string]int // map of [app name] issue tracker count
-	percntTracker float64        // apps with issue tracker / total apps
+	bestApps      map[string]int                       // top 10 apps with least vuln score
+	worstApps     map[string]int                       // top 10 apps with the greatest vuln score
+	toolUsage     map[string]int                       // map of [tool name] / count of usage
+	topCWE        map[string]int                       // top 10 CWEs in this month's findings
+	cweBySev      map[int]map[string]int               // map of [severity][CWE] count of findings
+	cweScore      map[string]int                       // map of [CWE] findings weighted by severity with vulnWeight
+	cweCats       map[string]map[string]VulnCount      // map of [scheme][category] vuln counts, see categories.go
+	cweCatsByLob  map[string]map[string]map[string]int // map of [scheme][LoB/Team name][category] count of findings
+	scansByTool   map[string]int                       // map of [scanner] scans / uploads, nil without scan history
+	scansByLob    map[string]int                       // map of [LoB/Team name] scans / uploads
+	totScans      int                                  // scans / uploads including clean ones
+	cleanScans    int                                  // scans / uploads without findings
+	trackerCount  map[string]int                       // map of [app name] issue tracker count
+	percntTracker float64                              // apps with issue tracker / total apps
 }
 
 //////////////////////////////////////////////////////////////////
@@ -77,17 +96,52 @@ type tfYear struct {
 	yearEnds   string         // quarter in which the year ends - year = 4 quarters not calendar year
 	qLabels    [4]string      // array of quarter lables e.g. 2015-Q1
 	quarters   [4]*tfQuarter  // pointers to the 4 quarters that make up the past year
+	totVulns   int            // total vulns - includes all but info for the year
 	critApps   map[string]int // map of [app name] count of crits
 	percntCrit float64        // apps with crits / total apps * 100 e.g. 8.03%
 	highApps   map[string]int // map of [app name] count of highs
 	percntHigh float64        // apps with highs / total apps * 100 e.g. 23.72%
 	// maps of [app name] vuln score for the next 2
-	bestApps      map[string]int // top 10 apps with least vuln score
-	worstApps     map[string]int // top 10 apps with the greatest vuln score
-	toolUsage     map[string]int // map of [tool name] / count of usage
-	topCWE        map[string]int // top 10 CWEs in this month's findings
-	trackerCount  map[string]int // map of [app name] issue tracker count
-	percntTracker float64        // apps with issue tracker / total apps
+	bestApps      map[string]int                       // top 10 apps with least vuln score
+	worstApps     map[string]int                       // top 10 apps with the greatest vuln score
+	toolUsage     map[string]int                       // map of [tool name] / count of usage
+	topCWE        map[string]int                       // top 10 CWEs in this month's findings
+	cweBySev      map[int]map[string]int               // map of [severity][CWE] count of findings
+	cweScore      map[string]int                       // map of [CWE] findings weighted by severity with vulnWeight
+	cweCats       map[string]map[string]VulnCount      // map of [scheme][category] vuln counts, see categories.go
+	cweCatsByLob  map[string]map[string]map[string]int // map of [scheme][LoB/Team name][category] count of findings
+	scansByTool   map[string]int                       // map of [scanner] scans / uploads, nil without scan history
+	scansByLob    map[string]int                       // map of [LoB/Team name] scans / uploads
+	totScans      int                                  // scans / uploads including clean ones
+	cleanScans    int                                  // scans / uploads without findings
+	trackerCount  map[string]int                       // map of [app name] issue tracker count
+	percntTracker float64                              // apps with issue tracker / total apps
+}
+
+//////////////////////////////////////////////////////////////////
+// Struct for metrics gathered per week from the Vul Search API //
+//////////////////////////////////////////////////////////////////
+
+type tfWeek struct {
+	year     int        // ISO year, can differ from the calendar year around New Year
+	week     int        // ISO week 1 to 53
+	start    time.Time  // Monday the week starts on
+	end      time.Time  // Sunday the week ends on
+	wpartial bool       // if we're part way through the week
+	metrics  *tfMonth   // the same metrics as a month, calculated for the week
+	parts    []*tfRange // the week split where it crosses into a new month, used to roll weeks up into months
+}
+
+///////////////////////////////////////////////////////////////////////
+// Struct for metrics gathered over any dates from the Vul Search API //
+///////////////////////////////////////////////////////////////////////
+
+type tfRange struct {
+	label   string     // e.g. 2026-01-15 to 2026-04-30 or 2026-02 for a month bucket
+	start   time.Time  // first day of the range
+	end     time.Time  // last day of the range, included
+	metrics *tfMonth   // the same metrics as a month, calculated over the range
+	buckets []*tfRange // the range split by week, month or quarter, oldest first
 }
 
 ////////////////////////////////////////
@@ -100,7 +154,9 @@ var tfc *http.Client = nil
 // Summary data structures
 var appCount int = 0                  // overall count of apps
 var teamCounts = make(map[string]int) // Number of apps under each team/LoB
+var teamIds = make(map[string]int)    // ThreadFix ID of each team/LoB by name
 var critsByLob = make(map[string]int) // Number of criticals by team/LoB
+var appLobs = make(map[string]string) // LoB/Team of each app by app name
 
 type quarter struct {
 	label string
@@ -140,4 +196,20 @@ var vulnWeight = map[int]int{
 	1: 1,  // Info weight
 }
 
-const monthCutoff = 15
+// Day of the month up to which the previous month is reported on
+var monthCutoff = 15
+
+// Most results asked for in a ThreadFix search
+var maxResults = 1500
+
+// Number of months searched for at the same time
+var fetchWorkers = 4
+
+// Severities searched for - crit, high, med & low
+var searchSeverities = []int{5, 4, 3, 2}
+
+// Include / exclude filters applied to every search
+var findingFilter filters
+
+// Sections in the full report, in order
+var reportSections = allSections
@@ -0,0 +1,123 @@
+// ranges.go
+// metrics for any range of dates, optionally split into weeks, months or quarters
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Layout of the dates a range is given as
+const dayKey = "2006-01-02"
+
+// Ways a range can be split up
+var rangeBuckets = []string{"week", "month", "quarter"}
+
+// Gather the metrics from start to end, both included. If by is set the range
+// is split into weeks, months or quarters, each searched for on its own, and
+// the range's metrics are calculated from all of their findings.
+func fetchRange(start time.Time, end time.Time, by string) (*tfRange, error) {
+	rg := &tfRange{
+		label: start.Format(dayKey) + " to " + end.Format(dayKey),
+		start: start,
+		end:   end,
+	}
+
+	if by == "" {
+		var search tf.SrchResp
+		err := rangeSearch(start, end, &search)
+		if err != nil {
+			return nil, err
+		}
+		calcRange(rg, &search)
+		return rg, nil
+	}
+
+	bs, err := splitRange(start, end, by)
+	if err != nil {
+		return nil, err
+	}
+	err = fetchAll(len(bs), func(i int) error {
+		var search tf.SrchResp
+		err := rangeSearch(bs[i].start, bs[i].end, &search)
+		if err != nil {
+			return err
+		}
+		calcRange(bs[i], &search)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	rg.buckets = bs
+
+	// Put the buckets' findings back together for the whole range
+	var searches []*tf.SrchResp
+	for _, b := range bs {
+		searches = append(searches, b.metrics.search)
+	}
+	calcRange(rg, joinSearches(searches...))
+
+	return rg, nil
+}
+
+// Fill in a range's metrics the same way as a month's
+func calcRange(rg *tfRange, search *tf.SrchResp) {
+	m := &tfMonth{
+		tStamp:  rg.end,
+		quarter: getQuarter(rg.end.Month(), rg.end.Year()),
+	}
+	calcMonth(m, search, appCount)
+	countScans(m, rg.start, rg.end)
+	rg.metrics = m
+}
+
+// One set of search results holding the findings of all of them
+func joinSearches(ss ...*tf.SrchResp) *tf.SrchResp {
+	all := *ss[0]
+	all.Results = nil
+	for _, s := range ss {
+		all.Results = append(all.Results, s.Results...)
+	}
+
+	return &all
+}
+
+// Split start to end into weeks, months or quarters. The first and last
+// buckets are cut short to fit the range.
+func splitRange(start time.Time, end time.Time, by string) ([]*tfRange, error) {
+	var bs []*tfRange
+	for st := start; !st.After(end); {
+		var e time.Time
+		var label string
+		switch by {
+		case "week":
+			// ISO weeks run Monday to Sunday
+			e = st.AddDate(0, 0, (7-int(st.Weekday()))%7)
+			y, w := st.ISOWeek()
+			label = fmt.Sprintf("%v-W%02d", y, w)
+		case "month":
+			e = monthEnd(st)
+			label = st.Format(monthKey)
+		case "quarter":
+			e = quarterLastMonth(st)
+			label = getQuarter(st.Month(), st.Year())
+		default:
+			return nil, fmt.Errorf("Ranges can be split by week, month or quarter not %v\n", by)
+		}
+		if e.After(end) {
+			e = end
+		}
+		bs = append(bs, &tfRange{label: label, start: st, end: e})
+		st = e.AddDate(0, 0, 1)
+	}
+
+	return bs, nil
+}
+
+// Last day of the month t is in
+func monthEnd(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), lastDate(int(t.Month()), t.Year()), 0, 0, 0, 0, time.UTC)
+}
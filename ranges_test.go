@@ -0,0 +1,51 @@
+// ranges_test.go
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitRange(t *testing.T) {
+	defer func(d map[int]string, e map[int]int) { qtrDefs, quarterEnd = d, e }(qtrDefs, quarterEnd)
+	tests := []struct {
+		quarters []string
+		start    string
+		end      string
+		by       string
+		want     string // label start end of each bucket
+		bad      bool
+	}{
+		{nil, "2026-01-15", "2026-04-30", "month",
+			"2026-01 2026-01-15 2026-01-31|2026-02 2026-02-01 2026-02-28|2026-03 2026-03-01 2026-03-31|2026-04 2026-04-01 2026-04-30", false},
+		{nil, "2026-02-10", "2026-02-20", "month", "2026-02 2026-02-10 2026-02-20", false},
+		// Weeks run Monday to Sunday, 2026-01-01 is a Thursday in ISO week 1
+		{nil, "2026-01-01", "2026-01-14", "week",
+			"2026-W01 2026-01-01 2026-01-04|2026-W02 2026-01-05 2026-01-11|2026-W03 2026-01-12 2026-01-14", false},
+		// 2027-01-01 is a Friday in the last ISO week of 2026
+		{nil, "2026-12-28", "2027-01-04", "week", "2026-W53 2026-12-28 2027-01-03|2027-W01 2027-01-04 2027-01-04", false},
+		{nil, "2026-02-15", "2026-10-19", "quarter",
+			"Q1-2026 2026-02-15 2026-03-31|Q2-2026 2026-04-01 2026-06-30|Q3-2026 2026-07-01 2026-09-30|Q4-2026 2026-10-01 2026-10-19", false},
+		// Q4-2027 runs from November 2026 to January 2027
+		{wrappingQuarters, "2026-10-15", "2027-03-31", "quarter",
+			"Q3-2027 2026-10-15 2026-10-31|Q4-2027 2026-11-01 2027-01-31|Q1-2028 2027-02-01 2027-03-31", false},
+		{nil, "2026-01-01", "2026-12-31", "fortnight", "", true},
+	}
+	for _, tt := range tests {
+		useQuarters(t, tt.quarters)
+		bs, err := splitRange(mustDay(t, tt.start), mustDay(t, tt.end), tt.by)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v to %v by %v: no error", tt.start, tt.end, tt.by)
+			}
+			continue
+		}
+		var got []string
+		for _, b := range bs {
+			got = append(got, b.label+" "+b.start.Format(dayKey)+" "+b.end.Format(dayKey))
+		}
+		if g := strings.Join(got, "|"); err != nil || g != tt.want {
+			t.Errorf("%v to %v by %v: got %q %v want %q", tt.start, tt.end, tt.by, g, err, tt.want)
+		}
+	}
+}
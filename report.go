@@ -0,0 +1,1352 @@
+// report.go
+// builds the metrics report and renders it as text, HTML, CSV or JSON
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// A report is an ordered list of sections. Each section holds lines of prose
+// and tables so the same report can be printed to screen, emailed as HTML or
+// attached as CSV / JSON without each section knowing about the formats.
+type report struct {
+	title    string    // e.g. ThreadFix Metrics for September 2026
+	team     string    // LoB/Team the report is limited to, empty for all teams
+	month    string    // label of the reporting month e.g. September 2026
+	quarter  string    // label of the reporting quarter e.g. Q3-2026
+	created  time.Time // when the report was generated
+	sections []section
+}
+
+type section struct {
+	title  string
+	blocks []block
+}
+
+// A block is either a line of text or a table
+type block struct {
+	text string
+	tbl  *table
+}
+
+type table struct {
+	caption string          // line printed before the rows e.g. "LoB with critical findings are:"
+	columns []string        // column names used for HTML, CSV and JSON output
+	format  string          // fmt format applied to each row for text output
+	rows    [][]interface{} // one entry per row, one value per column
+}
+
+func (s *section) line(format string, a ...interface{}) {
+	s.blocks = append(s.blocks, block{text: fmt.Sprintf(format, a...)})
+}
+
+func (s *section) table(t *table) {
+	s.blocks = append(s.blocks, block{tbl: t})
+}
+
+func (t *table) add(row ...interface{}) {
+	t.rows = append(t.rows, row)
+}
+
+func newReport(m0 *tfMonth) *report {
+	return newTeamReport(m0, "")
+}
+
+// A report limited to team, or for all teams if it's empty
+func newTeamReport(m0 *tfMonth, team string) *report {
+	r := &report{
+		title:   fmt.Sprintf("ThreadFix Metrics for %v %v", m0.tStamp.Month(), m0.tStamp.Year()),
+		team:    team,
+		month:   fmt.Sprintf("%v %v", m0.tStamp.Month(), m0.tStamp.Year()),
+		quarter: m0.quarter,
+		created: time.Now(),
+	}
+	// Say up front if the findings have been filtered
+	if findingFilter.active() {
+		r.add(filterSection())
+	}
+
+	return r
+}
+
+func (r *report) add(s ...section) {
+	r.sections = append(r.sections, s...)
+}
+
+// Add where the targets stand in ms from fetchTargetHistory, or why they
+// can't be checked, if any are set
+func (r *report) addTargets(ms []*tfMonth, err error) {
+	if len(targets) == 0 {
+		return
+	}
+	when := ""
+	if len(ms) > 0 {
+		when = fmt.Sprintf("%v %v", ms[0].tStamp.Month(), ms[0].tStamp.Year())
+	}
+	r.add(targetSection(when, targetStatuses(ms, r.team), err))
+}
+
+///////////////////////////////////////
+// Sections built from the metrics   //
+///////////////////////////////////////
+
+func filterSection() section {
+	s := section{title: "Filters"}
+	s.line("Only findings passing these filters are included:")
+	for _, d := range findingFilter.desc {
+		s.line("  %v", d)
+	}
+
+	return s
+}
+
+func summarySection() section {
+	s := section{title: "Summary Metrics"}
+	s.line("Total Apps in ThreadFix is %v", appCount)
+	s.line("Number of LoB/Teams in Threadfix is %v", len(teamCounts))
+	t := &table{
+		caption: "Individual LoB/Team counts are:",
+		columns: []string{"LoB/Team", "Apps"},
+		format:  "  %v includes %v apps",
+	}
+	for _, r := range rankCounts(teamCounts, false) {
+		t.add(r.name, r.count)
+	}
+	s.table(t)
+	s.line("")
+	s.line("Total LoB/Team with critical findings is %v", len(critsByLob))
+	// If there's apps with crits, print them and the average
+	if len(critsByLob) > 0 {
+		t := &table{
+			caption: "LoB with critical findings are:",
+			columns: []string{"LoB/Team", "Critical findings"},
+			format:  "  %v has %v critical findings",
+		}
+		for _, r := range rankCounts(critsByLob, false) {
+			t.add(r.name, r.count)
+		}
+		s.table(t)
+		percntCrits := (float64(len(critsByLob)) / float64(appCount)) * 100
+		s.line("Percentage of LoB/Teams with critical findings is %.2f%%", percntCrits)
+		s.line("")
+	}
+
+	return s
+}
+
+func monthSection(title string, m *tfMonth) section {
+	s := section{title: title}
+	s.line("Metrics for %+v %+v, which is part of %+v", m.tStamp.Month(), m.tStamp.Year(), m.quarter)
+	s.line("Total vulnerabilities found for %+v was %+v", m.tStamp.Month(), m.totVulns)
+	// Criticals
+	if len(m.critApps) > 0 {
+		s.line("Total apps with critical findings is %+v", len(m.critApps))
+		s.table(countTable("Individual App critical finding counts are:", "App", "Critical findings",
+			"  %v has %v critical findings", m.critApps, false))
+		s.line("Percentage of Apps with critical findings is %.2f%%", m.percntCrit)
+		s.line("")
+	}
+	// Highs
+	if len(m.highApps) > 0 {
+		s.line("Total apps with highs is %+v", len(m.highApps))
+		s.table(countTable("Individual App high finding counts are:", "App", "High findings",
+			"  %v has %v high findings", m.highApps, false))
+		s.line("Percentage of Apps with high findings is %.2f%%", m.percntHigh)
+		s.line("")
+	}
+	// Best and worst apps
+	s.table(scoreTable("The best apps of the month (and their score) are: (smaller is better)",
+		m.bestApps, m.bAppsCnt, true))
+	s.table(scoreTable("The worst apps of the month (and their score) are: (smaller is better)",
+		m.worstApps, m.wAppsCnt, false))
+	densityTable(&s, "the month", appScores(m.search))
+	// Tool usage
+	s.table(countTable(fmt.Sprintf("Number of findings by scanner for %+v %+v", m.tStamp.Month(), m.tStamp.Year()),
+		"Tool", "Results", "  %v found %v results", m.toolUsage, false))
+	scanTables(&s, fmt.Sprintf("%+v %+v", m.tStamp.Month(), m.tStamp.Year()), m.totScans, m.cleanScans,
+		m.scansByTool, m.scansByLob)
+	// Top 10 CWEs
+	s.table(cweTable(fmt.Sprintf("The Top 10 CWE Vulnerabilities for %+v %+v", m.tStamp.Month(), m.tStamp.Year()),
+		m.topCWE))
+	cweSevTables(&s, fmt.Sprintf("%+v %+v", m.tStamp.Month(), m.tStamp.Year()), m.cweBySev, m.cweScore)
+	// LoB stats
+	s.line("")
+	s.line("Total number of apps with findings this month: %+v", m.totAssess)
+	t := &table{
+		caption: "Apps with findings per LoB/Region",
+		columns: []string{"LoB/Team", "Apps with findings", "Critical", "High", "Medium", "Low"},
+		format:  "  %[1]v had %[2]v apps with findings\n    %[1]v vuln count (crit/high/med/low): %[3]v,%[4]v,%[5]v,%[6]v",
+	}
+	for _, r := range rankCounts(m.assessByLob, false) {
+		c := m.vulnByLob[r.name]
+		t.add(r.name, r.count, c.crit, c.high, c.med, c.low)
+	}
+	s.table(t)
+
+	return s
+}
+
+func quarterSection(q *tfQuarter) section {
+	s := section{title: "Quarter Metrics"}
+	s.line("Metrics for %+v", q.qLabel)
+	s.line("Total vulnerabilities found for %+v was %+v", q.qLabel, q.totVulns)
+	// Criticals
+	if len(q.critApps) > 0 {
+		s.line("Total apps with critical findings is %+v", len(q.critApps))
+		s.table(countTable("Individual App critical finding counts are:", "App", "Critical findings",
+			"  %v has %v critical findings", q.critApps, false))
+		s.line("Percentage of Apps with critical findings is %.2f%%", q.percntCrit)
+		s.line("")
+	}
+	// Highs
+	if len(q.highApps) > 0 {
+		s.line("Total apps with highs is %+v", len(q.highApps))
+		s.table(countTable("Individual App high finding counts are:", "App", "High findings",
+			"  %v has %v high findings", q.highApps, false))
+		s.line("Percentage of Apps with high findings is %.2f%%", q.percntHigh)
+		s.line("")
+	}
+	// Best and worst apps
+	s.table(countTable(fmt.Sprintf("The best apps of %+v (and their score) are: (smaller is better)", q.qLabel),
+		"App", "Score", "  %v has a score of %v ", q.bestApps, true))
+	s.table(countTable(fmt.Sprintf("The worst apps of %+v (and their score) are: (smaller is better)", q.qLabel),
+		"App", "Score", "  %v has a score of %v ", q.worstApps, false))
+	densityTable(&s, q.qLabel, appScores(joinSearches(q.months[0].search, q.months[1].search, q.months[2].search)))
+	// Tool usage
+	s.table(countTable(fmt.Sprintf("Number of findings by scanner for %+v", q.qLabel),
+		"Tool", "Results", "  %v found %v results", q.toolUsage, false))
+	scanTables(&s, q.qLabel, q.totScans, q.cleanScans, q.scansByTool, q.scansByLob)
+	// Top 10 CWEs
+	s.table(cweTable(fmt.Sprintf("The Top 10 CWE Vulnerabilities for %+v", q.qLabel), q.topCWE))
+	cweSevTables(&s, q.qLabel, q.cweBySev, q.cweScore)
+
+	return s
+}
+
+// Crit/High and assessment counts per LoB for the months sent, newest first
+func lobCSVSection(ms ...*tfMonth) section {
+	s := section{title: "LoB Crit/High by Month"}
+	t := &table{columns: []string{"Lob"}}
+	for _, m := range ms {
+		t.columns = append(t.columns,
+			fmt.Sprintf("%+v Crit/High", m.tStamp.Month()),
+			fmt.Sprintf("%+v Tot Asmts", m.tStamp.Month()))
+	}
+	s.line("Copy and Paste into a plain text file to create a CSV")
+	s.line("")
+	s.line("%v", strings.Join(t.columns, ","))
+	t.format = "%v" + strings.Repeat(",%v", len(t.columns)-1)
+	for _, r := range rankCounts(ms[0].assessByLob, false) {
+		row := []interface{}{r.name}
+		for _, m := range ms {
+			row = append(row, m.vulnByLob[r.name].crit+m.vulnByLob[r.name].high, m.assessByLob[r.name])
+		}
+		t.add(row...)
+	}
+	s.table(t)
+
+	return s
+}
+
+func yearSection(y *tfYear) section {
+	s := section{title: "Year Metrics"}
+	s.line("Metrics for the year ending %+v", y.yearEnds)
+	s.line("Total vulnerabilities found for the year was %+v", y.totVulns)
+	t := &table{
+		caption: "Quarters making up the year:",
+		columns: []string{"Quarter", "Vulnerabilities", "Apps with criticals", "Apps with highs"},
+		format:  "  %v had %v vulnerabilities, %v apps with criticals and %v with highs",
+	}
+	for _, q := range y.quarters {
+		t.add(q.qLabel, q.totVulns, len(q.critApps), len(q.highApps))
+	}
+	s.table(t)
+	s.line("")
+	// Criticals
+	if len(y.critApps) > 0 {
+		s.line("Total apps with critical findings is %+v", len(y.critApps))
+		s.table(countTable("Individual App critical finding counts are:", "App", "Critical findings",
+			"  %v has %v critical findings", y.critApps, false))
+		s.line("Percentage of Apps with critical findings is %.2f%%", y.percntCrit)
+		s.line("")
+	}
+	// Highs
+	if len(y.highApps) > 0 {
+		s.line("Total apps with highs is %+v", len(y.highApps))
+		s.table(countTable("Individual App high finding counts are:", "App", "High findings",
+			"  %v has %v high findings", y.highApps, false))
+		s.line("Percentage of Apps with high findings is %.2f%%", y.percntHigh)
+		s.line("")
+	}
+	// Best and worst apps
+	s.table(countTable("The best apps of the year (and their score) are: (smaller is better)",
+		"App", "Score", "  %v has a score of %v ", y.bestApps, true))
+	s.table(countTable("The worst apps of the year (and their score) are: (smaller is better)",
+		"App", "Score", "  %v has a score of %v ", y.worstApps, false))
+	var searches []*tf.SrchResp
+	for _, q := range y.quarters {
+		for _, m := range q.months {
+			searches = append(searches, m.search)
+		}
+	}
+	densityTable(&s, "the year", appScores(joinSearches(searches...)))
+	// Tool usage
+	s.table(countTable("Number of findings by scanner for the year",
+		"Tool", "Results", "  %v found %v results", y.toolUsage, false))
+	scanTables(&s, "the year", y.totScans, y.cleanScans, y.scansByTool, y.scansByLob)
+	// Top 10 CWEs
+	s.table(cweTable("The Top 10 CWE Vulnerabilities for the year", y.topCWE))
+	cweSevTables(&s, "the year", y.cweBySev, y.cweScore)
+
+	return s
+}
+
+func rangeSection(rg *tfRange) section {
+	m := rg.metrics
+	s := section{title: "Date Range Metrics"}
+	s.line("Metrics for %v", rg.label)
+	s.line("Total vulnerabilities found was %+v", m.totVulns)
+	// The range split up, oldest first
+	if len(rg.buckets) > 0 {
+		t := periodTable("Metrics for each part of the range:", true)
+		for _, b := range rg.buckets {
+			addPeriod(t, b.label, b.start, b.end, b.metrics)
+		}
+		s.table(t)
+		s.line("")
+	}
+	// Criticals
+	if len(m.critApps) > 0 {
+		s.line("Total apps with critical findings is %+v", len(m.critApps))
+		s.table(countTable("Individual App critical finding counts are:", "App", "Critical findings",
+			"  %v has %v critical findings", m.critApps, false))
+		s.line("Percentage of Apps with critical findings is %.2f%%", m.percntCrit)
+		s.line("")
+	}
+	// Highs
+	if len(m.highApps) > 0 {
+		s.line("Total apps with highs is %+v", len(m.highApps))
+		s.table(countTable("Individual App high finding counts are:", "App", "High findings",
+			"  %v has %v high findings", m.highApps, false))
+		s.line("Percentage of Apps with high findings is %.2f%%", m.percntHigh)
+		s.line("")
+	}
+	// Best and worst apps
+	s.table(scoreTable("The best apps of the range (and their score) are: (smaller is better)",
+		m.bestApps, m.bAppsCnt, true))
+	s.table(scoreTable("The worst apps of the range (and their score) are: (smaller is better)",
+		m.worstApps, m.wAppsCnt, false))
+	densityTable(&s, "the range", appScores(m.search))
+	// Tool usage
+	s.table(countTable("Number of findings by scanner for the range",
+		"Tool", "Results", "  %v found %v results", m.toolUsage, false))
+	scanTables(&s, "the range", m.totScans, m.cleanScans, m.scansByTool, m.scansByLob)
+	// Top 10 CWEs
+	s.table(cweTable("The Top 10 CWE Vulnerabilities for the range", m.topCWE))
+	cweSevTables(&s, "the range", m.cweBySev, m.cweScore)
+	// LoB stats
+	s.line("")
+	s.line("Total number of apps with findings for the range: %+v", m.totAssess)
+	t := &table{
+		caption: "Apps with findings per LoB/Region",
+		columns: []string{"LoB/Team", "Apps with findings", "Critical", "High", "Medium", "Low"},
+		format:  "  %[1]v had %[2]v apps with findings\n    %[1]v vuln count (crit/high/med/low): %[3]v,%[4]v,%[5]v,%[6]v",
+	}
+	for _, r := range rankCounts(m.assessByLob, false) {
+		c := m.vulnByLob[r.name]
+		t.add(r.name, r.count, c.crit, c.high, c.med, c.low)
+	}
+	s.table(t)
+
+	return s
+}
+
+// Weeks and the months they roll up into, both newest first
+func weeksSection(ws []*tfWeek, ms []*tfMonth) section {
+	s := section{title: "Weekly Metrics"}
+	s.line("Metrics for the %v weeks from %v to %v", len(ws), ws[len(ws)-1].start.Format(dayKey), ws[0].end.Format(dayKey))
+	t := periodTable("Metrics for each week:", true)
+	for _, w := range ws {
+		label := weekLabel(w)
+		if w.wpartial {
+			label += " (so far)"
+		}
+		addPeriod(t, label, w.start, w.end, w.metrics)
+	}
+	s.table(t)
+	s.line("")
+	t = periodTable("The weeks rolled up into months:", false)
+	for _, m := range ms {
+		label := m.tStamp.Format(monthKey)
+		if m.mpartial {
+			label += " (part)"
+		}
+		addPeriod(t, label, time.Time{}, time.Time{}, m)
+	}
+	s.table(t)
+	s.line("")
+
+	// Crit + high per LoB for each week, oldest first to read like a sprint board
+	var labels []string
+	var wms []*tfMonth
+	for i := len(ws) - 1; i >= 0; i-- {
+		labels = append(labels, weekLabel(ws[i]))
+		wms = append(wms, ws[i].metrics)
+	}
+	t = lobTrendTable(fmt.Sprintf("Critical and high findings per LoB/Team for %v to %v:", labels[0], labels[len(labels)-1]),
+		labels, wms)
+	s.table(t)
+
+	return s
+}
+
+// Month by month numbers for the months sent, newest first
+func trendSection(ms []*tfMonth) section {
+	first, last := ms[len(ms)-1].tStamp.Format(monthKey), ms[0].tStamp.Format(monthKey)
+	s := section{title: "Trend Metrics"}
+	s.line("Trend for the %v months from %v to %v", len(ms), first, last)
+	// Oldest first so the numbers read left to right / top to bottom in time
+	var labels []string
+	var oms []*tfMonth
+	t := periodTable("Metrics for each month:", false)
+	for i := len(ms) - 1; i >= 0; i-- {
+		label := ms[i].tStamp.Format(monthKey)
+		labels = append(labels, label)
+		oms = append(oms, ms[i])
+		if ms[i].mpartial {
+			label += " (so far)"
+		}
+		addPeriod(t, label, time.Time{}, time.Time{}, ms[i])
+	}
+	s.table(t)
+	s.line("")
+	s.table(lobTrendTable(fmt.Sprintf("Critical and high findings per LoB/Team for %v to %v:", first, last), labels, oms))
+	// Scans, if there's scan history
+	if ms[0].scansByTool != nil {
+		s.line("")
+		t := &table{
+			caption: "Scans for each month:",
+			columns: []string{"Month", "Scans", "Clean scans"},
+			format:  "  %v had %v scans, %v of them clean",
+		}
+		var tools, lobs []map[string]int
+		for i, m := range oms {
+			t.add(labels[i], m.totScans, m.cleanScans)
+			tools = append(tools, m.scansByTool)
+			lobs = append(lobs, m.scansByLob)
+		}
+		s.table(t)
+		s.table(countTrendTable("Scans per scanner for each month:", "Scanner", labels, tools))
+		s.table(countTrendTable("Scans per LoB/Team for each month:", "LoB/Team", labels, lobs))
+	}
+
+	return s
+}
+
+// Counts for each period, labels and counts in the same order, with a row per
+// name sorted by the total over all periods
+func countTrendTable(caption string, name string, labels []string, counts []map[string]int) *table {
+	t := &table{
+		caption: caption,
+		columns: append([]string{name}, labels...),
+		format:  "  %v:" + strings.Repeat(" %v", len(counts)),
+	}
+	for _, r := range rankCounts(sumMaps(counts...), false) {
+		row := []interface{}{r.name}
+		for _, c := range counts {
+			row = append(row, c[r.name])
+		}
+		t.add(row...)
+	}
+
+	return t
+}
+
+// Crit + high findings per LoB for each period, labels and ms in the same order
+func lobTrendTable(caption string, labels []string, ms []*tfMonth) *table {
+	t := &table{
+		caption: caption,
+		columns: append([]string{"LoB/Team"}, labels...),
+		format:  "  %v:" + strings.Repeat(" %v", len(ms)),
+	}
+	lobs := make(map[string]int)
+	for _, m := range ms {
+		for k, v := range m.vulnByLob {
+			lobs[k] += v.crit + v.high
+		}
+	}
+	for _, r := range rankCounts(lobs, false) {
+		row := []interface{}{r.name}
+		for _, m := range ms {
+			c := m.vulnByLob[r.name]
+			row = append(row, c.crit+c.high)
+		}
+		t.add(row...)
+	}
+
+	return t
+}
+
+// Table of the headline numbers for a list of periods, with their first and
+// last days if dates is set
+func periodTable(caption string, dates bool) *table {
+	t := &table{
+		caption: caption,
+		columns: []string{"Period", "Vulnerabilities", "Apps with criticals", "Critical %",
+			"Apps with highs", "High %", "Apps with findings"},
+		format: "  %[1]v had %[2]v vulnerabilities, %[3]v apps with criticals (%.2[4]f%%),\n" +
+			"    %[5]v apps with highs (%.2[6]f%%) and %[7]v apps with findings",
+	}
+	if dates {
+		t.columns = []string{"Period", "From", "To", "Vulnerabilities", "Apps with criticals", "Critical %",
+			"Apps with highs", "High %", "Apps with findings"}
+		t.format = "  %[1]v (%[2]v to %[3]v) had %[4]v vulnerabilities, %[5]v apps with criticals (%.2[6]f%%),\n" +
+			"    %[7]v apps with highs (%.2[8]f%%) and %[9]v apps with findings"
+	}
+
+	return t
+}
+
+// Add a period to a periodTable, start and end are left out if they're zero
+func addPeriod(t *table, label string, start time.Time, end time.Time, m *tfMonth) {
+	row := []interface{}{label}
+	if !start.IsZero() {
+		row = append(row, start.Format(dayKey), end.Format(dayKey))
+	}
+	row = append(row, m.totVulns, len(m.critApps), m.percntCrit, len(m.highApps), m.percntHigh, m.totAssess)
+	t.add(row...)
+}
+
+// Apps, critical findings and the month's vuln counts for every LoB/Team
+func teamsSection(m *tfMonth) section {
+	s := section{title: "LoB/Team Metrics"}
+	s.line("LoB/Team metrics for %+v %+v", m.tStamp.Month(), m.tStamp.Year())
+	t := &table{
+		caption: "Individual LoB/Team numbers are:",
+		columns: []string{"LoB/Team", "Apps", "Critical findings", "Assessed apps", "Critical", "High", "Medium", "Low"},
+		format:  "  %[1]v has %[2]v apps, %[3]v critical findings and %[4]v apps assessed\n    %[1]v vuln count (crit/high/med/low): %[5]v,%[6]v,%[7]v,%[8]v",
+	}
+	for _, r := range rankCounts(teamCounts, false) {
+		c := m.vulnByLob[r.name]
+		t.add(r.name, r.count, critsByLob[r.name], m.assessByLob[r.name], c.crit, c.high, c.med, c.low)
+	}
+	s.table(t)
+
+	return s
+}
+
+// Every app's score and vuln counts for the month, worst first. appTeams maps
+// app names to their LoB/Team and only apps of team are listed if it's set.
+func appsSection(m *tfMonth, appTeams map[string]string, team string) section {
+	s := section{title: "App Metrics"}
+	s.line("App metrics for %+v %+v", m.tStamp.Month(), m.tStamp.Year())
+	scores := appScores(m.search)
+	cnts := appVulnCounts(m.search, appFindings(m.search))
+	// Apps without findings have a score of 0
+	for a, _ := range appTeams {
+		if _, ok := scores[a]; !ok {
+			scores[a] = 0
+		}
+	}
+	for a, _ := range scores {
+		if team != "" && appTeams[a] != team {
+			delete(scores, a)
+		}
+	}
+	t := &table{
+		caption: "Individual App scores are: (smaller is better)",
+		columns: []string{"App", "LoB/Team", "Score", "Critical", "High", "Medium", "Low"},
+		format:  "  %[1]v (%[2]v) has a score of %[3]v \n    %[1]v vuln count (crit/high/med/low): %[4]v,%[5]v,%[6]v,%[7]v",
+	}
+	// Normalised by size too if there are app sizes
+	if appSizes != nil {
+		t.columns = append(t.columns, "KLOC", "Score per KLOC")
+		t.format += "\n    %[1]v has %.2[9]f per KLOC (%.1[8]f KLOC)"
+	}
+	for _, r := range rankCounts(scores, false) {
+		row := []interface{}{r.name, appTeams[r.name], r.count, cnts[r.name].crit, cnts[r.name].high, cnts[r.name].med, cnts[r.name].low}
+		if appSizes != nil {
+			size, _ := sizeOf(r.name)
+			kloc, _ := size.kloc()
+			per := 0.0
+			if kloc > 0 {
+				per = float64(r.count) / kloc
+			}
+			row = append(row, kloc, per)
+		}
+		t.add(row...)
+	}
+	s.table(t)
+
+	return s
+}
+
+// Scans and uploads per scanner and per LoB/Team, clean ones included, if
+// there's scan history
+func scanTables(s *section, when string, tot int, clean int, byTool map[string]int, byLob map[string]int) {
+	if byTool == nil {
+		return
+	}
+	s.line("Total number of scans for %v is %v, %v of them clean (no findings)", when, tot, clean)
+	s.table(countTable(fmt.Sprintf("Number of scans by scanner for %v", when),
+		"Scanner", "Scans", "  %v ran %v scans", byTool, false))
+	s.table(countTable(fmt.Sprintf("Number of scans per LoB/Team for %v", when),
+		"LoB/Team", "Scans", "  %v had %v scans", byLob, false))
+}
+
+// The 10 apps with the most weighted score per thousand lines of code, or
+// per endpoint if that's all that's known, if there are app sizes
+func densityTable(s *section, when string, scores map[string]int) {
+	if appSizes == nil {
+		return
+	}
+	t := &table{
+		caption: fmt.Sprintf("The densest apps of %v (score per KLOC, estimated from the size class if lines of code aren't known): (smaller is better)", when),
+		columns: []string{"App", "Score", "KLOC", "Score per KLOC", "Endpoints", "Score per endpoint", "Size class"},
+		format:  "  %[1]v has a score of %[2]v, %.2[4]f per KLOC (%.1[3]f KLOC) and %.2[6]f per endpoint (%[5]v endpoints)",
+	}
+	ds := appDensities(scores)
+	if len(ds) > 10 {
+		ds = ds[:10]
+	}
+	for _, d := range ds {
+		t.add(d.app, d.score, d.kloc, d.perKLOC, d.endpoints, d.perEnd, d.class)
+	}
+	s.table(t)
+}
+
+// Table of name / count pairs sorted by count
+func countTable(caption string, name string, count string, format string, m map[string]int, ascending bool) *table {
+	t := &table{
+		caption: caption,
+		columns: []string{name, count},
+		format:  format,
+	}
+	for _, r := range rankCounts(m, ascending) {
+		t.add(r.name, r.count)
+	}
+
+	return t
+}
+
+// Table of apps, their score and vuln counts for the best / worst apps
+func scoreTable(caption string, scores map[string]int, cnts map[string]VulnCount, ascending bool) *table {
+	t := &table{
+		caption: caption,
+		columns: []string{"App", "Score", "Critical", "High", "Medium", "Low"},
+		format:  "  %[1]v has a score of %[2]v \n    %[1]v vuln count (crit/high/med/low): %[3]v,%[4]v,%[5]v,%[6]v",
+	}
+	for _, r := range rankCounts(scores, ascending) {
+		t.add(r.name, r.count, cnts[r.name].crit, cnts[r.name].high, cnts[r.name].med, cnts[r.name].low)
+	}
+
+	return t
+}
+
+// Table of the 10 most common CWEs
+func cweTable(caption string, cwes map[string]int) *table {
+	t := &table{
+		caption: caption,
+		columns: []string{"Occurrences", "CWE"},
+		format:  "  %v occurrences of %v",
+	}
+	sCwe := rankCounts(cwes, false)
+	if len(sCwe) > 10 {
+		sCwe = sCwe[:10]
+	}
+	for _, r := range sCwe {
+		t.add(r.count, r.name)
+	}
+
+	return t
+}
+
+// Top 10 CWEs for each severity then by weighted score
+func cweSevTables(s *section, when string, bySev map[int]map[string]int, scores map[string]int) {
+	for sev := 5; sev >= 1; sev-- {
+		if len(bySev[sev]) > 0 {
+			s.table(cweTable(fmt.Sprintf("The Top 10 CWE Vulnerabilities in %v findings for %v",
+				strings.ToLower(sevNames[sev]), when), bySev[sev]))
+		}
+	}
+	t := &table{
+		caption: fmt.Sprintf("The Top 10 CWE Vulnerabilities by weighted score for %v (crit/high/med/low weights %v/%v/%v/%v)",
+			when, vulnWeight[5], vulnWeight[4], vulnWeight[3], vulnWeight[2]),
+		columns: []string{"Score", "CWE"},
+		format:  "  %v weighted score for %v",
+	}
+	sCwe := rankCounts(scores, false)
+	if len(sCwe) > 10 {
+		sCwe = sCwe[:10]
+	}
+	for _, r := range sCwe {
+		t.add(r.count, r.name)
+	}
+	s.table(t)
+}
+
+// What each scanner found, how much the scanners overlap and their false
+// positive rates
+func scannerSection(when string, st *scannerStats) section {
+	s := section{title: "Scanner Metrics"}
+	s.line("Scanner metrics for %v", when)
+	// Every scanner with open or false positive findings
+	all := sumMaps(st.found, st.falsePos)
+	t := &table{
+		caption: "Findings per scanner, unique to it or also reported by another scanner:",
+		columns: []string{"Scanner", "Findings", "Unique", "Shared", "Unique %", "False positives", "False positive %"},
+		format:  "  %[1]v found %[2]v, %[3]v unique and %[4]v shared (%.2[5]f%% unique)\n    %[1]v had %[6]v false positives (%.2[7]f%%)",
+	}
+	for _, r := range rankCounts(all, false) {
+		n := st.found[r.name]
+		pcnt := 0.0
+		if n > 0 {
+			pcnt = float64(st.unique[r.name]) / float64(n) * 100
+		}
+		t.add(r.name, n, st.unique[r.name], st.shared[r.name], pcnt, st.falsePos[r.name], st.fpRate(r.name))
+	}
+	s.table(t)
+	s.line("")
+	// Severity mix
+	t = &table{
+		caption: "Open findings per scanner by severity:",
+		columns: []string{"Scanner", "Critical", "High", "Medium", "Low", "Critical %", "High %"},
+		format:  "  %[1]v vuln count (crit/high/med/low): %[2]v,%[3]v,%[4]v,%[5]v - %.2[6]f%% critical, %.2[7]f%% high",
+	}
+	for _, r := range rankCounts(st.found, false) {
+		c := st.bySev[r.name]
+		tot := float64(c.crit + c.high + c.med + c.low)
+		if tot == 0 {
+			tot = 1
+		}
+		t.add(r.name, c.crit, c.high, c.med, c.low, float64(c.crit)/tot*100, float64(c.high)/tot*100)
+	}
+	s.table(t)
+	s.line("")
+	// How many scanners reported each finding
+	var counts []int
+	for n, _ := range st.byCount {
+		counts = append(counts, n)
+	}
+	sort.Ints(counts)
+	t = &table{
+		caption: "Open findings by the number of scanners reporting them:",
+		columns: []string{"Scanners", "Findings"},
+		format:  "  %v: %v findings",
+	}
+	for _, n := range counts {
+		label := "1 scanner"
+		if n > 1 {
+			label = fmt.Sprintf("%v scanners", n)
+		}
+		t.add(label, st.byCount[n])
+	}
+	s.table(t)
+	if len(st.overlap) > 0 {
+		s.table(countTable("Open findings reported by both scanners of each pair:", "Scanners", "Findings",
+			"  %v both found %v", st.overlap, false))
+	}
+
+	return s
+}
+
+// e.g. September 2026 or July 2026 to September 2026, ms newest first
+func monthsLabel(ms []*tfMonth) string {
+	last := fmt.Sprintf("%v %v", ms[0].tStamp.Month(), ms[0].tStamp.Year())
+	if len(ms) == 1 {
+		return last
+	}
+	first := ms[len(ms)-1].tStamp
+
+	return fmt.Sprintf("%v %v to %v", first.Month(), first.Year(), last)
+}
+
+// Apps not scanned within the days allowed for their criticality, coverage
+// per LoB/Team and apps never scanned by each type of tool
+func coverageSection(cov []appCoverage, asOf time.Time) section {
+	s := section{title: "Scan Coverage"}
+	s.line("Scan coverage as of %v", asOf.Format(dayKey))
+	var windows []string
+	for _, k := range sortedKeys(coverageDays) {
+		if k != "" {
+			windows = append(windows, fmt.Sprintf("%v %v days", k, coverageDays[k]))
+		}
+	}
+	windows = append(windows, fmt.Sprintf("others %v days", coverageDays[""]))
+	s.line("Apps must be scanned at least every: %v", strings.Join(windows, ", "))
+
+	// Apps overdue, those never scanned first then the longest since a scan
+	var overdue []appCoverage
+	apps := make(map[string]int)
+	covered := make(map[string]int)
+	for _, c := range cov {
+		apps[c.team]++
+		if c.covered(asOf) {
+			covered[c.team]++
+			continue
+		}
+		overdue = append(overdue, c)
+	}
+	pcnt := 0.0
+	if len(cov) > 0 {
+		pcnt = float64(len(cov)-len(overdue)) / float64(len(cov)) * 100
+	}
+	s.line("%v of %v apps were scanned within their window (%.2f%%)", len(cov)-len(overdue), len(cov), pcnt)
+	s.line("")
+	sort.SliceStable(overdue, func(i, j int) bool {
+		ai, aj := overdue[i].age(asOf), overdue[j].age(asOf)
+		if (ai < 0) != (aj < 0) {
+			return ai < 0
+		}
+		return ai > aj
+	})
+	t := &table{
+		caption: "Apps not scanned within their window:",
+		columns: []string{"App", "LoB/Team", "Criticality", "Window (days)", "Last scan", "Days since"},
+		format:  "  %[1]v (%[2]v, %[3]v) should be scanned every %[4]v days - last scan: %[5]v, days since: %[6]v",
+	}
+	for _, c := range overdue {
+		crit := c.criticality
+		if crit == "" {
+			crit = "unknown"
+		}
+		last, age := "never", "-"
+		if a := c.age(asOf); a >= 0 {
+			last, age = c.lastScan.Format(dayKey), fmt.Sprint(a)
+		}
+		t.add(c.app, c.team, crit, c.maxDays, last, age)
+	}
+	s.table(t)
+	s.line("")
+
+	t = &table{
+		caption: "Coverage per LoB/Team:",
+		columns: []string{"LoB/Team", "Apps", "Scanned in window", "Coverage %"},
+		format:  "  %v has %v apps, %v scanned within their window (%.2f%%)",
+	}
+	for _, team := range sortedKeys(apps) {
+		t.add(team, apps[team], covered[team], float64(covered[team])/float64(apps[team])*100)
+	}
+	s.table(t)
+	s.line("")
+
+	t = &table{
+		caption: fmt.Sprintf("Apps never scanned by a %v tool:", strings.Join(toolTypes, "/")),
+		columns: []string{"App", "LoB/Team", "Never scanned by"},
+		format:  "  %v (%v) has never had a %v scan",
+	}
+	for _, c := range cov {
+		var missing []string
+		for _, tt := range toolTypes {
+			if !c.types[tt] {
+				missing = append(missing, tt)
+			}
+		}
+		if len(missing) > 0 {
+			t.add(c.app, c.team, strings.Join(missing, " or "))
+		}
+	}
+	s.table(t)
+
+	return s
+}
+
+// App scores weighted by business criticality, how many apps of each tier
+// have critical and high findings and each LoB/Team's risk exposure
+func riskSection(when string, m *tfMonth, appTeams map[string]string) section {
+	s := section{title: "Business Risk"}
+	s.line("Business risk for %v", when)
+	var weights []string
+	for _, t := range critTiers {
+		weights = append(weights, fmt.Sprintf("%v x%v", t, tierWeight[t]))
+	}
+	s.line("App scores are weighted by criticality: %v. Apps without a known criticality count as %v.",
+		strings.Join(weights, ", "), defaultTier)
+
+	// Apps of each tier with crits and highs
+	apps := make(map[string]int)
+	crits := make(map[string]int)
+	highs := make(map[string]int)
+	for app, _ := range appTeams {
+		t := appTier(app)
+		apps[t]++
+		if m.critApps[app] > 0 {
+			crits[t]++
+		}
+		if m.highApps[app] > 0 {
+			highs[t]++
+		}
+	}
+	pcnt := func(n int, of int) float64 {
+		if of == 0 {
+			return 0
+		}
+		return float64(n) / float64(of) * 100
+	}
+	first := critTiers[0]
+	s.line("%v of %v %v tier apps have critical findings (%.2f%%)", crits[first], apps[first], first,
+		pcnt(crits[first], apps[first]))
+	s.line("")
+	t := &table{
+		caption: "Apps with critical and high findings by criticality tier:",
+		columns: []string{"Tier", "Apps", "Apps with criticals", "Critical %", "Apps with highs", "High %"},
+		format:  "  %[1]v tier has %[2]v apps, %[3]v with criticals (%.2[4]f%%) and %[5]v with highs (%.2[6]f%%)",
+	}
+	for _, tier := range critTiers {
+		t.add(tier, apps[tier], crits[tier], pcnt(crits[tier], apps[tier]), highs[tier], pcnt(highs[tier], apps[tier]))
+	}
+	s.table(t)
+	s.line("")
+
+	// Risk exposure of each LoB/Team is the sum of its apps' weighted scores
+	scores := appScores(m.search)
+	risk := riskScores(scores)
+	exposure := make(map[string]int)
+	for _, team := range appTeams {
+		exposure[team] = 0
+	}
+	for app, r := range risk {
+		if team, ok := appTeams[app]; ok {
+			exposure[team] += r
+		}
+	}
+	t = &table{
+		caption: "Risk exposure per LoB/Team (sum of weighted app scores):",
+		columns: []string{"LoB/Team", "Risk score", "Critical tier apps", "Critical tier apps with criticals"},
+		format:  "  %[1]v has a risk score of %[2]v, %[3]v critical tier apps and %[4]v of them with criticals",
+	}
+	tierApps := make(map[string]int)
+	tierCrits := make(map[string]int)
+	for app, team := range appTeams {
+		if appTier(app) == first {
+			tierApps[team]++
+			if m.critApps[app] > 0 {
+				tierCrits[team]++
+			}
+		}
+	}
+	for _, r := range rankCounts(exposure, false) {
+		t.add(r.name, r.count, tierApps[r.name], tierCrits[r.name])
+	}
+	s.table(t)
+	s.line("")
+
+	t = &table{
+		caption: "The riskiest apps (score weighted by criticality): (smaller is better)",
+		columns: []string{"App", "LoB/Team", "Tier", "Score", "Risk score"},
+		format:  "  %[1]v (%[2]v, %[3]v) has a risk score of %[5]v from a score of %[4]v",
+	}
+	ranked := rankCounts(risk, false)
+	if len(ranked) > 10 {
+		ranked = ranked[:10]
+	}
+	for _, r := range ranked {
+		t.add(r.name, appTeams[r.name], appTier(r.name), scores[r.name], r.count)
+	}
+	s.table(t)
+
+	return s
+}
+
+// Sudden spikes or drops in a month's findings per LoB/Team and scanner
+func anomalySection(when string, found []anomaly) section {
+	s := section{title: "Anomalies"}
+	method := "the median absolute deviation"
+	if anomalyMethod == "zscore" {
+		method = "the mean and standard deviation"
+	}
+	s.line("Findings per LoB/Team and scanner for %v compared with the %v months before by %v", when, anomalyMonths, method)
+	s.line("Flagged at a score of %v or more either way and a change of at least %v findings", anomalyThreshold, anomalyMinChange)
+	if len(found) == 0 {
+		s.line("No anomalies found")
+		return s
+	}
+	t := &table{
+		caption: "Anomalies:",
+		columns: []string{"Type", "Name", "Change", "Findings", "Typical", "Score"},
+		format:  "  %v %v: %v - %.0f findings against a typical %.1f (score %v)",
+	}
+	for _, a := range found {
+		score := fmt.Sprintf("%+.1f", a.score)
+		if math.IsInf(a.score, 0) {
+			score = "no variation before"
+		}
+		t.add(a.kind, a.name, a.change(), a.count, a.typical, score)
+	}
+	s.table(t)
+
+	return s
+}
+
+// The next quarter forecast by each method with its confidence band
+func forecastSection(next string, fcs []quarterForecast, months int) section {
+	s := section{title: "Next Quarter Forecast"}
+	if len(fcs) == 0 {
+		s.line("Not enough history to forecast %v, %v full months found and at least 3 are needed", next, months)
+		return s
+	}
+	s.line("Forecast for %v from the last %v full months with %v%% confidence bands", next, months, forecastConfidence)
+	t := &table{
+		caption: "Forecasts:",
+		columns: []string{"Metric", "Method", "Forecast", "Low", "High"},
+		format:  "  %v by %v: %.1f (%.1f to %.1f)",
+	}
+	for _, f := range fcs {
+		t.add(f.metric, strings.ToLower(f.method), f.value, f.low, f.high)
+	}
+	s.table(t)
+
+	return s
+}
+
+// The rules checked and what broke them
+func checkSection(results []ruleResult) section {
+	s := section{title: "Policy Check"}
+	if checkMonths > 0 {
+		s.line("%v rules checked against open findings found in the last %v months", len(results), checkMonths)
+	} else {
+		s.line("%v rules checked against all open findings", len(results))
+	}
+	s.line("%v rule violations", violations(results))
+	t := &table{
+		caption: "Rules:",
+		columns: []string{"Rule", "Checked", "Violations"},
+		format:  "  %v - %v checked, %v violations",
+	}
+	for _, rr := range results {
+		t.add(rr.spec, len(rr.values), len(rr.broken))
+	}
+	s.table(t)
+	s.line("")
+	t = &table{
+		caption: "Violations:",
+		columns: []string{"Rule", "Name", "Value"},
+		format:  "  %v: %v has %v",
+	}
+	for _, rr := range results {
+		for _, name := range rr.broken {
+			t.add(rr.spec, name, rr.format(rr.values[name]))
+		}
+	}
+	s.table(t)
+
+	return s
+}
+
+// Whether each target is met, how far off it is and when the trend meets it
+func targetSection(when string, sts []targetStatus, err error) section {
+	s := section{title: "Targets"}
+	if err != nil {
+		s.line("Can't check the targets: %v", strings.TrimSpace(err.Error()))
+		return s
+	}
+	s.line("Targets as of %v, trends are over the last %v months", when, targetMonths)
+	var metrics []string
+	for _, m := range sortedKeys(targetMetrics) {
+		metrics = append(metrics, fmt.Sprintf("%v is the %v", m, targetMetrics[m]))
+	}
+	s.line("%v", strings.Join(metrics, ", "))
+	t := &table{
+		caption: "Targets:",
+		columns: []string{"Target", "Status", "Current", "Gap", "Change a month", "Projected"},
+		format:  "  %v - %v: now %v, gap %v, changing %v a month, projected %v",
+	}
+	for _, ts := range sts {
+		if !ts.known {
+			t.add(ts.spec, "Unknown ("+ts.why+")", "-", "-", "-", "-")
+			continue
+		}
+		projected := "-"
+		if !ts.projected.IsZero() {
+			projected = ts.projected.Format(monthKey)
+		}
+		t.add(ts.spec, ts.status, fmt.Sprintf("%.1f", ts.current), fmt.Sprintf("%.1f", ts.gap),
+			fmt.Sprintf("%+.2f", ts.slope), projected)
+	}
+	s.table(t)
+
+	return s
+}
+
+// Leaderboard of LoB/Team scorecards, best first, and what drove each grade
+func scorecardSection(when string, scores []teamScore) section {
+	s := section{title: "LoB/Team Scorecards"}
+	s.line("Scorecards for %v", when)
+	var weights []string
+	for _, p := range scoreParts {
+		weights = append(weights, fmt.Sprintf("%v %v", p, scoreWeights[p]))
+	}
+	s.line("Each part is scored 0 to 100 and weighted: %v", strings.Join(weights, ", "))
+	var slas []string
+	for sev := 5; sev >= 1; sev-- {
+		if !hasSev(searchSeverities, sev) {
+			continue
+		}
+		slas = append(slas, fmt.Sprintf("%v %v days", strings.ToLower(sevNames[sev]), slaDays[sev]))
+	}
+	s.line("SLAs are %v", strings.Join(slas, ", "))
+	s.line("")
+	t := &table{
+		caption: "Leaderboard:",
+		columns: []string{"Rank", "LoB/Team", "Grade", "Score", "Open", "SLA", "Coverage", "Trend"},
+		format:  "  %v. %v %v (%.1f) - open %.0f, sla %.0f, coverage %v, trend %.0f",
+	}
+	for i, ts := range scores {
+		cov := "-"
+		if ts.hasCov {
+			cov = fmt.Sprintf("%.0f", ts.parts["coverage"])
+		}
+		t.add(i+1, ts.team, ts.grade, ts.score, ts.parts["open"], ts.parts["sla"], cov, ts.parts["trend"])
+	}
+	s.table(t)
+	s.line("")
+	t = &table{
+		caption: "What drove each grade:",
+		columns: []string{"LoB/Team", "Grade", "Explanation"},
+		format:  "  %v (%v): %v",
+	}
+	for _, ts := range scores {
+		t.add(ts.team, ts.grade, ts.explain())
+	}
+	s.table(t)
+
+	return s
+}
+
+// Findings grouped by the categories of each scheme reported on, overall and
+// per LoB/Team
+func categorySection(title string, when string, cats map[string]map[string]VulnCount,
+	byLob map[string]map[string]map[string]int) section {
+	s := section{title: title}
+	for _, name := range reportSchemes {
+		totals := make(map[string]int)
+		for c, n := range cats[name] {
+			totals[c] = n.crit + n.high + n.med + n.low
+		}
+		t := &table{
+			caption: fmt.Sprintf("Findings by %v category for %v", schemeTitle(name), when),
+			columns: []string{"Category", "Findings", "Critical", "High", "Medium", "Low"},
+			format:  "  %[1]v has %[2]v findings (crit/high/med/low): %[3]v,%[4]v,%[5]v,%[6]v",
+		}
+		for _, r := range rankCounts(totals, false) {
+			c := cats[name][r.name]
+			t.add(r.name, r.count, c.crit, c.high, c.med, c.low)
+		}
+		s.table(t)
+		lt := &table{
+			caption: fmt.Sprintf("Findings by %v category per LoB/Team for %v", schemeTitle(name), when),
+			columns: []string{"LoB/Team", "Findings", "Category"},
+			format:  "  %v has %v findings in %v",
+		}
+		for _, lob := range rankCounts(totalLobs(byLob[name]), false) {
+			for _, r := range rankCounts(byLob[name][lob.name], false) {
+				lt.add(lob.name, r.count, r.name)
+			}
+		}
+		s.table(lt)
+		s.line("")
+	}
+
+	return s
+}
+
+// Findings per LoB/Team across all of their categories
+func totalLobs(byLob map[string]map[string]int) map[string]int {
+	tot := make(map[string]int)
+	for lob, cats := range byLob {
+		tot[lob] = totalMap(cats)
+	}
+
+	return tot
+}
+
+///////////////////////////////////////
+// Renderers for the output formats  //
+///////////////////////////////////////
+
+func writeText(w io.Writer, r *report) {
+	for _, s := range r.sections {
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "==========[%v]==========\n", s.title)
+		for _, b := range s.blocks {
+			if b.tbl == nil {
+				fmt.Fprintln(w, b.text)
+				continue
+			}
+			if b.tbl.caption != "" {
+				fmt.Fprintln(w, b.tbl.caption)
+			}
+			for _, row := range b.tbl.rows {
+				fmt.Fprintf(w, b.tbl.format+"\n", row...)
+			}
+		}
+	}
+}
+
+var htmlTmpl = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; margin: 0 0 1em 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Created}}</p>
+{{range .Sections}}<h2>{{.Title}}</h2>
+{{range .Blocks}}{{if .Table}}{{with .Table}}{{if .Caption}}<p>{{.Caption}}</p>
+{{end}}<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{else if .Text}}<p>{{.Text}}</p>
+{{end}}{{end}}{{end}}</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, r *report) error {
+	return htmlTmpl.Execute(w, r.view())
+}
+
+// CSV output writes each table as its own block: a row naming the section and
+// table, the column names, then the rows with a blank row between tables
+func writeCSV(w io.Writer, r *report) error {
+	c := csv.NewWriter(w)
+	for _, s := range r.sections {
+		for _, b := range s.blocks {
+			if b.tbl == nil {
+				continue
+			}
+			c.Write([]string{s.title, b.tbl.caption})
+			c.Write(b.tbl.columns)
+			for _, row := range b.tbl.rows {
+				rec := make([]string, len(row))
+				for i, v := range row {
+					rec[i] = fmt.Sprint(v)
+				}
+				c.Write(rec)
+			}
+			c.Write([]string{})
+		}
+	}
+	c.Flush()
+
+	return c.Error()
+}
+
+func writeJSON(w io.Writer, r *report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r.view())
+}
+
+// Write the report to dir as base.txt, base.html, base.csv and base.json
+func saveReport(dir string, base string, r *report) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	writers := map[string]func(io.Writer, *report) error{
+		".txt": func(w io.Writer, r *report) error {
+			writeText(w, r)
+			return nil
+		},
+		".html": writeHTML,
+		".csv":  writeCSV,
+		".json": writeJSON,
+	}
+	for ext, write := range writers {
+		f, err := os.Create(filepath.Join(dir, base+ext))
+		if err != nil {
+			return err
+		}
+		err = write(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Exported view of a report used by the HTML template and JSON encoder
+type reportView struct {
+	Title    string        `json:"title"`
+	Team     string        `json:"team,omitempty"`
+	Month    string        `json:"month"`
+	Quarter  string        `json:"quarter"`
+	Created  string        `json:"created"`
+	Sections []sectionView `json:"sections"`
+}
+
+type sectionView struct {
+	Title  string      `json:"title"`
+	Blocks []blockView `json:"blocks"`
+}
+
+type blockView struct {
+	Text  string     `json:"text,omitempty"`
+	Table *tableView `json:"table,omitempty"`
+}
+
+type tableView struct {
+	Caption string          `json:"caption,omitempty"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+func (r *report) view() reportView {
+	v := reportView{
+		Title:   r.title,
+		Team:    r.team,
+		Month:   r.month,
+		Quarter: r.quarter,
+		Created: r.created.Format(time.RFC1123),
+	}
+	for _, s := range r.sections {
+		sv := sectionView{Title: s.title}
+		for _, b := range s.blocks {
+			if b.tbl == nil {
+				// Skip blank lines used for spacing in text output
+				if b.text != "" {
+					sv.Blocks = append(sv.Blocks, blockView{Text: b.text})
+				}
+				continue
+			}
+			rows := b.tbl.rows
+			if rows == nil {
+				rows = [][]interface{}{}
+			}
+			sv.Blocks = append(sv.Blocks, blockView{Table: &tableView{
+				Caption: b.tbl.caption,
+				Columns: b.tbl.columns,
+				Rows:    rows,
+			}})
+		}
+		v.Sections = append(v.Sections, sv)
+	}
+
+	return v
+}
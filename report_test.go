@@ -0,0 +1,73 @@
+// report_test.go
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTrendSection(t *testing.T) {
+	tests := []struct {
+		name string
+		end  string
+		lobs map[string][]int // critical findings per LoB/Team, oldest first, fakeSearch's if nil
+		want [][]interface{}  // LoB/Team rows, most critical and high findings first
+	}{
+		{"fake findings", "2026-10-19", nil, [][]interface{}{
+			{"Pay", 2, 2, 2},
+			{"Retail & Co", 1, 1, 1},
+		}},
+		{"rising", "2026-10-19", map[string][]int{"Pay": {2, 2, 2}, "Retail & Co": {1, 3, 8}}, [][]interface{}{
+			{"Retail & Co", 1, 3, 8},
+			{"Pay", 2, 2, 2},
+		}},
+		// A LoB/Team missing from a month has none that month
+		{"new team", "2026-09-30", map[string][]int{"Pay": {2, 2, 2}, "Cards": {0, 0, 7}}, [][]interface{}{
+			{"Cards", 0, 0, 7},
+			{"Pay", 2, 2, 2},
+		}},
+	}
+	for _, tt := range tests {
+		ms := fakeMonths(mustDay(t, tt.end), 3)
+		if tt.lobs != nil {
+			for i, m := range ms {
+				m.vulnByLob = make(map[string]VulnCount)
+				for lob, crits := range tt.lobs {
+					if c := crits[len(ms)-1-i]; c > 0 {
+						m.vulnByLob[lob] = VulnCount{crit: c}
+					}
+				}
+			}
+		}
+		s := trendSection(ms)
+		var tables []*table
+		for _, b := range s.blocks {
+			if b.tbl != nil {
+				tables = append(tables, b.tbl)
+			}
+		}
+		if len(tables) != 2 {
+			t.Errorf("%v: got %v tables want 2", tt.name, len(tables))
+			continue
+		}
+
+		// Months oldest first, the month under way marked as such
+		var periods []string
+		for _, r := range tables[0].rows {
+			periods = append(periods, fmt.Sprint(r[0], " ", r[1]))
+		}
+		first, second, last := ms[2].tStamp.Format(monthKey), ms[1].tStamp.Format(monthKey), ms[0].tStamp.Format(monthKey)
+		if ms[0].mpartial {
+			last += " (so far)"
+		}
+		want := []string{first + " 6", second + " 6", last + " 6"}
+		if !reflect.DeepEqual(periods, want) {
+			t.Errorf("%v: got periods %v want %v", tt.name, periods, want)
+		}
+		cols := []string{"LoB/Team", first, second, ms[0].tStamp.Format(monthKey)}
+		if !reflect.DeepEqual(tables[1].columns, cols) || !reflect.DeepEqual(tables[1].rows, tt.want) {
+			t.Errorf("%v: got %v %v want %v %v", tt.name, tables[1].columns, tables[1].rows, cols, tt.want)
+		}
+	}
+}
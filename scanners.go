@@ -0,0 +1,127 @@
+// scanners.go
+// scanner overlap, severity mix and false positive rates
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// What each scanner found. A finding reported by more than one scanner is
+// counted once for each of them.
+type scannerStats struct {
+	found    map[string]int       // map of [scanner] open findings reported, the same as toolUsage
+	unique   map[string]int       // map of [scanner] findings no other scanner reported
+	shared   map[string]int       // map of [scanner] findings at least one other scanner reported too
+	bySev    map[string]VulnCount // map of [scanner] findings per severity
+	overlap  map[string]int       // map of [scanner + scanner] findings both reported
+	byCount  map[int]int          // map of [number of scanners] findings reported by that many
+	falsePos map[string]int       // map of [scanner] findings marked as false positives
+}
+
+// Gather the open and false positive findings of the n months ending with the
+// month t is in and work out what each scanner found
+func fetchScanners(t time.Time, n int) (*scannerStats, []*tfMonth, error) {
+	ms, err := fetchMonths(t, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	fps := make([]*tf.SrchResp, n)
+	err = fetchAll(n, func(i int) error {
+		var search tf.SrchResp
+		ts := ms[i].tStamp
+		st := time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, time.UTC)
+		capped, err := cappedSearch(st, monthEnd(ts), "falsePositive", &search)
+		if capped {
+			fmt.Fprintf(os.Stderr, "Warning: False positives from %v to %v reached threadfix.max_results (%v), some may be "+
+				"missing and the false positive rates too low\n", st.Format(dayKey), monthEnd(ts).Format(dayKey), maxResults)
+		}
+		if err != nil {
+			return err
+		}
+		fps[i] = &search
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var open []*tf.SrchResp
+	for _, m := range ms {
+		open = append(open, m.search)
+	}
+
+	return scannerCounts(joinSearches(open...), joinSearches(fps...)), ms, nil
+}
+
+// Work out what each scanner found from the open findings and those marked
+// as false positives
+func scannerCounts(open *tf.SrchResp, fps *tf.SrchResp) *scannerStats {
+	st := &scannerStats{
+		found:    make(map[string]int),
+		unique:   make(map[string]int),
+		shared:   make(map[string]int),
+		bySev:    make(map[string]VulnCount),
+		overlap:  make(map[string]int),
+		byCount:  make(map[int]int),
+		falsePos: make(map[string]int),
+	}
+
+	for k, _ := range open.Results {
+		scanners := scannerNames(open.Results[k])
+		if len(scanners) == 0 {
+			continue
+		}
+		st.byCount[len(scanners)]++
+		for i, s := range scanners {
+			sumApps(st.found, s, 1)
+			sumVulns(st.bySev, s, open.Results[k].Severity.Value)
+			if len(scanners) == 1 {
+				sumApps(st.unique, s, 1)
+				continue
+			}
+			sumApps(st.shared, s, 1)
+			for _, o := range scanners[i+1:] {
+				sumApps(st.overlap, s+" + "+o, 1)
+			}
+		}
+	}
+
+	for k, _ := range fps.Results {
+		for _, s := range scannerNames(fps.Results[k]) {
+			sumApps(st.falsePos, s, 1)
+		}
+	}
+
+	return st
+}
+
+// The scanners that reported a finding, sorted and each listed once
+func scannerNames(r tf.Result) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, s := range r.Scanners {
+		if !seen[s] {
+			seen[s] = true
+			names = append(names, s)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Percentage of a scanner's findings marked as false positives, open and false
+// positive findings together
+func (st *scannerStats) fpRate(scanner string) float64 {
+	all := st.found[scanner] + st.falsePos[scanner]
+	if all == 0 {
+		return 0
+	}
+
+	return float64(st.falsePos[scanner]) / float64(all) * 100
+}
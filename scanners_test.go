@@ -0,0 +1,71 @@
+// scanners_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Findings of severity 3 reported by each list of scanners
+func scannerSearch(scanners ...[]string) *tf.SrchResp {
+	var s tf.SrchResp
+	for _, ss := range scanners {
+		var r tf.Result
+		r.Severity.Value = 3
+		r.Scanners = ss
+		s.Results = append(s.Results, r)
+	}
+
+	return &s
+}
+
+func TestScannerCounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		open    *tf.SrchResp
+		fps     *tf.SrchResp
+		found   map[string]int
+		unique  map[string]int
+		overlap map[string]int
+		byCount map[int]int
+		rates   map[string]float64
+	}{
+		// ZAP and Fortify share pay-web's high, Burp finds everything at shop and cart alone
+		{"fake findings", fakeSearch(), scannerSearch([]string{"ZAP"}, []string{"ZAP"}, []string{"Burp"}),
+			map[string]int{"ZAP": 2, "Fortify": 2, "Burp": 3},
+			map[string]int{"ZAP": 1, "Fortify": 1, "Burp": 3},
+			map[string]int{"Fortify + ZAP": 1},
+			map[int]int{1: 5, 2: 1},
+			map[string]float64{"ZAP": 50, "Fortify": 0, "Burp": 25, "Checkmarx": 0}},
+		// Scanners listed twice count once and findings without any are left out
+		{"repeats", scannerSearch([]string{"ZAP", "ZAP"}, []string{"Burp", "ZAP", "Fortify"}, nil), scannerSearch(),
+			map[string]int{"ZAP": 2, "Fortify": 1, "Burp": 1},
+			map[string]int{"ZAP": 1},
+			map[string]int{"Burp + Fortify": 1, "Burp + ZAP": 1, "Fortify + ZAP": 1},
+			map[int]int{1: 1, 3: 1},
+			map[string]float64{"ZAP": 0}},
+		// A scanner with only false positives has a rate of 100%
+		{"only false positives", scannerSearch([]string{"ZAP"}), scannerSearch([]string{"Burp"}, []string{"Burp"}),
+			map[string]int{"ZAP": 1},
+			map[string]int{"ZAP": 1},
+			map[string]int{},
+			map[int]int{1: 1},
+			map[string]float64{"Burp": 100, "ZAP": 0}},
+	}
+	for _, tt := range tests {
+		st := scannerCounts(tt.open, tt.fps)
+		if !reflect.DeepEqual(st.found, tt.found) || !reflect.DeepEqual(st.unique, tt.unique) {
+			t.Errorf("%v: found %v unique %v want %v %v", tt.name, st.found, st.unique, tt.found, tt.unique)
+		}
+		if !reflect.DeepEqual(st.overlap, tt.overlap) || !reflect.DeepEqual(st.byCount, tt.byCount) {
+			t.Errorf("%v: overlap %v by count %v want %v %v", tt.name, st.overlap, st.byCount, tt.overlap, tt.byCount)
+		}
+		for s, want := range tt.rates {
+			if got := st.fpRate(s); !near(got, want) {
+				t.Errorf("%v: %v false positive rate %v want %v", tt.name, s, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// scans.go
+// scan / upload history per app, used to count real assessments
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// One scan or upload of scanner results to an app
+type tfScan struct {
+	app     string
+	team    string
+	scanner string
+	date    time.Time
+	vulns   int // findings in the scan, 0 for a clean scan
+	closed  int // findings the scan no longer found, closing them
+}
+
+// Scans of every app, gathered with the summary when threadfix.url is set
+var scanHistory []tfScan
+
+// ThreadFix criticality (Critical, High, Medium or Low) of each app by name,
+// gathered along with the scan history
+var appCriticality = make(map[string]string)
+
+// The parts of ThreadFix's application details needed for its scans
+type appResp struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Object  struct {
+		Criticality struct {
+			Name string `json:"name"`
+		} `json:"applicationCriticality"`
+		Scans []struct {
+			ImportTime int64  `json:"importTime"` // milliseconds since 1970
+			Scanner    string `json:"scannerName"`
+			Vulns      int    `json:"numberTotalVulnerabilities"`
+			Closed     int    `json:"numberClosedVulnerabilities"`
+		} `json:"scans"`
+	} `json:"object"`
+}
+
+// Scan history needs ThreadFix's URL and API key as tfclient's search
+// doesn't cover it
+func scansWanted() bool {
+	return getenv("TFM_TF_URL") != "" && getenv("TFM_TF_API_KEY") != ""
+}
+
+// Gather the scans and criticality of every app in teams, fetchWorkers apps
+// at a time, setting appCriticality
+func fetchScans(teams *tf.TeamResp) ([]tfScan, error) {
+	scans, crits, err := fetchScanHistory(teams)
+	if err != nil {
+		return nil, err
+	}
+	appCriticality = crits
+
+	return scans, nil
+}
+
+// Same as fetchScans but returns the criticality of each app rather than
+// setting appCriticality
+func fetchScanHistory(teams *tf.TeamResp) ([]tfScan, map[string]string, error) {
+	type teamApp struct {
+		team string
+		app  tf.App
+	}
+	var apps []teamApp
+	for _, tm := range teams.Tm {
+		for _, a := range tm.Apps {
+			apps = append(apps, teamApp{tm.Name, a})
+		}
+	}
+
+	// Empty rather than nil so reports know the history was gathered
+	var mu sync.Mutex
+	scans := make([]tfScan, 0)
+	crits := make(map[string]string)
+	err := fetchAll(len(apps), func(i int) error {
+		ss, crit, err := appScans(apps[i].team, apps[i].app)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		scans = append(scans, ss...)
+		crits[apps[i].app.Name] = crit
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return scans, crits, nil
+}
+
+// Ask ThreadFix for an app's details and pull out its scans and criticality.
+// Scanners filtered out are left out.
+func appScans(team string, app tf.App) ([]tfScan, string, error) {
+	u := strings.TrimSuffix(getenv("TFM_TF_URL"), "/") + "/rest/applications/" + strconv.Itoa(app.Id)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "APIKEY "+getenv("TFM_TF_API_KEY"))
+	req.Header.Set("Accept", "application/json")
+	resp, err := tfc.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Can't get the scans for app %v: %v\n", app.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("Can't get the scans for app %v: ThreadFix returned %v\n", app.Name, resp.Status)
+	}
+	var ar appResp
+	err = json.NewDecoder(resp.Body).Decode(&ar)
+	if err != nil {
+		return nil, "", fmt.Errorf("Can't read the scans for app %v: %v\n", app.Name, err)
+	}
+	if !ar.Success {
+		return nil, "", fmt.Errorf("Can't get the scans for app %v: %v\n", app.Name, ar.Message)
+	}
+
+	var scans []tfScan
+	for _, s := range ar.Object.Scans {
+		if !findingFilter.scanner(s.Scanner) {
+			continue
+		}
+		scans = append(scans, tfScan{
+			app:     app.Name,
+			team:    team,
+			scanner: s.Scanner,
+			date:    time.UnixMilli(s.ImportTime).UTC(),
+			vulns:   s.Vulns,
+			closed:  s.Closed,
+		})
+	}
+
+	return scans, ar.Object.Criticality.Name, nil
+}
+
+// Count the scans from the start to the end day, both included, into m. The
+// counts are left nil if there's no scan history.
+func countScans(m *tfMonth, st time.Time, e time.Time) {
+	if scanHistory == nil {
+		return
+	}
+	m.scansByTool = make(map[string]int)
+	m.scansByLob = make(map[string]int)
+	m.totScans, m.cleanScans = 0, 0
+	end := e.AddDate(0, 0, 1)
+	for _, s := range scanHistory {
+		if s.date.Before(st) || !s.date.Before(end) {
+			continue
+		}
+		m.totScans++
+		if s.vulns == 0 {
+			m.cleanScans++
+		}
+		sumApps(m.scansByTool, s.scanner, 1)
+		sumApps(m.scansByLob, s.team, 1)
+	}
+}
@@ -0,0 +1,126 @@
+// scans_test.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+func TestAppScans(t *testing.T) {
+	defer func(c *http.Client, f filters) { tfc, findingFilter = c, f }(tfc, findingFilter)
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		exclude string
+		want    string // scanner, time, findings and closed of each scan
+		crit    string
+		bad     bool
+	}{
+		{"scans", http.StatusOK, `{"success": true, "object": {"applicationCriticality": {"name": "High"}, "scans": [
+			{"importTime": 1789468200000, "scannerName": "OWASP Zap", "numberTotalVulnerabilities": 3, "numberClosedVulnerabilities": 1},
+			{"importTime": 1790811000000, "scannerName": "Burp Suite", "numberTotalVulnerabilities": 0}]}}`,
+			"", "OWASP Zap 2026-09-15T10:30:00Z 3 1|Burp Suite 2026-09-30T23:30:00Z 0 0", "High", false},
+		{"no scans", http.StatusOK, `{"success": true, "object": {"applicationCriticality": {"name": "Low"}, "scans": []}}`,
+			"", "", "Low", false},
+		// Scans by scanners filtered out are left out
+		{"filtered", http.StatusOK, `{"success": true, "object": {"scans": [
+			{"importTime": 1789468200000, "scannerName": "OWASP Zap", "numberTotalVulnerabilities": 3},
+			{"importTime": 1790811000000, "scannerName": "Burp Suite", "numberTotalVulnerabilities": 2}]}}`,
+			"scanner=burp suite", "OWASP Zap 2026-09-15T10:30:00Z 3 0", "", false},
+		{"not found", http.StatusOK, `{"success": false, "message": "No application found"}`, "", "", "", true},
+		{"server error", http.StatusInternalServerError, "", "", "", "", true},
+		{"bad json", http.StatusOK, `{"success": true, "object": {"scans": [`, "", "", "", true},
+	}
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/rest/applications/7" || r.Header.Get("Authorization") != "APIKEY secret" {
+				http.Error(w, "wrong request "+r.URL.Path, http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+		t.Setenv("TFM_TF_URL", ts.URL+"/")
+		t.Setenv("TFM_TF_API_KEY", "secret")
+		tfc = ts.Client()
+		findingFilter = filters{}
+		if tt.exclude != "" {
+			findingFilter.addAll("exclude", tt.exclude)
+		}
+
+		scans, crit, err := appScans("Pay", tf.App{Id: 7, Name: "pay-web"})
+		ts.Close()
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error, got %v", tt.name, scans)
+			}
+			continue
+		}
+		var got []string
+		for _, s := range scans {
+			if s.app != "pay-web" || s.team != "Pay" {
+				t.Errorf("%v: scan of %v in %v", tt.name, s.app, s.team)
+			}
+			got = append(got, fmt.Sprintf("%v %v %v %v", s.scanner, s.date.Format(time.RFC3339), s.vulns, s.closed))
+		}
+		if g := strings.Join(got, "|"); err != nil || g != tt.want || crit != tt.crit {
+			t.Errorf("%v: got %q %q %v want %q %q", tt.name, g, crit, err, tt.want, tt.crit)
+		}
+	}
+}
+
+func TestCountScans(t *testing.T) {
+	defer func(h []tfScan) { scanHistory = h }(scanHistory)
+	at := func(s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	history := []tfScan{
+		{app: "shop", team: "Retail & Co", scanner: "Burp", date: at("2026-08-31T23:59:00Z"), vulns: 1},
+		{app: "shop", team: "Retail & Co", scanner: "Burp", date: at("2026-09-01T00:00:00Z"), vulns: 2},
+		{app: "pay-web", team: "Pay", scanner: "ZAP", date: at("2026-09-15T10:30:00Z")},
+		{app: "pay-web", team: "Pay", scanner: "Fortify", date: at("2026-09-30T23:30:00Z"), vulns: 5},
+		{app: "pay-api", team: "Pay", scanner: "ZAP", date: at("2026-10-01T08:00:00Z")},
+	}
+	tests := []struct {
+		name    string
+		history []tfScan
+		st      string
+		e       string
+		tools   map[string]int
+		lobs    map[string]int
+		total   int
+		clean   int
+	}{
+		{"month", history, "2026-09-01", "2026-09-30",
+			map[string]int{"Burp": 1, "ZAP": 1, "Fortify": 1}, map[string]int{"Retail & Co": 1, "Pay": 2}, 3, 1},
+		// The end day is included up to midnight
+		{"a day", history, "2026-09-30", "2026-09-30", map[string]int{"Fortify": 1}, map[string]int{"Pay": 1}, 1, 0},
+		{"month so far", history, "2026-10-01", "2026-10-19", map[string]int{"ZAP": 1}, map[string]int{"Pay": 1}, 1, 1},
+		{"none", history, "2026-07-01", "2026-07-31", map[string]int{}, map[string]int{}, 0, 0},
+		// Without scan history the counts stay nil
+		{"no history", nil, "2026-09-01", "2026-09-30", nil, nil, 0, 0},
+	}
+	for _, tt := range tests {
+		scanHistory = tt.history
+		var m tfMonth
+		countScans(&m, mustDay(t, tt.st), mustDay(t, tt.e))
+		if !reflect.DeepEqual(m.scansByTool, tt.tools) || !reflect.DeepEqual(m.scansByLob, tt.lobs) {
+			t.Errorf("%v: got %v %v want %v %v", tt.name, m.scansByTool, m.scansByLob, tt.tools, tt.lobs)
+		}
+		if m.totScans != tt.total || m.cleanScans != tt.clean {
+			t.Errorf("%v: got %v scans %v clean want %v %v", tt.name, m.totScans, m.cleanScans, tt.total, tt.clean)
+		}
+	}
+}
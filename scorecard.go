@@ -0,0 +1,312 @@
+// scorecard.go
+// per LoB/Team scorecards combining open findings, SLA, coverage and trend
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Days a finding of each severity can stay open
+var slaDays = map[int]int{
+	5: 15,  // Critical
+	4: 30,  // High
+	3: 90,  // Medium
+	2: 180, // Low
+	1: 365, // Info
+}
+
+// Parts of a scorecard, each scored 0 to 100 where more is better
+var scoreParts = []string{"open", "sla", "coverage", "trend"}
+
+// How much each part counts towards the composite score
+var scoreWeights = map[string]int{
+	"open":     40,
+	"sla":      30,
+	"coverage": 20,
+	"trend":    10,
+}
+
+// Points taken off the open part for each open critical or high per app
+var openPenalty = 10.0
+
+// Months of findings a scorecard looks at, at least 6 for the trend
+var scorecardMonths = 12
+
+// Lowest composite score for each grade, best first. Lower scores get an F.
+type gradeLevel struct {
+	grade string
+	min   float64
+}
+
+var gradeLevels = []gradeLevel{{"A", 90}, {"B", 80}, {"C", 70}, {"D", 60}}
+
+// A LoB/Team's scorecard
+type teamScore struct {
+	team     string
+	apps     int
+	openCH   int                // open critical and high findings
+	perApp   float64            // open critical and high findings per app
+	open     int                // open findings with an SLA
+	breached int                // open findings past their SLA
+	slaPct   float64            // open findings within their SLA
+	covPct   float64            // apps scanned within their coverage window
+	hasCov   bool               // false without scan history, coverage is left out
+	cur      int                // critical and high findings found in the quarter so far
+	prev     int                // and in the same months of the quarter before
+	parts    map[string]float64 // score for each of scoreParts
+	score    float64            // weighted average of the parts
+	grade    string
+}
+
+// Score every LoB/Team from ms, newest first and at least 6 months, as of
+// asOf. cov is the scan coverage of every app, nil without scan history.
+func teamScores(ms []*tfMonth, cov []appCoverage, asOf time.Time) []teamScore {
+	byTeam := make(map[string]*teamScore)
+	for team, n := range teamCounts {
+		byTeam[team] = &teamScore{team: team, apps: n, parts: make(map[string]float64)}
+	}
+	get := func(team string) *teamScore {
+		if _, ok := byTeam[team]; !ok {
+			byTeam[team] = &teamScore{team: team, parts: make(map[string]float64)}
+		}
+		return byTeam[team]
+	}
+
+	// Months of the quarter so far, compared with the same months a quarter
+	// before so a quarter under way isn't compared with a whole one
+	n := 3 - monthsLeft(ms[0].tStamp)
+	for i, m := range ms {
+		// Findings found in a month are at least this old, from the end of the
+		// month or asOf for the month under way
+		found := monthEnd(m.tStamp)
+		if found.After(asOf) {
+			found = asOf
+		}
+		age := int(asOf.Sub(found).Hours() / 24)
+		for k, _ := range m.search.Results {
+			r := m.search.Results[k]
+			ts := get(r.Team.Name)
+			sev := r.Severity.Value
+			if sev >= 4 {
+				ts.openCH++
+				if i < n {
+					ts.cur++
+				} else if i >= 3 && i < 3+n {
+					ts.prev++
+				}
+			}
+			if days, ok := slaDays[sev]; ok {
+				ts.open++
+				if age > days {
+					ts.breached++
+				}
+			}
+		}
+	}
+	if cov != nil {
+		apps := make(map[string]int)
+		covered := make(map[string]int)
+		for _, c := range cov {
+			apps[c.team]++
+			if c.covered(asOf) {
+				covered[c.team]++
+			}
+		}
+		for team, n := range apps {
+			ts := get(team)
+			ts.hasCov = true
+			ts.covPct = float64(covered[team]) / float64(n) * 100
+		}
+	}
+
+	var scores []teamScore
+	for _, ts := range byTeam {
+		scoreTeam(ts)
+		scores = append(scores, *ts)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].score != scores[j].score {
+			return scores[i].score > scores[j].score
+		}
+		return scores[i].team < scores[j].team
+	})
+
+	return scores
+}
+
+// Work out the parts, composite score and grade from the counts
+func scoreTeam(ts *teamScore) {
+	if ts.apps > 0 {
+		ts.perApp = float64(ts.openCH) / float64(ts.apps)
+	}
+	ts.parts["open"] = clamp(100 - ts.perApp*openPenalty)
+	ts.slaPct = 100
+	if ts.open > 0 {
+		ts.slaPct = float64(ts.open-ts.breached) / float64(ts.open) * 100
+	}
+	ts.parts["sla"] = ts.slaPct
+	if ts.hasCov {
+		ts.parts["coverage"] = ts.covPct
+	}
+	// 50 for no change, up to 100 for none found after some the quarter before
+	switch {
+	case ts.prev == 0 && ts.cur == 0:
+		ts.parts["trend"] = 50
+	case ts.prev == 0:
+		ts.parts["trend"] = 0
+	default:
+		ts.parts["trend"] = clamp(50 + float64(ts.prev-ts.cur)/float64(ts.prev)*50)
+	}
+
+	total, weights := 0.0, 0
+	for _, p := range scoreParts {
+		v, ok := ts.parts[p]
+		if !ok {
+			continue
+		}
+		total += v * float64(scoreWeights[p])
+		weights += scoreWeights[p]
+	}
+	if weights > 0 {
+		ts.score = total / float64(weights)
+	}
+	ts.grade = gradeFor(ts.score)
+}
+
+func gradeFor(score float64) string {
+	for _, g := range gradeLevels {
+		if score >= g.min {
+			return g.grade
+		}
+	}
+
+	return "F"
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 100 {
+		return 100
+	}
+
+	return v
+}
+
+// What each part of a scorecard came from and the part that cost the most
+func (ts teamScore) explain() string {
+	var why []string
+	why = append(why, fmt.Sprintf("%.2f open critical/high per app (open %.0f)", ts.perApp, ts.parts["open"]))
+	why = append(why, fmt.Sprintf("%.0f%% of open findings within SLA, %v past it (sla %.0f)",
+		ts.slaPct, ts.breached, ts.parts["sla"]))
+	if ts.hasCov {
+		why = append(why, fmt.Sprintf("%.0f%% of apps scanned in time (coverage %.0f)", ts.covPct, ts.parts["coverage"]))
+	}
+	why = append(why, fmt.Sprintf("critical/high found went from %v to %v over the quarter (trend %.0f)",
+		ts.prev, ts.cur, ts.parts["trend"]))
+
+	// The part furthest from 100 once weighted
+	worst, lost := "", 0.0
+	for _, p := range scoreParts {
+		v, ok := ts.parts[p]
+		if !ok {
+			continue
+		}
+		if l := (100 - v) * float64(scoreWeights[p]); l > lost {
+			worst, lost = p, l
+		}
+	}
+	s := strings.Join(why, ", ")
+	if worst != "" {
+		s += ". Held back most by " + worst
+	}
+
+	return s
+}
+
+// Gather what a scorecard needs for the months ending with end, newest first
+func fetchScorecards(end time.Time) ([]teamScore, error) {
+	ms, err := fetchMonths(end, scorecardMonths)
+	if err != nil {
+		return nil, err
+	}
+	n := time.Now()
+	var cov []appCoverage
+	if scanHistory != nil {
+		cov = appCoverages(n)
+	}
+
+	return teamScores(ms, cov, n), nil
+}
+
+///////////////////////////////////////
+// Setters                           //
+///////////////////////////////////////
+
+func slaSetter(sev int) func(v string) error {
+	return func(v string) error {
+		n, err := positiveInt(v)
+		if err != nil {
+			return err
+		}
+		slaDays[sev] = n
+
+		return nil
+	}
+}
+
+func scoreWeightSetter(part string) func(v string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("must be a whole number, 0 or more, not %v", v)
+		}
+		scoreWeights[part] = n
+
+		return nil
+	}
+}
+
+func setOpenPenalty(v string) error {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a number, 0 or more, not %v", v)
+	}
+	openPenalty = n
+
+	return nil
+}
+
+func setScorecardMonths(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 6 {
+		return fmt.Errorf("must be 6 or more months not %v", v)
+	}
+	scorecardMonths = n
+
+	return nil
+}
+
+// Grades and their lowest scores e.g. A=90,B=80,C=70,D=60
+func setGrades(v []string) error {
+	var levels []gradeLevel
+	for _, g := range v {
+		name, min, ok := strings.Cut(g, "=")
+		n, err := strconv.ParseFloat(strings.TrimSpace(min), 64)
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("grades look like A=90,B=80 not %v", g)
+		}
+		levels = append(levels, gradeLevel{strings.TrimSpace(name), n})
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].min > levels[j].min
+	})
+	gradeLevels = levels
+
+	return nil
+}
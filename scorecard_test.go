@@ -0,0 +1,47 @@
+// scorecard_test.go
+package main
+
+import "testing"
+
+func TestTeamScoresQuarterTrend(t *testing.T) {
+	defer func(c map[string]int) { teamCounts = c }(teamCounts)
+	teamCounts = map[string]int{"Pay": 3, "Retail & Co": 2}
+	tests := []struct {
+		end       string
+		cur, prev int
+	}{
+		// Only October so far, against July
+		{"2026-10-19", 2, 2},
+		{"2026-11-19", 4, 4},
+		{"2026-12-31", 6, 6},
+	}
+	for _, tt := range tests {
+		end := mustDay(t, tt.end)
+		for _, ts := range teamScores(fakeMonths(end, 6), nil, end) {
+			if ts.team == "Pay" && (ts.cur != tt.cur || ts.prev != tt.prev) {
+				t.Errorf("%v: Pay went from %v to %v, want %v to %v", tt.end, ts.prev, ts.cur, tt.prev, tt.cur)
+			}
+		}
+	}
+}
+
+func TestTeamScoresSLA(t *testing.T) {
+	defer func(c map[string]int) { teamCounts = c }(teamCounts)
+	teamCounts = map[string]int{"Pay": 3, "Retail & Co": 2}
+	defer func(d map[int]int) { slaDays = d }(slaDays)
+	slaDays = map[int]int{5: 15, 4: 30}
+	asOf := mustDay(t, "2026-10-19")
+	ms := fakeMonths(asOf, 6)
+
+	for _, ts := range teamScores(ms, nil, asOf) {
+		if ts.team != "Pay" {
+			continue
+		}
+		// One critical and one high a month. October's are at most 0 days
+		// old, September's 19 so only the critical is past its SLA, and both
+		// are from August back.
+		if ts.open != 12 || ts.breached != 9 {
+			t.Errorf("Pay has %v open with an SLA and %v breached, want 12 and 9", ts.open, ts.breached)
+		}
+	}
+}
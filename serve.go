@@ -0,0 +1,147 @@
+// serve.go
+// long running serve mode - gathers metrics on an interval and serves them
+// to Prometheus at /metrics, as JSON under /api/v1 and as a web dashboard
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Defaults for serve mode which can be changed with --listen and --refresh or
+// TFM_LISTEN and TFM_REFRESH
+const defaultListen = ":9555"
+const defaultRefresh = 15 * time.Minute
+
+// Number of months kept by serve mode - enough for the last full fiscal year
+// as well as the one under way
+const historyMonths = 24
+
+// Everything gathered by one refresh from ThreadFix. A snapshot is never
+// changed once made. Handlers still hold the read lock while they use it as
+// working out metrics from it reads the globals a refresh sets.
+type snapshot struct {
+	taken      time.Time
+	apps       int                 // overall count of apps
+	teamCounts map[string]int      // Number of apps under each team/LoB
+	critsByLob map[string]int      // Number of criticals by team/LoB
+	teamApps   map[string][]string // app names under each team/LoB
+	months     []*tfMonth          // last historyMonths months, newest first
+}
+
+type server struct {
+	mu          sync.RWMutex
+	snap        *snapshot // last good refresh, nil until there is one
+	lastTook    float64   // seconds the last refresh took
+	refreshErrs int       // number of refreshes that failed
+}
+
+// Gather metrics every refresh and serve them on addr
+func serve(addr string, refresh time.Duration) error {
+	s := &server{}
+	log.Printf("Gathering metrics from ThreadFix every %v", refresh)
+	s.refresh()
+	go func() {
+		for range time.Tick(refresh) {
+			s.refresh()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.metrics)
+	s.api(mux)
+	s.dashboard(mux)
+	log.Printf("Serving the dashboard on %v, metrics on %v/metrics and the API under %v/api/v1", addr, addr, addr)
+
+	return http.ListenAndServe(addr, mux)
+}
+
+func (s *server) refresh() {
+	start := time.Now()
+	snap, err := s.gather(time.Now())
+	took := time.Since(start).Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTook = took
+	if err != nil {
+		s.refreshErrs++
+		log.Printf("Refreshing metrics from ThreadFix failed: %v", err)
+		return
+	}
+	s.snap = snap
+}
+
+// The last good snapshot or nil if there hasn't been one yet, for handlers
+// holding the read lock
+func (s *server) current() *snapshot {
+	return s.snap
+}
+
+// Run h holding the read lock so a refresh can't change the globals it reads
+// part way through
+func (s *server) locked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		h(w, r)
+	}
+}
+
+// Pull the summary and the last historyMonths months from ThreadFix. Only a
+// refresh changes the globals and it holds the lock while it does so handlers
+// never read them part way through.
+func (s *server) gather(n time.Time) (*snapshot, error) {
+	var teams tf.TeamResp
+	err := fetchTeams(tfc, &teams)
+	if err != nil {
+		return nil, err
+	}
+	var scans []tfScan
+	var crits map[string]string
+	if scansWanted() {
+		scans, crits, err = fetchScanHistory(&teams)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.setSummary(&teams, scans, crits)
+
+	snap := &snapshot{
+		apps:       appCount,
+		teamCounts: teamCounts,
+		critsByLob: critsByLob,
+		teamApps:   make(map[string][]string),
+	}
+	for _, t := range teams.Tm {
+		for _, a := range t.Apps {
+			snap.teamApps[t.Name] = append(snap.teamApps[t.Name], a.Name)
+		}
+	}
+
+	snap.months, err = fetchMonths(reportMonth(n), historyMonths)
+	if err != nil {
+		return nil, err
+	}
+	snap.taken = time.Now()
+
+	return snap, nil
+}
+
+// Set the summary globals and, if scans isn't nil, the scan history from a
+// refresh, holding the lock
+func (s *server) setSummary(teams *tf.TeamResp, scans []tfScan, crits map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	createSummary(teams)
+	resolveAppSizes(teams)
+	resolveAppTiers(teams)
+	if scans != nil {
+		scanHistory, appCriticality = scans, crits
+	}
+}
@@ -0,0 +1,50 @@
+// serve_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Run with -race, handlers read while refreshes change the globals and
+// snapshot
+func TestServeRefreshRace(t *testing.T) {
+	end := mustDay(t, "2026-10-19")
+	s := &server{snap: fakeSnap(end)}
+	mux := http.NewServeMux()
+	s.api(mux)
+	s.dashboard(mux)
+	teams := tf.TeamResp{Tm: []tf.Team{
+		{Name: "Pay", NumCrit: 1, Apps: []tf.App{{Id: 1, Name: "pay-web"}, {Id: 2, Name: "pay-api"}, {Id: 3, Name: "pay-x"}}},
+		{Name: "Retail & Co", NumCrit: 1, Apps: []tf.App{{Id: 4, Name: "shop"}, {Id: 5, Name: "cart"}}},
+	}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			s.setSummary(&teams, []tfScan{}, map[string]string{"shop": "High"})
+			snap := fakeSnap(end)
+			s.mu.Lock()
+			s.snap = snap
+			s.mu.Unlock()
+		}
+	}()
+	paths := []string{"/", "/lob?name=Pay", "/app?name=shop", "/api/v1/months", "/api/v1/quarters/Q3-2026",
+		"/api/v1/years/2025", "/api/v1/teams/Pay"}
+	for i := 0; i < 20; i++ {
+		for _, p := range paths {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("%v: status %v", p, w.Code)
+			}
+		}
+	}
+	wg.Wait()
+}
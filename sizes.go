@@ -0,0 +1,220 @@
+// sizes.go
+// app sizes from a local file, used to normalise scores into densities
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// How big an app is, any of which can be unknown
+type appSize struct {
+	loc       int    // lines of code, 0 if unknown
+	endpoints int    // endpoints / pages, 0 if unknown
+	class     string // manual size class e.g. M, empty if unknown
+}
+
+// Sizes by app name, lower case. Apps given by ThreadFix ID are keyed by the
+// ID until resolveAppSizes swaps it for their name.
+var appSizes map[string]appSize
+
+// Thousands of lines of code assumed for each size class when an app's lines
+// of code aren't known
+var sizeClassKLOC = map[string]float64{"S": 10, "M": 50, "L": 200, "XL": 500}
+
+// Read app sizes from a CSV file with a header row. The app column holds the
+// app's name or ThreadFix ID and at least one of loc, endpoints and size must
+// be there, e.g.
+//
+//	app,loc,endpoints,size
+//	pay-web,120000,85,L
+func setAppSizes(v string) error {
+	f, err := os.Open(v)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	head, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%v: can't read the header row: %v", v, err)
+	}
+	cols := make(map[string]int)
+	for i, h := range head {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["app"]; !ok {
+		return fmt.Errorf("%v: needs an app column", v)
+	}
+	_, hasLoc := cols["loc"]
+	_, hasEnd := cols["endpoints"]
+	_, hasSize := cols["size"]
+	if !hasLoc && !hasEnd && !hasSize {
+		return fmt.Errorf("%v: needs a loc, endpoints or size column", v)
+	}
+
+	sizes := make(map[string]appSize)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", v, err)
+		}
+		line, _ := r.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		app := strings.ToLower(field("app"))
+		if app == "" {
+			continue
+		}
+		var s appSize
+		for _, n := range []struct {
+			name string
+			to   *int
+		}{{"loc", &s.loc}, {"endpoints", &s.endpoints}} {
+			if val := field(n.name); val != "" {
+				*n.to, err = strconv.Atoi(strings.ReplaceAll(val, ",", ""))
+				if err != nil || *n.to < 0 {
+					return fmt.Errorf("%v:%v: %v must be a whole number not %v", v, line, n.name, val)
+				}
+			}
+		}
+		if c := strings.ToUpper(field("size")); c != "" {
+			if _, ok := sizeClassKLOC[c]; !ok {
+				return fmt.Errorf("%v:%v: size classes are %v not %v", v, line,
+					strings.Join(sizeClasses(), ", "), field("size"))
+			}
+			s.class = c
+		}
+		sizes[app] = s
+	}
+	appSizes = sizes
+
+	return nil
+}
+
+// KLOC for each size class e.g. S=10,M=50,L=200,XL=500
+func setSizeClasses(v []string) error {
+	classes := make(map[string]float64)
+	for _, c := range v {
+		name, kloc, ok := strings.Cut(c, "=")
+		n, err := strconv.ParseFloat(strings.TrimSpace(kloc), 64)
+		if !ok || err != nil || n <= 0 || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("size classes look like S=10,M=50 (thousands of lines of code) not %v", c)
+		}
+		classes[strings.ToUpper(strings.TrimSpace(name))] = n
+	}
+	if len(classes) == 0 {
+		return errors.New("must list at least one size class")
+	}
+	sizeClassKLOC = classes
+
+	return nil
+}
+
+// Size classes, smallest first
+func sizeClasses() []string {
+	var cs []string
+	for c, _ := range sizeClassKLOC {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		return sizeClassKLOC[cs[i]] < sizeClassKLOC[cs[j]]
+	})
+
+	return cs
+}
+
+// Key the sizes given by ThreadFix ID by app name instead
+func resolveAppSizes(teams *tf.TeamResp) {
+	for _, tm := range teams.Tm {
+		for _, a := range tm.Apps {
+			id := strconv.Itoa(a.Id)
+			if s, ok := appSizes[id]; ok {
+				if _, named := appSizes[strings.ToLower(a.Name)]; !named {
+					appSizes[strings.ToLower(a.Name)] = s
+				}
+				delete(appSizes, id)
+			}
+		}
+	}
+}
+
+// An app's size, false if it isn't in the sizes file
+func sizeOf(app string) (appSize, bool) {
+	s, ok := appSizes[strings.ToLower(app)]
+
+	return s, ok
+}
+
+// Thousands of lines of code, estimated from the size class if the lines of
+// code aren't known. 0 if neither is.
+func (s appSize) kloc() (float64, bool) {
+	if s.loc > 0 {
+		return float64(s.loc) / 1000, false
+	}
+	if k, ok := sizeClassKLOC[s.class]; ok {
+		return k, true
+	}
+
+	return 0, false
+}
+
+// An app's score normalised by its size
+type density struct {
+	app       string
+	score     int
+	kloc      float64
+	estimated bool    // kloc is from the size class
+	perKLOC   float64 // score per thousand lines of code, 0 if kloc isn't known
+	endpoints int
+	perEnd    float64 // score per endpoint, 0 if endpoints aren't known
+	class     string
+}
+
+// Densities of the apps in scores with a known size, densest first
+func appDensities(scores map[string]int) []density {
+	var ds []density
+	for app, score := range scores {
+		s, ok := sizeOf(app)
+		if !ok {
+			continue
+		}
+		d := density{app: app, score: score, endpoints: s.endpoints, class: s.class}
+		d.kloc, d.estimated = s.kloc()
+		if d.kloc > 0 {
+			d.perKLOC = float64(score) / d.kloc
+		}
+		if s.endpoints > 0 {
+			d.perEnd = float64(score) / float64(s.endpoints)
+		}
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		if ds[i].perKLOC != ds[j].perKLOC {
+			return ds[i].perKLOC > ds[j].perKLOC
+		}
+		if ds[i].perEnd != ds[j].perEnd {
+			return ds[i].perEnd > ds[j].perEnd
+		}
+		return ds[i].app < ds[j].app
+	})
+
+	return ds
+}
@@ -0,0 +1,109 @@
+// sizes_test.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+func TestSetAppSizes(t *testing.T) {
+	defer func(s map[string]appSize) { appSizes = s }(appSizes)
+	tests := []struct {
+		name string
+		csv  string
+		want map[string]appSize
+		bad  bool
+	}{
+		{"all columns", "app,loc,endpoints,size\nPay-Web,120000,85,L\nshop,,12,s\n",
+			map[string]appSize{"pay-web": {120000, 85, "L"}, "shop": {0, 12, "S"}}, false},
+		{"any order", "Size, App\nM, cart\n", map[string]appSize{"cart": {class: "M"}}, false},
+		{"thousands", "app,loc\npay-api,\"45,000\"\n,10\n", map[string]appSize{"pay-api": {loc: 45000}}, false},
+		// IDs are kept until resolveAppSizes
+		{"ids", "app,endpoints\n3,40\n", map[string]appSize{"3": {endpoints: 40}}, false},
+		{"no app", "name,loc\nshop,10\n", nil, true},
+		{"no size", "app,team\nshop,Retail\n", nil, true},
+		{"bad loc", "app,loc\nshop,lots\n", nil, true},
+		{"negative", "app,endpoints\nshop,-1\n", nil, true},
+		{"bad class", "app,size\nshop,XXL\n", nil, true},
+		{"empty", "", nil, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "sizes.csv")
+		if err := os.WriteFile(path, []byte(tt.csv), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		appSizes = nil
+		err := setAppSizes(path)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error, got %v", tt.name, appSizes)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(appSizes, tt.want) {
+			t.Errorf("%v: got %v %v want %v", tt.name, appSizes, err, tt.want)
+		}
+	}
+}
+
+func TestResolveAppSizes(t *testing.T) {
+	defer func(s map[string]appSize) { appSizes = s }(appSizes)
+	appSizes = map[string]appSize{"1": {loc: 1000}, "3": {loc: 3000}, "shop": {loc: 500}, "9": {loc: 9000}}
+	resolveAppSizes(&tf.TeamResp{Tm: []tf.Team{
+		{Name: "Pay", Apps: []tf.App{{Id: 1, Name: "Pay-Web"}, {Id: 2, Name: "pay-api"}}},
+		{Name: "Retail & Co", Apps: []tf.App{{Id: 3, Name: "shop"}}},
+	}})
+	// Names given win over IDs, unknown IDs are kept
+	want := map[string]appSize{"pay-web": {loc: 1000}, "shop": {loc: 500}, "9": {loc: 9000}}
+	if !reflect.DeepEqual(appSizes, want) {
+		t.Errorf("got %v want %v", appSizes, want)
+	}
+}
+
+func TestAppDensities(t *testing.T) {
+	defer func(s map[string]appSize, k map[string]float64) { appSizes, sizeClassKLOC = s, k }(appSizes, sizeClassKLOC)
+	appSizes = map[string]appSize{
+		"pay-web": {loc: 20000, endpoints: 10},
+		"pay-api": {class: "M"},
+		"shop":    {endpoints: 4},
+		"cart":    {loc: 5000, class: "XL"},
+	}
+	tests := []struct {
+		classes []string // size classes, the defaults if nil
+		scores  map[string]int
+		want    []string // app, KLOC, estimated, per KLOC and per endpoint, densest first
+	}{
+		{nil, map[string]int{"pay-web": 40, "pay-api": 100, "shop": 20, "cart": 10, "unsized": 500}, []string{
+			"pay-web 20 false 2 4",
+			"cart 5 false 2 0",
+			"pay-api 50 true 2 0",
+			"shop 0 false 0 5",
+		}},
+		{[]string{"M=10"}, map[string]int{"pay-web": 40, "pay-api": 100}, []string{
+			"pay-api 10 true 10 0",
+			"pay-web 20 false 2 4",
+		}},
+		{nil, map[string]int{"unsized": 500}, nil},
+	}
+	for _, tt := range tests {
+		sizeClassKLOC = map[string]float64{"S": 10, "M": 50, "L": 200, "XL": 500}
+		if tt.classes != nil {
+			if err := setSizeClasses(tt.classes); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var got []string
+		for _, d := range appDensities(tt.scores) {
+			got = append(got, fmt.Sprintf("%v %v %v %v %v", d.app, d.kloc, d.estimated, d.perKLOC, d.perEnd))
+		}
+		if g, w := strings.Join(got, "|"), strings.Join(tt.want, "|"); g != w {
+			t.Errorf("%v with %v: got %q want %q", tt.scores, tt.classes, g, w)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// targets.go
+// targets for key metrics, whether they're met and when the trend meets them
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics a target can be set for and what they measure
+var targetMetrics = map[string]string{
+	"crit_pct": "% of apps with critical findings",
+	"high_pct": "% of apps with high findings",
+	"coverage": "% of apps scanned within their coverage window",
+	"mttr":     "estimated mean days to remediate a finding",
+}
+
+// Days of closed findings the MTTR estimate is worked out from
+const mttrDays = 90
+
+// A target for a metric, e.g. Payments: crit_pct < 5 by Q4-2026
+type target struct {
+	spec   string    // as it was set
+	team   string    // LoB/Team it's for, empty for all of ThreadFix
+	metric string    // one of targetMetrics
+	op     string    // <, <=, > or >=
+	value  float64   // what the metric has to reach
+	by     time.Time // last day to reach it, zero if there's no deadline
+}
+
+// Targets set in the config, shown in every report
+var targets []target
+
+// Months of history the trend towards a target is worked out from
+var targetMonths = 6
+
+// Where a target stands as of a month
+type targetStatus struct {
+	target
+	known     bool      // false if the metric can't be worked out, see why
+	why       string    // why the metric isn't known
+	current   float64   // the metric now
+	met       bool      // current meets the target
+	gap       float64   // how far current is from the target, 0 once met
+	slope     float64   // change in the metric a month from the trend
+	projected time.Time // when the trend reaches the target, zero if met or it never will
+	status    string    // Met, On track, Behind, Not improving or Missed
+}
+
+// Read a target like [LoB/Team:] metric op value [by deadline] where the
+// deadline is a quarter (Q4-2026) or a month (2026-12)
+func parseTarget(spec string) (target, error) {
+	t := target{spec: spec}
+	rest := spec
+	if i := strings.LastIndex(rest, ":"); i >= 0 {
+		t.team = strings.TrimSpace(rest[:i])
+		rest = rest[i+1:]
+	}
+	rest, by, hasBy := strings.Cut(rest, " by ")
+	f := strings.Fields(rest)
+	if len(f) != 3 {
+		return t, fmt.Errorf("targets look like [LoB:] crit_pct < 5 [by Q4-2026] not %v", spec)
+	}
+
+	t.metric = strings.ToLower(f[0])
+	if _, ok := targetMetrics[t.metric]; !ok {
+		return t, fmt.Errorf("target metrics are %v not %v", strings.Join(sortedKeys(targetMetrics), ", "), f[0])
+	}
+	t.op = f[1]
+	if !oneOf(t.op, compareOps) {
+		return t, fmt.Errorf("targets compare with <, <=, > or >= not %v", f[1])
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(f[2], "%"), 64)
+	switch {
+	case t.metric == "mttr" && (err != nil || v < 0):
+		return t, fmt.Errorf("mttr targets are days, 0 or more, not %v", f[2])
+	case t.metric != "mttr" && (err != nil || v < 0 || v > 100):
+		return t, fmt.Errorf("target values are percentages from 0 to 100 not %v", f[2])
+	}
+	t.value = v
+	if hasBy {
+		t.by, err = targetDeadline(strings.TrimSpace(by))
+		if err != nil {
+			return t, err
+		}
+	}
+
+	return t, nil
+}
+
+// The last day of a quarter (Q4-2026) or month (2026-12)
+func targetDeadline(v string) (time.Time, error) {
+	if t, err := time.Parse(monthKey, v); err == nil {
+		return monthEnd(t), nil
+	}
+	q, y, _ := strings.Cut(strings.ToUpper(v), "-")
+	year, yErr := strconv.Atoi(y)
+	n, qErr := strconv.Atoi(strings.TrimPrefix(q, "Q"))
+	if _, ok := quarterEnd[n]; yErr != nil || qErr != nil || !ok {
+		return time.Time{}, fmt.Errorf("target deadlines look like Q4-2026 or 2026-12 not %v", v)
+	}
+
+	return quarterEndDay(n, year), nil
+}
+
+// Whether v meets the target
+func (t target) meets(v float64) bool {
+	return compare(v, t.op, t.value)
+}
+
+// Ways targets and rules compare a metric with a value
+var compareOps = []string{"<", "<=", ">", ">="}
+
+func compare(v float64, op string, value float64) bool {
+	switch op {
+	case "<":
+		return v < value
+	case "<=":
+		return v <= value
+	case ">":
+		return v > value
+	}
+
+	return v >= value
+}
+
+// A metric for team, or all of ThreadFix if it's empty, in month m. false if
+// it can't be worked out.
+func targetValue(metric string, team string, m *tfMonth) (float64, bool) {
+	switch metric {
+	case "crit_pct", "high_pct":
+		sev := 5
+		if metric == "high_pct" {
+			sev = 4
+		}
+		if team == "" {
+			if sev == 5 {
+				return m.percntCrit, true
+			}
+			return m.percntHigh, true
+		}
+		n := teamCounts[team]
+		if n == 0 {
+			return 0, false
+		}
+		apps := make(map[string]bool)
+		for k, _ := range m.search.Results {
+			r := m.search.Results[k]
+			if r.Team.Name == team && r.Severity.Value == sev {
+				apps[r.Apps.Name] = true
+			}
+		}
+		return float64(len(apps)) / float64(n) * 100, true
+	case "coverage", "mttr":
+		if scanHistory == nil {
+			return 0, false
+		}
+		// As of the end of the month, or now for the month under way
+		asOf := monthEnd(m.tStamp).AddDate(0, 0, 1)
+		if n := time.Now(); asOf.After(n) {
+			asOf = n
+		}
+		if metric == "mttr" {
+			return estimateMTTR(team, asOf)
+		}
+		apps, covered := 0, 0
+		for _, c := range appCoverages(asOf) {
+			if team != "" && c.team != team {
+				continue
+			}
+			apps++
+			if c.covered(asOf) {
+				covered++
+			}
+		}
+		if apps == 0 {
+			return 0, false
+		}
+		return float64(covered) / float64(apps) * 100, true
+	}
+
+	return 0, false
+}
+
+// Mean days to remediate for team, or all of ThreadFix if it's empty, as of
+// asOf. ThreadFix doesn't say when findings were closed so it's estimated by
+// Little's law - the findings open in the latest scan of each app by each
+// scanner over the findings scans closed a day in the mttrDays before. false
+// if none were closed.
+func estimateMTTR(team string, asOf time.Time) (float64, bool) {
+	from := asOf.AddDate(0, 0, -mttrDays)
+	latest := make(map[string]tfScan)
+	closed := 0
+	for _, s := range scanHistory {
+		if (team != "" && s.team != team) || s.date.After(asOf) {
+			continue
+		}
+		k := s.app + "\x00" + s.scanner
+		if l, ok := latest[k]; !ok || s.date.After(l.date) {
+			latest[k] = s
+		}
+		if s.date.After(from) {
+			closed += s.closed
+		}
+	}
+	if closed == 0 {
+		return 0, false
+	}
+	open := 0
+	for _, s := range latest {
+		open += s.vulns
+	}
+
+	return float64(open) / (float64(closed) / mttrDays), true
+}
+
+// Where the targets for team stand in ms, newest first. Every target is
+// checked if team is empty, otherwise the ones for all of ThreadFix and team.
+func targetStatuses(ms []*tfMonth, team string) []targetStatus {
+	var out []targetStatus
+	for _, t := range targets {
+		if team != "" && t.team != "" && t.team != team {
+			continue
+		}
+		out = append(out, checkTarget(t, ms))
+	}
+
+	return out
+}
+
+func checkTarget(t target, ms []*tfMonth) targetStatus {
+	ts := targetStatus{target: t}
+	var ys []float64
+	for i := len(ms) - 1; i >= 0; i-- {
+		v, ok := targetValue(t.metric, t.team, ms[i])
+		if !ok {
+			ts.why = fmt.Sprintf("no LoB/Team named %v", t.team)
+			switch {
+			case (t.metric == "coverage" || t.metric == "mttr") && scanHistory == nil:
+				ts.why = "needs scan history"
+			case t.metric == "mttr" && (t.team == "" || teamCounts[t.team] > 0):
+				ts.why = fmt.Sprintf("no findings closed in the %v days to %v", mttrDays, ms[i].tStamp.Format(monthKey))
+			}
+			return ts
+		}
+		ys = append(ys, v)
+	}
+	if len(ys) == 0 {
+		ts.why = "no months to check"
+		return ts
+	}
+	ts.known = true
+	ts.current = ys[len(ys)-1]
+	ts.slope = trendSlope(ys)
+	ts.met = t.meets(ts.current)
+	if ts.met {
+		ts.status = "Met"
+		return ts
+	}
+
+	ts.gap = math.Abs(t.value - ts.current)
+	asOf := ms[0].tStamp
+	need := t.value - ts.current
+	improving := ts.slope != 0 && (need > 0) == (ts.slope > 0)
+	if improving {
+		n := int(math.Ceil(need / ts.slope))
+		if n < 1 {
+			n = 1
+		}
+		ts.projected = monthEnd(time.Date(asOf.Year(), asOf.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC))
+	}
+	switch {
+	case !t.by.IsZero() && asOf.After(t.by):
+		ts.status = "Missed"
+	case !improving:
+		ts.status = "Not improving"
+	case !t.by.IsZero() && ts.projected.After(t.by):
+		ts.status = "Behind"
+	default:
+		ts.status = "On track"
+	}
+
+	return ts
+}
+
+// Least squares slope of ys over their index, 0 for fewer than 2
+func trendSlope(ys []float64) float64 {
+	slope, _ := linearFit(ys)
+
+	return slope
+}
+
+// The targetMonths months ending with end, newest first, none if there are no
+// targets
+func fetchTargetHistory(end time.Time) ([]*tfMonth, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	return fetchHistory(end, targetMonths)
+}
+
+///////////////////////////////////////
+// Setters                           //
+///////////////////////////////////////
+
+func setTargets(v []string) error {
+	var ts []target
+	for _, spec := range v {
+		t, err := parseTarget(spec)
+		if err != nil {
+			return err
+		}
+		ts = append(ts, t)
+	}
+	targets = ts
+
+	return nil
+}
+
+func setTargetMonths(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 2 {
+		return fmt.Errorf("must be 2 or more months not %v", v)
+	}
+	targetMonths = n
+
+	return nil
+}
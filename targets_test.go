@@ -0,0 +1,91 @@
+// targets_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		spec   string
+		team   string
+		metric string
+		op     string
+		value  float64
+		by     string
+		bad    bool
+	}{
+		{"crit_pct < 5 by Q4-2026", "", "crit_pct", "<", 5, "2026-12-31", false},
+		{"Payments: coverage >= 90% by 2027-03", "Payments", "coverage", ">=", 90, "2027-03-31", false},
+		{"mttr <= 45", "", "mttr", "<=", 45, "", false},
+		{"Pay: mttr < 120 by Q1-2027", "Pay", "mttr", "<", 120, "2027-03-31", false},
+		{"high_pct < 120", "", "", "", 0, "", true},
+		{"mttr < -1", "", "", "", 0, "", true},
+		{"crit_pct = 5", "", "", "", 0, "", true},
+		{"apps < 5", "", "", "", 0, "", true},
+		{"crit_pct < 5 by next year", "", "", "", 0, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseTarget(tt.spec)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%v: no error", tt.spec)
+			}
+			continue
+		}
+		by := ""
+		if !got.by.IsZero() {
+			by = got.by.Format(dayKey)
+		}
+		if err != nil || got.team != tt.team || got.metric != tt.metric || got.op != tt.op || got.value != tt.value || by != tt.by {
+			t.Errorf("%v: got %+v %v", tt.spec, got, err)
+		}
+	}
+}
+
+func TestEstimateMTTR(t *testing.T) {
+	defer func() { scanHistory = nil }()
+	asOf := mustDay(t, "2026-10-01")
+	scan := func(app string, team string, scanner string, date string, vulns int, closed int) tfScan {
+		return tfScan{app: app, team: team, scanner: scanner, date: mustDay(t, date), vulns: vulns, closed: closed}
+	}
+	scanHistory = []tfScan{
+		scan("pay-web", "Pay", "ZAP", "2026-08-01", 40, 9),
+		scan("pay-web", "Pay", "ZAP", "2026-09-01", 30, 9),
+		scan("pay-web", "Pay", "Fortify", "2026-09-15", 15, 0),
+		// Before the 90 days, only counts towards what's open if it's the latest
+		scan("shop", "Retail & Co", "Burp", "2026-05-01", 20, 50),
+		// After asOf
+		scan("pay-api", "Pay", "ZAP", "2026-10-05", 100, 100),
+	}
+
+	tests := []struct {
+		team string
+		days float64
+		ok   bool
+	}{
+		// 45 open in the latest scans, 18 closed in 90 days is 0.2 a day
+		{"Pay", 225, true},
+		// 65 open, still 18 closed
+		{"", 65 / (18.0 / 90), true},
+		{"Retail & Co", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := estimateMTTR(tt.team, asOf)
+		if ok != tt.ok || math.Abs(got-tt.days) > 1e-9 {
+			t.Errorf("%q: %v %v, want %v %v", tt.team, got, ok, tt.days, tt.ok)
+		}
+	}
+
+	// Falling MTTR towards a target
+	tg, _ := parseTarget("Pay: mttr < 100")
+	var ms []*tfMonth
+	for _, d := range []string{"2026-09-30", "2026-08-31", "2026-07-31"} {
+		ms = append(ms, &tfMonth{tStamp: mustDay(t, d)})
+	}
+	ts := checkTarget(tg, ms)
+	if !ts.known || ts.met || ts.current != 225 {
+		t.Errorf("Pay mttr status %+v", ts)
+	}
+}
@@ -0,0 +1,157 @@
+// teams.go
+// per LoB/Team reports so each team lead only sees their own numbers
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Copy of the search results with only the findings for the team sent
+func teamSearch(srch *tf.SrchResp, team string) *tf.SrchResp {
+	s := *srch
+	s.Results = nil
+	for k, _ := range srch.Results {
+		if srch.Results[k].Team.Name == team {
+			s.Results = append(s.Results, srch.Results[k])
+		}
+	}
+
+	return &s
+}
+
+// Recalculate a month for a single team using the findings already pulled for
+// that month. Percentages are against the number of apps in the team.
+func teamMonth(m *tfMonth, team string) *tfMonth {
+	t := tfMonth{
+		tStamp:   m.tStamp,
+		mpartial: m.mpartial,
+		quarter:  m.quarter,
+		qpartial: m.qpartial,
+	}
+	calcMonth(&t, teamSearch(m.search, team), teamCounts[team])
+
+	return &t
+}
+
+// Build the report for a single team from the month and the 2 before it that
+// have already been summed, and the target history and its error from
+// fetchTargetHistory
+func teamReport(team string, ms []*tfMonth, tms []*tfMonth, terr error) *report {
+	m0, m1, m2 := teamMonth(ms[0], team), teamMonth(ms[1], team), teamMonth(ms[2], team)
+	qm := quarterMonths(m0.tStamp, []*tfMonth{m0, m1, m2})
+	var tq tfQuarter
+	rollQuarter(&tq, teamCounts[team], qm[0], qm[1], qm[2])
+
+	r := newTeamReport(m0, team)
+	r.addTargets(tms, terr)
+	r.title = fmt.Sprintf("ThreadFix Metrics for %v - %v", team, r.month)
+	r.add(teamSummarySection(team),
+		monthSection("Month Metrics", m0),
+		monthSection("Month - 1 Metrics", m1),
+		monthSection("Month - 2 Metrics", m2),
+		quarterSection(&tq),
+		lobCSVSection(m0, m1, m2),
+	)
+
+	return r
+}
+
+func teamSummarySection(team string) section {
+	s := section{title: "Summary Metrics"}
+	s.line("LoB/Team %v includes %v of the %v apps in ThreadFix", team, teamCounts[team], appCount)
+	s.line("%v has %v critical findings", team, critsByLob[team])
+
+	return s
+}
+
+// All teams in name order
+func teamNames() []string {
+	names := make([]string, 0, len(teamCounts))
+	for k, _ := range teamCounts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Write a report per team to dir
+func writeTeamReports(dir string, ms []*tfMonth) error {
+	tms, terr := fetchTargetHistory(ms[0].tStamp)
+	files := teamFileNames(teamNames())
+	for _, team := range teamNames() {
+		err := saveReport(dir, files[team], teamReport(team, ms, tms, terr))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Email each LoB's recipients their own team's report
+func mailTeamReports(c *mailConfig, ms []*tfMonth) error {
+	tms, terr := fetchTargetHistory(ms[0].tStamp)
+	for _, lob := range c.lobs() {
+		if _, ok := teamCounts[lob]; !ok {
+			fmt.Fprintf(os.Stderr, "Warning: No LoB/Team named %v in ThreadFix, not emailing %v\n",
+				lob, strings.Join(c.lobTo[lob], ", "))
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Emailing %v report to %v\n", lob, strings.Join(c.lobTo[lob], ", "))
+		err := mailReport(c, c.lobTo[lob], teamReport(lob, ms, tms, terr))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Turn a team name into something safe to use as a file name
+func fileName(n string) string {
+	f := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, n)
+
+	return strings.Trim(f, "-")
+}
+
+// The file name of each team's reports. Names that come out the same from
+// fileName, like "Retail & Co" and "Retail / Co", or empty have the team's
+// ThreadFix ID added so one team's report doesn't overwrite another's.
+func teamFileNames(names []string) map[string]string {
+	count := make(map[string]int)
+	for _, n := range names {
+		count[fileName(n)]++
+	}
+	files := make(map[string]string)
+	for _, n := range names {
+		f := fileName(n)
+		if count[f] > 1 || f == "" {
+			f = strings.TrimPrefix(f+"-"+strconv.Itoa(teamIds[n]), "-")
+		}
+		files[n] = f
+	}
+
+	return files
+}
+
+// The file name of a team's reports among all of the teams
+func teamFileName(team string) string {
+	if f, ok := teamFileNames(teamNames())[team]; ok {
+		return f
+	}
+
+	return fileName(team)
+}
@@ -0,0 +1,98 @@
+// teams_test.go
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		team string
+		want string
+	}{
+		{"Pay", "Pay"},
+		{"Retail & Co", "Retail---Co"},
+		{"Retail / Co", "Retail---Co"},
+		{"  Card_Services-EU ", "Card_Services-EU"},
+		{"Zahlungsverkehr Süd", "Zahlungsverkehr-Süd"},
+		{"&&", ""},
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.team); got != tt.want {
+			t.Errorf("%q: got %q want %q", tt.team, got, tt.want)
+		}
+	}
+}
+
+func TestTeamFileNames(t *testing.T) {
+	defer func(ids map[string]int) { teamIds = ids }(teamIds)
+	teamIds = map[string]int{"Pay": 10, "Retail & Co": 20, "Retail / Co": 30, "&&": 40}
+	tests := []struct {
+		teams []string
+		want  map[string]string
+	}{
+		{[]string{"Pay", "Retail & Co"}, map[string]string{"Pay": "Pay", "Retail & Co": "Retail---Co"}},
+		// Names that clean up the same get the team's ID
+		{[]string{"Pay", "Retail & Co", "Retail / Co"},
+			map[string]string{"Pay": "Pay", "Retail & Co": "Retail---Co-20", "Retail / Co": "Retail---Co-30"}},
+		{[]string{"&&"}, map[string]string{"&&": "40"}},
+	}
+	for _, tt := range tests {
+		if got := teamFileNames(tt.teams); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v want %v", tt.teams, got, tt.want)
+		}
+	}
+}
+
+func TestTeamMonth(t *testing.T) {
+	defer func(c map[string]int) { teamCounts = c }(teamCounts)
+	teamCounts = map[string]int{"Pay": 3, "Retail & Co": 2, "Cards": 4}
+	m := fakeMonths(mustDay(t, "2026-09-30"), 1)[0]
+	tests := []struct {
+		team     string
+		findings int
+		crit     float64
+		high     float64
+	}{
+		// 1 of Pay's 3 apps has criticals and 1 has highs
+		{"Pay", 3, 100.0 / 3, 100.0 / 3},
+		{"Retail & Co", 3, 50, 0},
+		{"Cards", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		tm := teamMonth(m, tt.team)
+		if tm.totVulns != tt.findings || !near(tm.percntCrit, tt.crit) || !near(tm.percntHigh, tt.high) {
+			t.Errorf("%v: got %v findings, %v%% crit, %v%% high want %v, %v%%, %v%%",
+				tt.team, tm.totVulns, tm.percntCrit, tm.percntHigh, tt.findings, tt.crit, tt.high)
+		}
+		if !tm.tStamp.Equal(m.tStamp) || tm.quarter != m.quarter {
+			t.Errorf("%v: got %v %v want %v %v", tt.team, tm.tStamp, tm.quarter, m.tStamp, m.quarter)
+		}
+	}
+}
+
+func TestWriteTeamReports(t *testing.T) {
+	defer func(c map[string]int, ids map[string]int) { teamCounts, teamIds = c, ids }(teamCounts, teamIds)
+	teamCounts = map[string]int{"Pay": 3, "Retail & Co": 2, "Retail / Co": 1}
+	teamIds = map[string]int{"Pay": 10, "Retail & Co": 20, "Retail / Co": 30}
+	dir := t.TempDir()
+	if err := writeTeamReports(dir, fakeMonths(mustDay(t, "2026-09-30"), 3)); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.Base(f))
+	}
+	sort.Strings(got)
+	want := []string{"Pay.json", "Retail---Co-20.json", "Retail---Co-30.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrote %v want %v", got, want)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	tf "github.com/mtesauro/tfclient"
@@ -14,11 +17,22 @@ import (
 
 // Metrics functions
 func createSummary(teams *tf.TeamResp) {
+	// Start from zero so the summary can be rebuilt by long running modes
+	appCount = 0
+	teamCounts = make(map[string]int)
+	teamIds = make(map[string]int)
+	critsByLob = make(map[string]int)
+	appLobs = make(map[string]string)
+
 	// Create summary data across all teams/apps
 	for _, v := range teams.Tm {
 		// Count the number of apps per team plus overall count of apps
 		teamCounts[v.Name] = len(v.Apps)
+		teamIds[v.Name] = v.Id
 		appCount += len(v.Apps)
+		for _, a := range v.Apps {
+			appLobs[a.Name] = v.Name
+		}
 
 		// For apps with criticals, pull out them plus the count
 		if v.NumCrit > 0 {
@@ -30,28 +44,47 @@ func createSummary(teams *tf.TeamResp) {
 }
 
 func getTeams(tfc *http.Client, t *tf.TeamResp) {
-	// Call Get Team API method
-	tResp, err := tf.GetTeams(tfc)
+	err := fetchTeams(tfc, t)
 	if err != nil {
 		fmt.Print(err)
 		os.Exit(1)
 	}
 
+	return
+}
+
+// Same as getTeams but returns errors for long running callers like serve
+func fetchTeams(tfc *http.Client, t *tf.TeamResp) error {
+	// Call Get Team API method
+	tResp, err := tf.GetTeams(tfc)
+	if err != nil {
+		return err
+	}
+
 	// Setup Team struct to hold the data we received
 	err = tf.MakeTeamStruct(t, tResp)
 	if err != nil {
-		fmt.Print(err)
-		os.Exit(1)
+		return err
 	}
+	// Leave out any teams or apps filtered out
+	findingFilter.filterTeams(t)
 
-	return
+	return nil
 }
 
+// Fill in the month for m.tStamp, searching for it only if it hasn't been
+// searched for already
 func sumMonth(m *tfMonth) {
+	*m = *sumMonths(m.tStamp, 1)[0]
+
+	return
+}
+
+// Same as sumMonth but returns errors for long running callers like serve
+func fetchMonth(m *tfMonth) error {
 	// Check that time stamp is set before summing the month as its required
 	if m.tStamp.Year() == 1 {
-		fmt.Println("Error:  You must set the timestamp - tfMonth.tStamp - before calling sumMonth")
-		os.Exit(1)
+		return errors.New("Error:  You must set the timestamp - tfMonth.tStamp - before calling sumMonth\n")
 	}
 
 	m.quarter = getQuarter(m.tStamp.Month(), m.tStamp.Year())
@@ -71,36 +104,56 @@ func sumMonth(m *tfMonth) {
 
 	// Create a search struct to hold 1 month worth of data to mine and populate
 	var search tf.SrchResp
-	monthSearch(m.tStamp, &search)
+	err := monthSearch(m.tStamp, &search)
+	if err != nil {
+		return err
+	}
+
+	calcMonth(m, &search, appCount)
+	countScans(m, time.Date(m.tStamp.Year(), m.tStamp.Month(), 1, 0, 0, 0, 0, time.UTC), monthEnd(m.tStamp))
+
+	return nil
+}
+
+// Fill in a month's metrics from the search results sent. apps is the number
+// of apps the crit and high percentages are calculated against
+func calcMonth(m *tfMonth, search *tf.SrchResp, apps int) {
+	m.search = search
 
 	// Find Total vuns per month, vuln counts by LoB/Team, assessments by LoB/Team
 	// and Total assessments for the month
 	m.totVulns = len(search.Results)
-	m.vulnByLob, m.assessByLob = lobCounts(&search)
+	m.vulnByLob, m.assessByLob = lobCounts(search)
 	m.totAssess = totalMap(m.assessByLob)
 
 	// Find the apps with criticals aka int 5
-	m.critApps = appsWithVulns(5, &search)
+	m.critApps = appsWithVulns(5, search)
 
 	// Find the apps with highs aka int 4
-	m.highApps = appsWithVulns(4, &search)
+	m.highApps = appsWithVulns(4, search)
 
 	// Calculate precent crit and high
-	m.percntCrit = (float64(len(m.critApps)) / float64(appCount)) * 100
-	m.percntHigh = (float64(len(m.highApps)) / float64(appCount)) * 100
+	m.percntCrit = (float64(len(m.critApps)) / float64(apps)) * 100
+	m.percntHigh = (float64(len(m.highApps)) / float64(apps)) * 100
 
 	// Best and Worst apps and counts
-	m.bestApps, m.worstApps = rateApps(&search)
-	m.bAppsCnt = appVulnCounts(&search, m.bestApps)
-	m.wAppsCnt = appVulnCounts(&search, m.worstApps)
+	m.bestApps, m.worstApps = rateApps(search)
+	m.bAppsCnt = appVulnCounts(search, m.bestApps)
+	m.wAppsCnt = appVulnCounts(search, m.worstApps)
 
 	// Tool Usage
-	m.toolUsage = toolUsage(&search)
+	m.toolUsage = toolUsage(search)
 
-	// Top 10 CWE's
-	m.topCWE = cweCounts(&search)
+	// Top 10 CWE's, overall, per severity and weighted by severity
+	m.topCWE = cweCounts(search)
+	m.cweBySev = make(map[int]map[string]int)
+	for sev := 5; sev >= 1; sev-- {
+		m.cweBySev[sev] = cweCounts(search, sev)
+	}
+	m.cweScore = cweScores(search)
 
-	return
+	// Findings grouped into OWASP Top 10, CWE Top 25 and custom categories
+	m.cweCats, m.cweCatsByLob = categoryCounts(search)
 }
 
 func totalMap(a map[string]int) int {
@@ -232,20 +285,46 @@ func sumVulns(a map[string]VulnCount, name string, sev int) {
 	}
 }
 
-func cweCounts(srch *tf.SrchResp) map[string]int {
-	// TODO - add an option to only pull CWEs of 1+ severity level(s)
+// Count of findings per CWE, only for the severities sent if there are any
+func cweCounts(srch *tf.SrchResp, sevs ...int) map[string]int {
 	cwes := make(map[string]int)
 
-	// Cycle through the results struct, pulling out the tools which found vulns
+	// Cycle through the results struct, pulling out the CWE of each vuln
 	for k, _ := range srch.Results {
-		c := "CWE-" + strconv.Itoa(srch.Results[k].CweVuln.Id) +
-			": " + srch.Results[k].CweVuln.Name
-		sumApps(cwes, c, 1)
+		if len(sevs) > 0 && !hasSev(sevs, srch.Results[k].Severity.Value) {
+			continue
+		}
+		sumApps(cwes, cweName(srch.Results[k]), 1)
 	}
 
 	return cwes
 }
 
+// Findings per CWE weighted by severity the same way as app scores
+func cweScores(srch *tf.SrchResp) map[string]int {
+	cwes := make(map[string]int)
+
+	for k, _ := range srch.Results {
+		sumApps(cwes, cweName(srch.Results[k]), vulnWeight[srch.Results[k].Severity.Value])
+	}
+
+	return cwes
+}
+
+func cweName(r tf.Result) string {
+	return "CWE-" + strconv.Itoa(r.CweVuln.Id) + ": " + r.CweVuln.Name
+}
+
+func hasSev(sevs []int, sev int) bool {
+	for _, s := range sevs {
+		if s == sev {
+			return true
+		}
+	}
+
+	return false
+}
+
 func toolUsage(srch *tf.SrchResp) map[string]int {
 	tools := make(map[string]int)
 
@@ -261,28 +340,10 @@ func toolUsage(srch *tf.SrchResp) map[string]int {
 }
 
 func rateApps(srch *tf.SrchResp) (map[string]int, map[string]int) {
-	apps := make(map[string]int)
-
-	// Cycle through the results struct, pulling out the severity level sent in
-	for k, _ := range srch.Results {
-		switch srch.Results[k].Severity.Value {
-		case 5:
-			// Critical
-			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[5])
-		case 4:
-			// High
-			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[4])
-		case 3:
-			// Medium
-			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[3])
-		case 2:
-			// Low
-			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[2])
-		}
-	}
+	apps := appScores(srch)
 
 	// Sort apps and pull off top 10 and bottom 10
-	sApps := sortCounts(apps, true)
+	sApps := rankCounts(apps, true)
 
 	var l, bestEnd, worseStart int
 	l = len(sApps)
@@ -300,22 +361,43 @@ func rateApps(srch *tf.SrchResp) (map[string]int, map[string]int) {
 	}
 
 	best := make(map[string]int)
-	for b := 0; b < bestEnd; b++ {
-		for k, v := range sApps[b] {
-			best[k] = v
-		}
+	for _, r := range sApps[:bestEnd] {
+		best[r.name] = r.count
 	}
 
 	worse := make(map[string]int)
-	for w := worseStart; w < len(sApps); w++ {
-		for k, v := range sApps[w] {
-			worse[k] = v
-		}
+	for _, r := range sApps[worseStart:] {
+		worse[r.name] = r.count
 	}
 
 	return best, worse
 }
 
+// Weighted vuln score of every app in the search results
+func appScores(srch *tf.SrchResp) map[string]int {
+	apps := make(map[string]int)
+
+	// Cycle through the results struct, pulling out the severity level sent in
+	for k, _ := range srch.Results {
+		switch srch.Results[k].Severity.Value {
+		case 5:
+			// Critical
+			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[5])
+		case 4:
+			// High
+			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[4])
+		case 3:
+			// Medium
+			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[3])
+		case 2:
+			// Low
+			sumApps(apps, srch.Results[k].Apps.Name, vulnWeight[2])
+		}
+	}
+
+	return apps
+}
+
 func sumApps(a map[string]int, name string, val int) {
 	// Takes a map and add val (value) to the int counter of map[string]int
 	// Sums up values under a label - usually an app name
@@ -326,90 +408,92 @@ func sumApps(a map[string]int, name string, val int) {
 	}
 }
 
-// Custom function to return a sorted map[int]map[string]int descending by default
-// if reverse is set to true, it will be ascending. iterate over the initial int in
-// order e.g. for j := 0; j < len(sortedMap); j++ {} and use
-// for k, v := range sortedMap[j] {} to acess the values in desc/asc order
-func sortCounts(m map[string]int, ascending bool) map[int]map[string]int {
-	sorted := make(map[int]map[string]int)
+// A name and its count e.g. an app and its score
+type rank struct {
+	name  string
+	count int
+}
 
-	// Fill up the sorted map
-	count := 0
+// Sort the counts in a map, descending by default or ascending if asked to.
+// Ties are put in name order so the same counts always come out the same way.
+func rankCounts(m map[string]int, ascending bool) []rank {
+	ranked := make([]rank, 0, len(m))
 	for k, v := range m {
-		sorted[count] = map[string]int{k: v}
-		count++
+		ranked = append(ranked, rank{k, v})
 	}
-
-	// Bubble sort the counts in newly populated sorted map
-	for cnt := len(sorted) - 1; ; cnt-- {
-		changed := false
-		for index := 0; index < cnt; index++ {
-			if mapCompare(sorted[index], sorted[index+1], ascending) {
-				sorted[index], sorted[index+1] = sorted[index+1], sorted[index]
-				changed = true
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].count != ranked[j].count {
+			if ascending {
+				return ranked[i].count < ranked[j].count
 			}
+			return ranked[i].count > ranked[j].count
 		}
-		if changed == false {
-			break
-		}
-	}
+		return ranked[i].name < ranked[j].name
+	})
 
-	return sorted
+	return ranked
 }
 
-func mapCompare(a map[string]int, b map[string]int, ascending bool) bool {
-	for _, v1 := range a {
-		for _, v2 := range b {
-			if ascending {
-				if v1 > v2 {
-					return true
-				} else {
-					return false
-				}
-			} else {
-				// Descending
-				if v1 < v2 {
-					return true
-				} else {
-					return false
-				}
-			}
-		}
+func monthSearch(t time.Time, srch *tf.SrchResp) error {
+	// Restrict default search to the month sent
+	st := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	e := time.Date(t.Year(), t.Month(), lastDate(int(t.Month()), t.Year()), 0, 0, 0, 0, time.UTC)
+
+	return rangeSearch(st, e, srch)
+}
+
+// Search for the open findings between the start and end days, both included,
+// warning if ThreadFix left some out
+func rangeSearch(st time.Time, e time.Time, srch *tf.SrchResp) error {
+	capped, err := cappedSearch(st, e, "open", srch)
+	if capped {
+		fmt.Fprintf(os.Stderr, "Warning: Findings from %v to %v reached threadfix.max_results (%v), some may be missing\n",
+			st.Format(dayKey), e.Format(dayKey), maxResults)
 	}
 
-	return true
+	return err
 }
 
-func monthSearch(t time.Time, srch *tf.SrchResp) {
+// Search for the findings between the start and end days that show picks out
+// e.g. open or falsePositive, and say if ThreadFix sent back maxResults
+// findings, in which case there may be more that were left out
+func cappedSearch(st time.Time, e time.Time, show string, srch *tf.SrchResp) (bool, error) {
 	// Create a struct to hold our search parameters
 	s := tf.CreateSearchStruct()
 
-	// Restrict default search to the month sent
-	st := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
 	start := st.Format("01/02/2006")
-	e := time.Date(t.Year(), t.Month(), lastDate(int(t.Month()), t.Year()), 0, 0, 0, 0, time.UTC)
 	end := e.Format("01/02/2006")
 	tf.StartSearch(&s, start)
 	tf.EndSearch(&s, end)
-	// And only ask for all but infos - 5, 4, 3, 2
-	tf.SeveritySearch(&s, 5, 4, 3, 2)
+	// And only ask for the severities wanted, all but infos unless configured
+	tf.SeveritySearch(&s, findingFilter.severities(searchSeverities)...)
+	// Only the teams, apps, scanners and CWEs included
+	sc, ok := findingFilter.scope()
+	if !ok {
+		*srch = tf.SrchResp{}
+		return false, nil
+	}
+	sc.narrow(&s)
 	// Increase number of results up from the default of 10
-	tf.NumSearchResults(&s, 1500)
-	// Only open vulns
-	tf.ShowInSearch(&s, "open")
+	tf.NumSearchResults(&s, maxResults)
+	// Only open vulns unless asked for others
+	tf.ShowInSearch(&s, show)
 	// Send the search query to TF
 	vulns, err := tf.VulnSearch(tfc, &s)
 	if err != nil {
-		fmt.Print(err)
-		os.Exit(1)
+		return false, err
 	}
 
 	// Create a search struct and load it with the search with just conducted
 	err = tf.MakeSearchStruct(srch, vulns)
 	if err != nil {
-		fmt.Print(err)
-		os.Exit(1)
+		return false, err
 	}
+	capped := len(srch.Results) >= maxResults
+	// Drop findings for the teams, apps, scanners etc. excluded
+	findingFilter.filterSearch(srch)
+
+	return capped, nil
 }
 
 func appsWithVulns(sev int, srch *tf.SrchResp) map[string]int {
@@ -425,6 +509,17 @@ func appsWithVulns(sev int, srch *tf.SrchResp) map[string]int {
 	return apps
 }
 
+// Number of findings for every app in the search results
+func appFindings(srch *tf.SrchResp) map[string]int {
+	apps := make(map[string]int)
+
+	for k, _ := range srch.Results {
+		sumApps(apps, srch.Results[k].Apps.Name, 1)
+	}
+
+	return apps
+}
+
 func lastDate(month int, year int) int {
 	// Using a month and year, return the last day for that month
 	// Add a month to what is sent, subtract an hour,
@@ -458,38 +553,105 @@ func previousMonth(n time.Time) time.Time {
 	return time.Date(n.Year(), time.Month(n.Month()-1), d, 0, 0, 0, 0, time.UTC)
 }
 
+// The month to report on for the date sent
+func reportMonth(n time.Time) time.Time {
+	// If current day is less then monthCutoff, then back up a month for metrics
+	if n.Day() <= monthCutoff {
+		return previousMonth(n)
+	}
+
+	return n
+}
+
 func getQuarter(m time.Month, y int) string {
 
-	return qtrDefs[int(m)] + "-" + strconv.Itoa(y)
+	return qtrDefs[int(m)] + "-" + strconv.Itoa(fiscalYear(m, y))
+}
+
+// The fiscal year month m of year y is in, named for the year Q4 ends in
+func fiscalYear(m time.Month, y int) int {
+	if int(m) > quarterEnd[4] {
+		return y + 1
+	}
+
+	return y
+}
+
+// The number, 1 to 4, of the quarter month m is in
+func quarterNum(m time.Month) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(qtrDefs[int(m)], "Q"))
+
+	return n
+}
+
+// The last day of quarter n of fiscal year fy
+func quarterEndDay(n int, fy int) time.Time {
+	y := fy
+	if quarterEnd[n] > quarterEnd[4] {
+		y--
+	}
+
+	return monthEnd(time.Date(y, time.Month(quarterEnd[n]), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// Months of the quarter t is in that come after t's month
+func monthsLeft(t time.Time) int {
+	e := quarterLastMonth(t)
+
+	return (e.Year()-t.Year())*12 + int(e.Month()-t.Month())
+}
+
+// The three months of the quarter t is in, newest first, taken from ms which
+// are newest first from t. Months of the quarter after t haven't happened yet
+// and are left empty so the quarter only counts the months in it.
+func quarterMonths(t time.Time, ms []*tfMonth) [3]*tfMonth {
+	byMonth := make(map[string]*tfMonth)
+	for _, m := range ms {
+		byMonth[m.tStamp.Format(monthKey)] = m
+	}
+	left := monthsLeft(t)
+	e := quarterLastMonth(t)
+	var qm [3]*tfMonth
+	for i := 0; i < 3; i++ {
+		st := monthEnd(time.Date(e.Year(), e.Month()-time.Month(i), 1, 0, 0, 0, 0, time.UTC))
+		m, ok := byMonth[st.Format(monthKey)]
+		if i < left || !ok {
+			m = &tfMonth{tStamp: st, mpartial: true, qpartial: true, search: &tf.SrchResp{}}
+			m.quarter = getQuarter(st.Month(), st.Year())
+			if ms[0].scansByTool != nil {
+				m.scansByTool = make(map[string]int)
+			}
+		}
+		qm[i] = m
+	}
+
+	return qm
 }
 
-func sumQuarter(m0 *tfMonth, q *tfQuarter) {
+// Sum up the three months of a quarter, newest first. apps is the number of
+// apps the crit and high percentages are calculated against
+func rollQuarter(q *tfQuarter, apps int, m0 *tfMonth, m1 *tfMonth, m2 *tfMonth) {
+	// The quarter is named for the month it ends with
 	q.qLabel = m0.quarter
 	q.qTStamps = [3]time.Time{
 		m0.tStamp,
-		previousMonth(m0.tStamp),
-		previousMonth(previousMonth(m0.tStamp)),
+		m1.tStamp,
+		m2.tStamp,
 	}
-	// Gather data for the previous 2 months
-	var m1, m2 tfMonth
-	m1.tStamp = q.qTStamps[1]
-	m2.tStamp = q.qTStamps[2]
-	sumMonth(&m1)
-	sumMonth(&m2)
 
 	// tfMonth structs for the quarter
 	q.months = [3]*tfMonth{
 		m0,
-		&m1,
-		&m2,
+		m1,
+		m2,
 	}
 
 	// Total vulns, crit & high counts and percentages
 	q.totVulns = m0.totVulns + m1.totVulns + m2.totVulns
 	q.critApps = sumMaps(m0.critApps, m1.critApps, m2.critApps)
 	q.highApps = sumMaps(m0.highApps, m1.highApps, m2.highApps)
-	q.percntCrit = (float64(len(q.critApps)) / float64(appCount)) * 100
-	q.percntHigh = (float64(len(q.highApps)) / float64(appCount)) * 100
+	q.percntCrit = (float64(len(q.critApps)) / float64(apps)) * 100
+	q.percntHigh = (float64(len(q.highApps)) / float64(apps)) * 100
 
 	// Best and Worst apps
 	q.bestApps = sumMaps(m0.bestApps, m1.bestApps, m2.bestApps)
@@ -500,6 +662,30 @@ func sumQuarter(m0 *tfMonth, q *tfQuarter) {
 
 	// Top 10 CWE's
 	q.topCWE = sumMaps(m0.topCWE, m1.topCWE, m2.topCWE)
+	q.cweBySev = sumSevMaps(m0.cweBySev, m1.cweBySev, m2.cweBySev)
+	q.cweScore = sumMaps(m0.cweScore, m1.cweScore, m2.cweScore)
+	q.cweCats = sumCatMaps(m0.cweCats, m1.cweCats, m2.cweCats)
+	q.cweCatsByLob = sumCatLobMaps(m0.cweCatsByLob, m1.cweCatsByLob, m2.cweCatsByLob)
+
+	// Scans, if there's scan history
+	if m0.scansByTool != nil {
+		q.scansByTool = sumMaps(m0.scansByTool, m1.scansByTool, m2.scansByTool)
+		q.scansByLob = sumMaps(m0.scansByLob, m1.scansByLob, m2.scansByLob)
+		q.totScans = m0.totScans + m1.totScans + m2.totScans
+		q.cleanScans = m0.cleanScans + m1.cleanScans + m2.cleanScans
+	}
+}
+
+// Same as sumMaps for maps keyed by severity
+func sumSevMaps(s ...map[int]map[string]int) map[int]map[string]int {
+	tot := make(map[int]map[string]int)
+	for _, v := range s {
+		for sev, m := range v {
+			tot[sev] = sumMaps(tot[sev], m)
+		}
+	}
+
+	return tot
 }
 
 func sumMaps(s ...map[string]int) map[string]int {
@@ -516,407 +702,164 @@ func sumMaps(s ...map[string]int) map[string]int {
 	return tot
 }
 
-func sumYear(lastQtr *tfQuarter, y *tfYear) {
-	y.year = 2025
-}
-
-func main() {
-	// Create a client to talk to the API and set it as a global variable
-	t, err := tf.CreateClient()
+// Gather n months ending with the month sent, newest first. Each month is
+// searched for once, reusing those fetchHistory has already searched for, and
+// fetchWorkers months are searched for at a time.
+func sumMonths(end time.Time, n int) []*tfMonth {
+	ms, err := fetchHistory(end, n)
 	if err != nil {
 		fmt.Print(err)
 		os.Exit(1)
 	}
-	tfc = t
-
-	// Gather summary metrics
-	fmt.Println("Gathering summary metrics...")
-	var teams tf.TeamResp
-	getTeams(tfc, &teams)
-	createSummary(&teams)
 
-	// Gather trending metrics starting with current month, quarter & year
+	return ms
+}
 
-	fmt.Println("Gathering month metrics...")
-	// Fill in current month's stats
-	var m0 tfMonth
-	n := time.Now()
-	// If current day is less then monthCutoff, then back up a month for metrics
-	if n.Day() <= monthCutoff {
-		m0.tStamp = previousMonth(n)
-	} else {
-		m0.tStamp = n
-	}
-
-	// CHEATING AND FIXING THE MONTH TO BE MARCH 30, 2015
-	//m0.tStamp = time.Date(2015, time.Month(2), 28, 0, 0, 0, 0, time.UTC)
-	// CHEATING END
-
-	// Gather data for the month
-	sumMonth(&m0)
-
-	// Gather metrics for the previous quarter
-	// TODO - Do partial and last full or just last full or just the partial?
-	fmt.Println("Gethering quarter metrics...")
-	var q0 tfQuarter
-	sumQuarter(&m0, &q0)
-
-	// Print the metrics we've gathered so far to screen
-	// Eventually move this to a file so it can be emailed
-	fmt.Println("")
-	fmt.Println("==========[Summary Metrics]==========")
-	fmt.Printf("Total Apps in ThreadFix is %v\n", appCount)
-	fmt.Printf("Number of LoB/Teams in Threadfix is %v\n", len(teamCounts))
-	fmt.Println("Individual LoB/Team counts are:")
-	sTeamCts := sortCounts(teamCounts, false)
-	for j := 0; j < len(sTeamCts); j++ {
-		for k, v := range sTeamCts[j] {
-			fmt.Printf("  %v includes %v apps\n", k, v)
-		}
-	}
-	fmt.Println("")
-	fmt.Printf("Total LoB/Team with critical findings is %v\n", len(critsByLob))
-	// If there's apps with crits, print them and the average
-	if len(critsByLob) > 0 {
-		fmt.Println("LoB with critical findings are:")
-		sCritsLob := sortCounts(critsByLob, false)
-		for j := 0; j < len(sCritsLob); j++ {
-			for k, v := range sCritsLob[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		percntCrits := (float64(len(critsByLob)) / float64(appCount)) * 100
-		fmt.Printf("Percentage of LoB/Teams with critical findings is %.2f%%\n\n", percntCrits)
-	}
-
-	// Monthly stats
-	fmt.Println("")
-	fmt.Println("==========[Month Metrics]==========")
-	fmt.Printf("Metrics for %+v %+v", m0.tStamp.Month(), m0.tStamp.Year())
-	fmt.Printf(", which is part of %+v\n", m0.quarter)
-	fmt.Printf("Total vulnerabilities found for %+v was %+v\n", m0.tStamp.Month(), m0.totVulns)
-	// Criticals
-	if len(m0.critApps) > 0 {
-		fmt.Printf("Total apps with critical findings is %+v\n", len(m0.critApps))
-		fmt.Println("Individual App critical finding counts are:")
-		sMCrit := sortCounts(m0.critApps, false)
-		for j := 0; j < len(sMCrit); j++ {
-			for k, v := range sMCrit[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with critical findings is %.2f%%\n\n", m0.percntCrit)
-	}
-	// Highs
-	if len(m0.highApps) > 0 {
-		fmt.Printf("Total apps with highs is %+v\n", len(m0.highApps))
-		fmt.Println("Individual App high finding counts are:")
-		sMHigh := sortCounts(m0.highApps, false)
-		for j := 0; j < len(sMHigh); j++ {
-			for k, v := range sMHigh[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with high findings is %.2f%%\n\n", m0.percntHigh)
-	}
-	// Best apps
-	fmt.Println("The best apps of the month (and their score) are: (smaller is better)")
-	sBest := sortCounts(m0.bestApps, true)
-	for j := 0; j < len(sBest); j++ {
-		for k, v := range sBest[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-			fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m0.bAppsCnt[k].crit, m0.bAppsCnt[k].high, m0.bAppsCnt[k].med, m0.bAppsCnt[k].low)
-		}
-	}
-	// Worst apps
-	fmt.Println("The worst apps of the month (and their score) are: (smaller is better)")
-	sWorst := sortCounts(m0.worstApps, false)
-	for j := 0; j < len(sWorst); j++ {
-		for k, v := range sWorst[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-			fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m0.wAppsCnt[k].crit, m0.wAppsCnt[k].high, m0.wAppsCnt[k].med, m0.wAppsCnt[k].low)
-		}
-	}
-	// Tool usage
-	fmt.Printf("Number of assessments by type for %+v %+v\n", m0.tStamp.Month(), m0.tStamp.Year())
-	sTools := sortCounts(m0.toolUsage, false)
-	for j := 0; j < len(sTools); j++ {
-		for k, v := range sTools[j] {
-			fmt.Printf("  %v found %v results\n", k, v)
-		}
+// Same as sumMonths but returns errors for long running callers like serve
+func fetchMonths(end time.Time, n int) ([]*tfMonth, error) {
+	ms := make([]*tfMonth, n)
+	t := end
+	for i := 0; i < n; i++ {
+		ms[i] = &tfMonth{tStamp: t}
+		t = previousMonth(t)
 	}
-	// Top 10 CWEs
-	fmt.Printf("The Top 10 CWE Vulnerabilities for %+v %+v\n", m0.tStamp.Month(), m0.tStamp.Year())
-	sCwe := sortCounts(m0.topCWE, false)
-	max := 10
-	if len(sCwe) < max {
-		max = len(sCwe)
-	}
-	for j := 0; j < max; j++ {
-		for k, v := range sCwe[j] {
-			fmt.Printf("  %v occurrences of %v\n", v, k)
-		}
-	}
-	// LoB stats
-	fmt.Println("")
-	fmt.Printf("Total number of assessments this month: %+v\n", m0.totAssess)
-	fmt.Println("Assessments completed per LoB/Region")
-	for k, v := range m0.assessByLob {
-		fmt.Printf("  %+v had %+v assessments\n", k, v)
-		fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m0.vulnByLob[k].crit, m0.vulnByLob[k].high, m0.vulnByLob[k].med, m0.vulnByLob[k].low)
-	}
-
-	// ==========================[ Month - 1 ]=====================================
-
-	// Current Month - 1 month
-	var m1 tfMonth
-	m1.tStamp = previousMonth(m0.tStamp)
-	sumMonth(&m1)
-	fmt.Println("")
-	fmt.Println("==========[Month - 1 Metrics]==========")
-	fmt.Printf("Metrics for %+v %+v", m1.tStamp.Month(), m1.tStamp.Year())
-	fmt.Printf(", which is part of %+v\n", m1.quarter)
-	fmt.Printf("Total vulnerabilities found for %+v was %+v\n", m1.tStamp.Month(), m1.totVulns)
-	// Criticals
-	if len(m1.critApps) > 0 {
-		fmt.Printf("Total apps with critical findings is %+v\n", len(m1.critApps))
-		fmt.Println("Individual App critical finding counts are:")
-		sMCrit := sortCounts(m1.critApps, false)
-		for j := 0; j < len(sMCrit); j++ {
-			for k, v := range sMCrit[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with critical findings is %.2f%%\n\n", m1.percntCrit)
-	}
-	// Highs
-	if len(m1.highApps) > 0 {
-		fmt.Printf("Total apps with highs is %+v\n", len(m1.highApps))
-		fmt.Println("Individual App high finding counts are:")
-		sMHigh := sortCounts(m1.highApps, false)
-		for j := 0; j < len(sMHigh); j++ {
-			for k, v := range sMHigh[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with high findings is %.2f%%\n\n", m1.percntHigh)
-	}
-	// Best apps
-	fmt.Println("The best apps of the month (and their score) are: (smaller is better)")
-	sBest = sortCounts(m1.bestApps, true)
-	for j := 0; j < len(sBest); j++ {
-		for k, v := range sBest[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-			fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m1.bAppsCnt[k].crit, m1.bAppsCnt[k].high, m1.bAppsCnt[k].med, m1.bAppsCnt[k].low)
-		}
-	}
-	// Worst apps
-	fmt.Println("The worst apps of the month (and their score) are: (smaller is better)")
-	sWorst = sortCounts(m1.worstApps, false)
-	for j := 0; j < len(sWorst); j++ {
-		for k, v := range sWorst[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-			fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m1.wAppsCnt[k].crit, m1.wAppsCnt[k].high, m1.wAppsCnt[k].med, m1.wAppsCnt[k].low)
-		}
-	}
-	// Tool usage
-	fmt.Printf("Number of assessments by type for %+v %+v\n", m1.tStamp.Month(), m1.tStamp.Year())
-	sTools = sortCounts(m1.toolUsage, false)
-	for j := 0; j < len(sTools); j++ {
-		for k, v := range sTools[j] {
-			fmt.Printf("  %v found %v results\n", k, v)
-		}
-	}
-	// Top 10 CWEs
-	fmt.Printf("The Top 10 CWE Vulnerabilities for %+v %+v\n", m1.tStamp.Month(), m1.tStamp.Year())
-	sCwe = sortCounts(m1.topCWE, false)
-	max = 10
-	if len(sCwe) < max {
-		max = len(sCwe)
-	}
-	for j := 0; j < max; j++ {
-		for k, v := range sCwe[j] {
-			fmt.Printf("  %v occurrences of %v\n", v, k)
-		}
-	}
-	// LoB stats
-	fmt.Println("")
-	fmt.Printf("Total number of assessments this month: %+v\n", m1.totAssess)
-	fmt.Println("Assessments completed per LoB/Region")
-	for k, v := range m1.assessByLob {
-		fmt.Printf("  %+v had %+v assessments\n", k, v)
-		fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m1.vulnByLob[k].crit, m1.vulnByLob[k].high, m1.vulnByLob[k].med, m1.vulnByLob[k].low)
-	}
-
-	// ==========================[ Month - 2 ]=====================================
-
-	// Current Month - 2 months
-	var m2 tfMonth
-	m2.tStamp = previousMonth(m1.tStamp)
-	sumMonth(&m2)
-	fmt.Println("")
-	fmt.Println("==========[Month - 2 Metrics]==========")
-	fmt.Printf("Metrics for %+v %+v", m2.tStamp.Month(), m2.tStamp.Year())
-	fmt.Printf(", which is part of %+v\n", m2.quarter)
-	fmt.Printf("Total vulnerabilities found for %+v was %+v\n", m2.tStamp.Month(), m2.totVulns)
-	// Criticals
-	if len(m2.critApps) > 0 {
-		fmt.Printf("Total apps with critical findings is %+v\n", len(m2.critApps))
-		fmt.Println("Individual App critical finding counts are:")
-		sMCrit := sortCounts(m2.critApps, false)
-		for j := 0; j < len(sMCrit); j++ {
-			for k, v := range sMCrit[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with critical findings is %.2f%%\n\n", m2.percntCrit)
-	}
-	// Highs
-	if len(m2.highApps) > 0 {
-		fmt.Printf("Total apps with highs is %+v\n", len(m2.highApps))
-		fmt.Println("Individual App high finding counts are:")
-		sMHigh := sortCounts(m2.highApps, false)
-		for j := 0; j < len(sMHigh); j++ {
-			for k, v := range sMHigh[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with high findings is %.2f%%\n\n", m2.percntHigh)
-	}
-	// Best apps
-	fmt.Println("The best apps of the month (and their score) are: (smaller is better)")
-	sBest = sortCounts(m2.bestApps, true)
-	for j := 0; j < len(sBest); j++ {
-		for k, v := range sBest[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-			fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m2.bAppsCnt[k].crit, m2.bAppsCnt[k].high, m2.bAppsCnt[k].med, m2.bAppsCnt[k].low)
-		}
-	}
-	// Worst apps
-	fmt.Println("The worst apps of the month (and their score) are: (smaller is better)")
-	sWorst = sortCounts(m2.worstApps, false)
-	for j := 0; j < len(sWorst); j++ {
-		for k, v := range sWorst[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-			fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m2.wAppsCnt[k].crit, m2.wAppsCnt[k].high, m2.wAppsCnt[k].med, m2.wAppsCnt[k].low)
-		}
+
+	err := fetchAll(n, func(i int) error {
+		return fetchMonth(ms[i])
+	})
+	if err != nil {
+		return nil, err
 	}
-	// Tool usage
-	fmt.Printf("Number of assessments by type for %+v %+v\n", m2.tStamp.Month(), m2.tStamp.Year())
-	sTools = sortCounts(m2.toolUsage, false)
-	for j := 0; j < len(sTools); j++ {
-		for k, v := range sTools[j] {
-			fmt.Printf("  %v found %v results\n", k, v)
+
+	return ms, nil
+}
+
+// Months fetched by fetchHistory, by month, so the months a command and its
+// sections look back over are only searched for once
+var monthCache = make(map[string]*tfMonth)
+
+// The same as fetchMonths but reusing months fetched by it before
+func fetchHistory(end time.Time, n int) ([]*tfMonth, error) {
+	ms := make([]*tfMonth, n)
+	var todo []int
+	t := end
+	for i := 0; i < n; i++ {
+		if m, ok := monthCache[t.Format(monthKey)]; ok {
+			ms[i] = m
+		} else {
+			ms[i] = &tfMonth{tStamp: t}
+			todo = append(todo, i)
 		}
+		t = previousMonth(t)
 	}
-	// Top 10 CWEs
-	fmt.Printf("The Top 10 CWE Vulnerabilities for %+v %+v\n", m2.tStamp.Month(), m2.tStamp.Year())
-	sCwe = sortCounts(m2.topCWE, false)
-	max = 10
-	if len(sCwe) < max {
-		max = len(sCwe)
+
+	err := fetchAll(len(todo), func(i int) error {
+		return fetchMonth(ms[todo[i]])
+	})
+	if err != nil {
+		return nil, err
 	}
-	for j := 0; j < max; j++ {
-		for k, v := range sCwe[j] {
-			fmt.Printf("  %v occurrences of %v\n", v, k)
-		}
+	for _, i := range todo {
+		monthCache[ms[i].tStamp.Format(monthKey)] = ms[i]
 	}
-	// LoB stats
-	fmt.Println("")
-	fmt.Printf("Total number of assessments this month: %+v\n", m2.totAssess)
-	fmt.Println("Assessments completed per LoB/Region")
-	for k, v := range m2.assessByLob {
-		fmt.Printf("  %+v had %+v assessments\n", k, v)
-		fmt.Printf("    %v vuln count (crit/high/med/low): %v,%v,%v,%v\n", k, m2.vulnByLob[k].crit, m2.vulnByLob[k].high, m2.vulnByLob[k].med, m2.vulnByLob[k].low)
-	}
-
-	// ==========================[ Quarterly ]=====================================
-
-	// Quarterly stats
-	fmt.Println("")
-	fmt.Println("==========[Quarter Metrics]==========")
-	fmt.Printf("Metrics for %+v\n", q0.qLabel)
-	fmt.Printf("Total vulnerabilities found for %+v was %+v\n", q0.qLabel, q0.totVulns)
-	// Criticals
-	if len(q0.critApps) > 0 {
-		fmt.Printf("Total apps with critical findings is %+v\n", len(q0.critApps))
-		fmt.Println("Individual App critical finding counts are:")
-		sQCrit := sortCounts(q0.critApps, false)
-		for j := 0; j < len(sQCrit); j++ {
-			for k, v := range sQCrit[j] {
-				fmt.Printf("  %v has %v critical findings\n", k, v)
-			}
-		}
-		fmt.Printf("Percentage of Apps with critical findings is %.2f%%\n\n", q0.percntCrit)
-	}
-	// Highs
-	if len(q0.highApps) > 0 {
-		fmt.Printf("Total apps with highs is %+v\n", len(q0.highApps))
-		fmt.Println("Individual App high finding counts are:")
-		sQHigh := sortCounts(q0.highApps, false)
-		for j := 0; j < len(sQHigh); j++ {
-			for k, v := range sQHigh[j] {
-				fmt.Printf("  %v has %v high findings\n", k, v)
+
+	return ms, nil
+}
+
+// Call fetch for 0 to n-1, fetchWorkers at a time, and return the first error
+func fetchAll(n int, fetch func(i int) error) error {
+	todo := make(chan int)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for w := 0; w < fetchWorkers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range todo {
+				errs <- fetch(i)
 			}
-		}
-		fmt.Printf("Percentage of Apps with high findings is %.2f%%\n\n", q0.percntHigh)
-	}
-	// Best apps
-	fmt.Printf("The best apps of %+v (and their score) are: (smaller is better)\n", q0.qLabel)
-	sQBest := sortCounts(q0.bestApps, true)
-	for j := 0; j < len(sQBest); j++ {
-		for k, v := range sQBest[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-		}
+		}()
 	}
-	// Worst apps
-	fmt.Printf("The worst apps of %+v (and their score) are: (smaller is better)\n", q0.qLabel)
-	sQWorst := sortCounts(q0.worstApps, false)
-	for j := 0; j < len(sQWorst); j++ {
-		for k, v := range sQWorst[j] {
-			fmt.Printf("  %v has a score of %v \n", k, v)
-		}
+	for i := 0; i < n; i++ {
+		todo <- i
 	}
-	// Tool usage
-	fmt.Printf("Number of assessments by type for %+v\n", q0.qLabel)
-	sQTools := sortCounts(q0.toolUsage, false)
-	for j := 0; j < len(sQTools); j++ {
-		for k, v := range sQTools[j] {
-			fmt.Printf("  %v found %v results\n", k, v)
+	close(todo)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
 		}
 	}
-	// Top 10 CWEs
-	fmt.Printf("The Top 10 CWE Vulnerabilities for %+v\n", q0.qLabel)
-	sQCwe := sortCounts(q0.topCWE, false)
-	max = 10
-	if len(sQCwe) < max {
-		max = len(sQCwe)
+
+	return nil
+}
+
+// Sum up the quarter the first of ms is in, up to it, and the 3 quarters
+// before as a year. ms are newest first and 12 months. apps is the number of
+// apps the crit and high percentages are calculated against
+func rollYear(y *tfYear, apps int, ms []*tfMonth) {
+	t := ms[0].tStamp
+	for i := 0; i < 4; i++ {
+		var q tfQuarter
+		qm := quarterMonths(t, ms)
+		rollQuarter(&q, apps, qm[0], qm[1], qm[2])
+		y.quarters[i] = &q
+		y.qLabels[i] = q.qLabel
+		t = previousMonth(qm[2].tStamp)
 	}
-	for j := 0; j < max; j++ {
-		for k, v := range sQCwe[j] {
-			fmt.Printf("  %v occurrences of %v\n", v, k)
-		}
+	q0, q1, q2, q3 := y.quarters[0], y.quarters[1], y.quarters[2], y.quarters[3]
+
+	y.year = ms[0].tStamp.Year()
+	y.yearEnds = q0.qLabel
+
+	// Total vulns, crit & high counts and percentages
+	y.totVulns = q0.totVulns + q1.totVulns + q2.totVulns + q3.totVulns
+	y.critApps = sumMaps(q0.critApps, q1.critApps, q2.critApps, q3.critApps)
+	y.highApps = sumMaps(q0.highApps, q1.highApps, q2.highApps, q3.highApps)
+	y.percntCrit = (float64(len(y.critApps)) / float64(apps)) * 100
+	y.percntHigh = (float64(len(y.highApps)) / float64(apps)) * 100
+
+	// Best and Worst apps
+	y.bestApps = sumMaps(q0.bestApps, q1.bestApps, q2.bestApps, q3.bestApps)
+	y.worstApps = sumMaps(q0.worstApps, q1.worstApps, q2.worstApps, q3.worstApps)
+
+	// Tool Usage
+	y.toolUsage = sumMaps(q0.toolUsage, q1.toolUsage, q2.toolUsage, q3.toolUsage)
+
+	// Top 10 CWE's
+	y.topCWE = sumMaps(q0.topCWE, q1.topCWE, q2.topCWE, q3.topCWE)
+	y.cweBySev = sumSevMaps(q0.cweBySev, q1.cweBySev, q2.cweBySev, q3.cweBySev)
+	y.cweScore = sumMaps(q0.cweScore, q1.cweScore, q2.cweScore, q3.cweScore)
+	y.cweCats = sumCatMaps(q0.cweCats, q1.cweCats, q2.cweCats, q3.cweCats)
+	y.cweCatsByLob = sumCatLobMaps(q0.cweCatsByLob, q1.cweCatsByLob, q2.cweCatsByLob, q3.cweCatsByLob)
+
+	// Scans, if there's scan history
+	if q0.scansByTool != nil {
+		y.scansByTool = sumMaps(q0.scansByTool, q1.scansByTool, q2.scansByTool, q3.scansByTool)
+		y.scansByLob = sumMaps(q0.scansByLob, q1.scansByLob, q2.scansByLob, q3.scansByLob)
+		y.totScans = q0.totScans + q1.totScans + q2.totScans + q3.totScans
+		y.cleanScans = q0.cleanScans + q1.cleanScans + q2.cleanScans + q3.cleanScans
 	}
+}
 
-	fmt.Println("")
-	fmt.Println("Copy and Paste into a plain text file to create a CSV")
-	fmt.Println("")
-	fmt.Printf("Lob,%+v Crit/High,%+v Tot Asmts,%+v Crit/High,%+v Tot Asmts,%+v Crit/High,%+v Tot Asmts\n",
-		m0.tStamp.Month(), m0.tStamp.Month(), m1.tStamp.Month(), m1.tStamp.Month(), m2.tStamp.Month(), m2.tStamp.Month())
-	sAsByLob := sortCounts(m0.assessByLob, false)
-	for j := 0; j < len(sAsByLob); j++ {
-		for k, _ := range sAsByLob[j] {
-			fmt.Printf("%+v,%+v,%+v,%+v,%+v,%+v,%+v\n", k,
-				(m0.vulnByLob[k].crit + m0.vulnByLob[k].high), m0.assessByLob[k],
-				(m1.vulnByLob[k].crit + m1.vulnByLob[k].high), m1.assessByLob[k],
-				(m2.vulnByLob[k].crit + m2.vulnByLob[k].high), m2.assessByLob[k],
-			)
+func main() {
+	// Run the command given, the full report if there isn't one
+	err := runCommand(os.Args[1:])
+	if err != nil {
+		// Most errors end with a newline, add one to those that don't
+		msg := err.Error()
+		if !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
 		}
+		fmt.Print(msg)
+		var e exitError
+		if errors.As(err, &e) {
+			os.Exit(e.code)
+		}
+		os.Exit(1)
 	}
 
-	fmt.Println("")
-	fmt.Println("Done.")
-
 	//TODO - Global
 	//(1) For LoB stats - First get a list of LoB/Teams (getTeams call),
 	//    set them all to zero, _then_ run the month stat's like I do now so that
@@ -0,0 +1,153 @@
+// tfmetrics_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuarterMonths(t *testing.T) {
+	defer func(d map[int]string, e map[int]int) { qtrDefs, quarterEnd = d, e }(qtrDefs, quarterEnd)
+	tests := []struct {
+		quarters []string
+		end      string
+		label    string
+		months   []string // months with findings, newest first
+	}{
+		{nil, "2026-10-19", "Q4-2026", []string{"2026-10"}},
+		{nil, "2026-11-19", "Q4-2026", []string{"2026-11", "2026-10"}},
+		{nil, "2026-12-31", "Q4-2026", []string{"2026-12", "2026-11", "2026-10"}},
+		{nil, "2026-09-30", "Q3-2026", []string{"2026-09", "2026-08", "2026-07"}},
+		{nil, "2027-01-05", "Q1-2027", []string{"2027-01"}},
+		// Q4-2027 runs from November 2026 to January 2027
+		{wrappingQuarters, "2026-10-19", "Q3-2027", []string{"2026-10", "2026-09", "2026-08"}},
+		{wrappingQuarters, "2026-11-19", "Q4-2027", []string{"2026-11"}},
+		{wrappingQuarters, "2026-12-31", "Q4-2027", []string{"2026-12", "2026-11"}},
+		{wrappingQuarters, "2027-01-05", "Q4-2027", []string{"2027-01", "2026-12", "2026-11"}},
+		{wrappingQuarters, "2027-02-28", "Q1-2028", []string{"2027-02"}},
+	}
+	for _, tt := range tests {
+		useQuarters(t, tt.quarters)
+		end := mustDay(t, tt.end)
+		ms := fakeMonths(end, 3)
+		var q tfQuarter
+		qm := quarterMonths(end, ms)
+		rollQuarter(&q, 5, qm[0], qm[1], qm[2])
+		var got []string
+		for _, m := range q.months {
+			if m.totVulns > 0 {
+				got = append(got, m.tStamp.Format(monthKey))
+			}
+		}
+		if q.qLabel != tt.label || !reflect.DeepEqual(got, tt.months) {
+			t.Errorf("%v: %v covers %v, want %v covering %v", tt.end, q.qLabel, got, tt.label, tt.months)
+		}
+		if q.totVulns != 6*len(tt.months) {
+			t.Errorf("%v: %v findings, want %v", tt.end, q.totVulns, 6*len(tt.months))
+		}
+	}
+}
+
+func TestRollYear(t *testing.T) {
+	var y tfYear
+	rollYear(&y, 5, fakeMonths(mustDay(t, "2026-10-19"), 12))
+
+	labels := []string{"Q4-2026", "Q3-2026", "Q2-2026", "Q1-2026"}
+	if !reflect.DeepEqual(y.qLabels[:], labels) {
+		t.Errorf("quarters are %v, want %v", y.qLabels, labels)
+	}
+	// October and the 3 quarters before it
+	if y.totVulns != 6*10 {
+		t.Errorf("year has %v findings, want %v", y.totVulns, 6*10)
+	}
+	if y.yearEnds != "Q4-2026" {
+		t.Errorf("year ends %v, want Q4-2026", y.yearEnds)
+	}
+}
+
+func TestSumMonthsCached(t *testing.T) {
+	defer func() { monthCache = make(map[string]*tfMonth) }()
+	end := mustDay(t, "2026-10-19")
+	ms := fakeMonths(end, 3)
+	for _, m := range ms {
+		monthCache[m.tStamp.Format(monthKey)] = m
+	}
+
+	// All cached, so nothing is searched for
+	got := sumMonths(end, 3)
+	for i := range ms {
+		if got[i] != ms[i] {
+			t.Errorf("month %v was searched for again", ms[i].tStamp.Format(monthKey))
+		}
+	}
+	m := tfMonth{tStamp: ms[1].tStamp}
+	sumMonth(&m)
+	if m.totVulns != ms[1].totVulns || m.search != ms[1].search {
+		t.Errorf("sumMonth didn't reuse %v", ms[1].tStamp.Format(monthKey))
+	}
+}
+
+func TestRankCounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		counts    map[string]int
+		ascending bool
+		want      []rank
+	}{
+		{"empty", map[string]int{}, false, []rank{}},
+		{"descending", map[string]int{"a": 1, "b": 3, "c": 2}, false, []rank{{"b", 3}, {"c", 2}, {"a", 1}}},
+		{"ascending", map[string]int{"a": 1, "b": 3, "c": 2}, true, []rank{{"a", 1}, {"c", 2}, {"b", 3}}},
+		{"ties by name", map[string]int{"shop": 2, "cart": 2, "pay-web": 5, "pay-api": 2}, false,
+			[]rank{{"pay-web", 5}, {"cart", 2}, {"pay-api", 2}, {"shop", 2}}},
+		{"ties by name ascending", map[string]int{"shop": 2, "cart": 2, "pay-web": 0}, true,
+			[]rank{{"pay-web", 0}, {"cart", 2}, {"shop", 2}}},
+	}
+	for _, tt := range tests {
+		// Map order is random so try a few times
+		for i := 0; i < 5; i++ {
+			if got := rankCounts(tt.counts, tt.ascending); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%v: got %v want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCWECounts(t *testing.T) {
+	const xss, sqli, path = "CWE-79: CWE-79", "CWE-89: CWE-89", "CWE-22: CWE-22"
+	tests := []struct {
+		sevs []int
+		want map[string]int
+	}{
+		{nil, map[string]int{xss: 3, sqli: 1, path: 2}},
+		{[]int{5}, map[string]int{xss: 1, path: 1}},
+		{[]int{5, 4}, map[string]int{xss: 1, sqli: 1, path: 1}},
+		{[]int{2}, map[string]int{xss: 1, path: 1}},
+		{[]int{1}, map[string]int{}},
+	}
+	for _, tt := range tests {
+		if got := cweCounts(fakeSearch(), tt.sevs...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("severities %v: got %v want %v", tt.sevs, got, tt.want)
+		}
+	}
+}
+
+func TestCWEScores(t *testing.T) {
+	defer func(w map[int]int) { vulnWeight = w }(vulnWeight)
+	const xss, sqli, path = "CWE-79: CWE-79", "CWE-89: CWE-89", "CWE-22: CWE-22"
+	tests := []struct {
+		weights map[int]int
+		want    map[string]int
+	}{
+		// A critical, a medium and a low for XSS, a high for SQLi and a critical and a low for path traversal
+		{map[int]int{5: 16, 4: 8, 3: 4, 2: 2, 1: 1}, map[string]int{xss: 22, sqli: 8, path: 18}},
+		{map[int]int{5: 10, 4: 8, 3: 4, 2: 2, 1: 1}, map[string]int{xss: 16, sqli: 8, path: 12}},
+		{map[int]int{5: 1, 4: 0, 3: 0, 2: 0, 1: 0}, map[string]int{xss: 1, sqli: 0, path: 1}},
+	}
+	for _, tt := range tests {
+		vulnWeight = tt.weights
+		if got := cweScores(fakeSearch()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("weights %v: got %v want %v", tt.weights, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// weeks.go
+// metrics for ISO weeks and rolling weeks up into months
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tf "github.com/mtesauro/tfclient"
+)
+
+// Gather the n ISO weeks ending with the week t is in, newest first. Weeks
+// that cross into a new month are searched for in two parts so they can be
+// rolled up into months exactly.
+func fetchWeeks(t time.Time, n int) ([]*tfWeek, error) {
+	ws := isoWeeks(t, n)
+	var parts []*tfRange
+	for _, w := range ws {
+		parts = append(parts, w.parts...)
+	}
+
+	err := fetchAll(len(parts), func(i int) error {
+		var search tf.SrchResp
+		err := rangeSearch(parts[i].start, parts[i].end, &search)
+		if err != nil {
+			return err
+		}
+		calcRange(parts[i], &search)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range ws {
+		var searches []*tf.SrchResp
+		for _, p := range w.parts {
+			searches = append(searches, p.metrics.search)
+		}
+		w.metrics = &tfMonth{
+			tStamp:   w.end,
+			mpartial: w.wpartial,
+			quarter:  getQuarter(w.end.Month(), w.end.Year()),
+		}
+		calcMonth(w.metrics, joinSearches(searches...), appCount)
+		countScans(w.metrics, w.start, w.end)
+	}
+
+	return ws, nil
+}
+
+// The n ISO weeks ending with the week t is in, newest first, each split at
+// the end of a month
+func isoWeeks(t time.Time, n int) []*tfWeek {
+	today := now()
+	ws := make([]*tfWeek, n)
+	st := weekStart(t)
+	for i := 0; i < n; i++ {
+		w := &tfWeek{start: st, end: st.AddDate(0, 0, 6)}
+		w.year, w.week = st.ISOWeek()
+		if !w.end.Before(today) {
+			w.wpartial = true
+		}
+		// Split the week at the end of the month
+		for p := w.start; !p.After(w.end); {
+			e := monthEnd(p)
+			if e.After(w.end) {
+				e = w.end
+			}
+			w.parts = append(w.parts, &tfRange{label: p.Format(monthKey), start: p, end: e})
+			p = e.AddDate(0, 0, 1)
+		}
+		ws[i] = w
+		st = st.AddDate(0, 0, -7)
+	}
+
+	return ws
+}
+
+// Roll weeks up into the months they fall in, newest first. Months the weeks
+// only cover part of are marked as partial.
+func rollWeeks(ws []*tfWeek, apps int) []*tfMonth {
+	var ms []*tfMonth
+	byMonth := make(map[string][]*tfRange)
+	var keys []string
+	for _, w := range ws {
+		for i := len(w.parts) - 1; i >= 0; i-- {
+			p := w.parts[i]
+			if _, ok := byMonth[p.label]; !ok {
+				keys = append(keys, p.label)
+			}
+			byMonth[p.label] = append(byMonth[p.label], p)
+		}
+	}
+
+	for _, k := range keys {
+		ps := byMonth[k]
+		var searches []*tf.SrchResp
+		first, last := ps[0].start, ps[0].end
+		for _, p := range ps {
+			searches = append(searches, p.metrics.search)
+			if p.start.Before(first) {
+				first = p.start
+			}
+			if p.end.After(last) {
+				last = p.end
+			}
+		}
+		m := &tfMonth{
+			tStamp:  last,
+			quarter: getQuarter(last.Month(), last.Year()),
+		}
+		// Partial if the weeks don't cover the whole month or it isn't over yet
+		if first.Day() != 1 || last.Day() != lastDate(int(last.Month()), last.Year()) || !last.Before(now()) {
+			m.mpartial = true
+		}
+		calcMonth(m, joinSearches(searches...), apps)
+		countScans(m, first, last)
+		ms = append(ms, m)
+	}
+
+	return ms
+}
+
+// Monday of the ISO week t is in
+func weekStart(t time.Time) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+// e.g. 2026-W40
+func weekLabel(w *tfWeek) string {
+	return fmt.Sprintf("%v-W%02d", w.year, w.week)
+}
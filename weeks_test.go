@@ -0,0 +1,86 @@
+// weeks_test.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestISOWeeks(t *testing.T) {
+	defer func(n func() time.Time) { now = n }(now)
+	tests := []struct {
+		today string
+		t     string
+		n     int
+		want  string // label start end and month parts of each week, newest first
+	}{
+		// 2026-10-19 is a Monday
+		{"2026-10-19", "2026-10-19", 3,
+			"2026-W43 2026-10-19 2026-10-25 partial 2026-10|2026-W42 2026-10-12 2026-10-18 2026-10|2026-W41 2026-10-05 2026-10-11 2026-10"},
+		{"2026-10-19", "2026-10-18", 1, "2026-W42 2026-10-12 2026-10-18 2026-10"},
+		// Weeks crossing into a new month are split in two
+		{"2026-10-19", "2026-10-01", 2, "2026-W40 2026-09-28 2026-10-04 2026-09,2026-10|2026-W39 2026-09-21 2026-09-27 2026-09"},
+		// and into a new year are in the ISO year they start in
+		{"2027-01-19", "2027-01-03", 1, "2026-W53 2026-12-28 2027-01-03 2026-12,2027-01"},
+		{"2027-01-19", "2027-01-04", 1, "2027-W01 2027-01-04 2027-01-10 2027-01"},
+	}
+	for _, tt := range tests {
+		today := mustDay(t, tt.today)
+		now = func() time.Time { return today }
+		var got []string
+		for _, w := range isoWeeks(mustDay(t, tt.t), tt.n) {
+			g := weekLabel(w) + " " + w.start.Format(dayKey) + " " + w.end.Format(dayKey)
+			if w.wpartial {
+				g += " partial"
+			}
+			var parts []string
+			for _, p := range w.parts {
+				parts = append(parts, p.label)
+			}
+			got = append(got, g+" "+strings.Join(parts, ","))
+		}
+		if g := strings.Join(got, "|"); g != tt.want {
+			t.Errorf("%v weeks to %v on %v: got %q want %q", tt.n, tt.t, tt.today, g, tt.want)
+		}
+	}
+}
+
+func TestRollWeeks(t *testing.T) {
+	defer func(n func() time.Time) { now = n }(now)
+	tests := []struct {
+		today string
+		t     string
+		n     int
+		want  string // month, findings and whether it's partial, newest first
+	}{
+		// Each week or part of one has fakeSearch's 6 findings
+		{"2026-10-19", "2026-10-07", 2, "2026-10 12 partial|2026-09 6 partial"},
+		// February 2027 is exactly 4 ISO weeks
+		{"2027-03-10", "2027-02-22", 4, "2027-02 24 full"},
+		{"2027-02-25", "2027-02-22", 4, "2027-02 24 partial"},
+		{"2027-03-10", "2027-03-01", 5, "2027-03 6 partial|2027-02 24 full"},
+	}
+	for _, tt := range tests {
+		today := mustDay(t, tt.today)
+		now = func() time.Time { return today }
+		ws := isoWeeks(mustDay(t, tt.t), tt.n)
+		for _, w := range ws {
+			for _, p := range w.parts {
+				calcRange(p, fakeSearch())
+			}
+		}
+		var got []string
+		for _, m := range rollWeeks(ws, 5) {
+			state := "full"
+			if m.mpartial {
+				state = "partial"
+			}
+			got = append(got, fmt.Sprintf("%v %v %v", m.tStamp.Format(monthKey), m.totVulns, state))
+		}
+		if g := strings.Join(got, "|"); g != tt.want {
+			t.Errorf("%v weeks to %v on %v: got %q want %q", tt.n, tt.t, tt.today, g, tt.want)
+		}
+	}
+}